@@ -0,0 +1,334 @@
+// Package api serves a NoteService as a JSON REST API:
+//
+//	GET    /notes            list notes, filtered by ?note=, ?tag=, ?since=, ?until= and ?on=
+//	POST   /notes            add a note from {"note": "...", "tags": [...]}
+//	GET    /notes/{id}       read a note
+//	PUT    /notes/{id}       replace the text and tags of a note
+//	DELETE /notes/{id}       move a note to the trash
+//	GET    /search?q=        search notes, with the same date filters
+//	GET    /tags             list tags with their note counts
+//
+// Notes have the shape of render.Record, with encrypted texts replaced by
+// service.EncryptedPlaceholder; a PUT of the placeholder keeps the stored
+// text and changes only the tags. Errors are returned as
+// {"error": "..."} with a 4xx or 5xx status.
+//
+// The API has no authentication. So that web pages cannot reach it through
+// the browser, requests must name a loopback host or the listen address in
+// their Host header, and bodies must be sent as application/json, which a
+// page cannot do across origins without the API agreeing to it.
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iamunni/hugnin/model"
+	"github.com/iamunni/hugnin/render"
+	"github.com/iamunni/hugnin/service"
+	"github.com/iamunni/hugnin/store"
+)
+
+// maxBodyBytes limits the size of request bodies.
+const maxBodyBytes = 1 << 20
+
+// NoteRequest is the body of POST /notes and PUT /notes/{id}.
+type NoteRequest struct {
+	Note string   `json:"note"`
+	Tags []string `json:"tags"`
+}
+
+// TagResponse is one tag of GET /tags.
+type TagResponse struct {
+	Name  string `json:"name"`
+	Notes int    `json:"notes"`
+}
+
+// ErrorResponse is the body of every failed request.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+type handler struct {
+	notes service.NoteService
+	host  string
+}
+
+// NewHandler returns the API handler for notes listening on addr, whose
+// host is accepted in Host headers besides the loopback ones. It is safe
+// for concurrent use as long as the store behind notes is.
+func NewHandler(notes service.NoteService, addr string) http.Handler {
+	return &handler{
+		notes: notes,
+		host:  hostname(addr),
+	}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.allowedHost(r.Host) {
+		writeError(w, http.StatusForbidden, fmt.Errorf("host %q is not allowed, use localhost or the listen address", r.Host))
+		return
+	}
+	switch r.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || mediaType != "application/json" {
+			writeError(w, http.StatusUnsupportedMediaType, errors.New("request bodies must be sent as Content-Type: application/json"))
+			return
+		}
+	}
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	switch {
+	case path == "/notes":
+		switch r.Method {
+		case http.MethodGet:
+			h.listNotes(w, r)
+		case http.MethodPost:
+			h.addNote(w, r)
+		default:
+			methodNotAllowed(w, http.MethodGet, http.MethodPost)
+		}
+	case strings.HasPrefix(path, "/notes/"):
+		id, err := strconv.ParseInt(strings.TrimPrefix(path, "/notes/"), 10, 64)
+		if err != nil || id <= 0 {
+			writeError(w, http.StatusNotFound, fmt.Errorf("no such note %q", strings.TrimPrefix(path, "/notes/")))
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			h.getNote(w, r, id)
+		case http.MethodPut:
+			h.updateNote(w, r, id)
+		case http.MethodDelete:
+			h.deleteNote(w, r, id)
+		default:
+			methodNotAllowed(w, http.MethodGet, http.MethodPut, http.MethodDelete)
+		}
+	case path == "/search":
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		h.search(w, r)
+	case path == "/tags":
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		h.listTags(w, r)
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("no such endpoint %s", r.URL.Path))
+	}
+}
+
+// allowedHost reports whether a request for host, as in its Host header,
+// is served: host must be a loopback name or address, or the host the API
+// listens on. This defeats DNS rebinding, where a page on another site
+// resolves its own name to 127.0.0.1.
+func (h *handler) allowedHost(host string) bool {
+	name := hostname(host)
+	if name == "localhost" || strings.HasSuffix(name, ".localhost") {
+		return true
+	}
+	if ip := net.ParseIP(name); ip != nil && ip.IsLoopback() {
+		return true
+	}
+	return name != "" && name == h.host
+}
+
+// hostname returns the lower case host of a host[:port] address, without
+// the brackets of an IPv6 address.
+func hostname(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	return strings.ToLower(strings.Trim(host, "[]"))
+}
+
+func (h *handler) listNotes(w http.ResponseWriter, r *http.Request) {
+	period, ok := dateRange(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	filter := model.Note{Value: query.Get("note"), Tags: queryList(query["tag"])}
+	notes, err := h.notes.View(r.Context(), filter, period)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, records(notes))
+}
+
+func (h *handler) addNote(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeNote(w, r)
+	if !ok {
+		return
+	}
+	added, err := h.notes.Add(r.Context(), model.Note{Value: req.Note, Tags: req.Tags})
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	note, err := h.notes.Get(r.Context(), added.Id)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/notes/%d", note.Id))
+	writeJSON(w, http.StatusCreated, records([]model.Note{note})[0])
+}
+
+func (h *handler) getNote(w http.ResponseWriter, r *http.Request, id int64) {
+	note, err := h.notes.Get(r.Context(), id)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, records([]model.Note{note})[0])
+}
+
+func (h *handler) updateNote(w http.ResponseWriter, r *http.Request, id int64) {
+	req, ok := decodeNote(w, r)
+	if !ok {
+		return
+	}
+	_, err := h.notes.Update(r.Context(), model.Note{Id: id, Value: req.Note, Tags: req.Tags})
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	h.getNote(w, r, id)
+}
+
+func (h *handler) deleteNote(w http.ResponseWriter, r *http.Request, id int64) {
+	deleted, err := h.notes.Delete(r.Context(), model.Note{Id: id})
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	if deleted == 0 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("note %d: %w", id, store.ErrNotFound))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) search(w http.ResponseWriter, r *http.Request) {
+	keyword := strings.TrimSpace(r.URL.Query().Get("q"))
+	if keyword == "" {
+		writeError(w, http.StatusBadRequest, errors.New("missing search query q"))
+		return
+	}
+	period, ok := dateRange(w, r)
+	if !ok {
+		return
+	}
+	notes, err := h.notes.Search(r.Context(), keyword, period)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, records(notes))
+}
+
+func (h *handler) listTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := h.notes.Tags(r.Context())
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	result := make([]TagResponse, 0, len(tags))
+	for _, tag := range tags {
+		result = append(result, TagResponse{Name: tag.Name, Notes: tag.Notes})
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// records converts notes to their JSON form, hiding encrypted texts.
+func records(notes []model.Note) []render.Record {
+	return render.NewRecords(service.ConcealEncrypted(notes))
+}
+
+// decodeNote reads a NoteRequest body, answering 400 when it is not valid.
+func decodeNote(w http.ResponseWriter, r *http.Request) (NoteRequest, bool) {
+	var req NoteRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid note body: %w", err))
+		return req, false
+	}
+	if strings.TrimSpace(req.Note) == "" {
+		writeError(w, http.StatusBadRequest, errors.New("note must not be empty"))
+		return req, false
+	}
+	return req, true
+}
+
+// dateRange reads the since, until and on query parameters, answering 400
+// when they are not valid.
+func dateRange(w http.ResponseWriter, r *http.Request) (model.DateRange, bool) {
+	query := r.URL.Query()
+	period, err := service.ParseDateRange(query.Get("since"), query.Get("until"), query.Get("on"), time.Now())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return period, false
+	}
+	return period, true
+}
+
+// queryList splits repeated and comma separated query values.
+func queryList(values []string) []string {
+	var result []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				result = append(result, item)
+			}
+		}
+	}
+	return result
+}
+
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+}
+
+// writeServiceError answers 404 for unknown notes and 500 otherwise.
+func writeServiceError(w http.ResponseWriter, err error) {
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if errors.Is(err, service.ErrEncrypted) {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	log.Println(err)
+	writeError(w, http.StatusInternalServerError, errors.New("internal error"))
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, ErrorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(body)
+	if err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,258 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/iamunni/hugnin/model"
+	"github.com/iamunni/hugnin/render"
+	"github.com/iamunni/hugnin/service"
+	"github.com/iamunni/hugnin/store"
+)
+
+// newTestServer serves the API over a fresh in-memory SQLite store.
+func newTestServer(t *testing.T) *httptest.Server {
+	s := store.NewSQLiteStore(":memory:")
+	if err := s.Open(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(NewHandler(service.NewNoteService(s), "127.0.0.1:0"))
+	t.Cleanup(func() {
+		server.Close()
+		s.Close()
+	})
+	return server
+}
+
+// do sends a request and decodes the JSON response into out, when given.
+func do(t *testing.T, server *httptest.Server, method, path, body string, out interface{}) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			t.Fatalf("%s %s: decoding %q: %v", method, path, data, err)
+		}
+	}
+	return resp
+}
+
+func TestAPI_NoteLifecycle(t *testing.T) {
+	server := newTestServer(t)
+
+	var created render.Record
+	resp := do(t, server, http.MethodPost, "/notes", `{"note": "restart postgres", "tags": ["db", "oncall"]}`, &created)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("POST /notes status = %d, want 201", resp.StatusCode)
+	}
+	if created.Id != 1 || created.Note != "restart postgres" || !reflect.DeepEqual(created.Tags, []string{"db", "oncall"}) || created.CreatedAt.IsZero() {
+		t.Errorf("POST /notes = %+v", created)
+	}
+	if got := resp.Header.Get("Location"); got != "/notes/1" {
+		t.Errorf("POST /notes Location = %q, want /notes/1", got)
+	}
+	do(t, server, http.MethodPost, "/notes", `{"note": "rotate keys"}`, nil)
+
+	var got render.Record
+	if resp := do(t, server, http.MethodGet, "/notes/1", "", &got); resp.StatusCode != http.StatusOK || !reflect.DeepEqual(got, created) {
+		t.Errorf("GET /notes/1 = %d %+v, want %+v", resp.StatusCode, got, created)
+	}
+
+	var updated render.Record
+	resp = do(t, server, http.MethodPut, "/notes/1", `{"note": "restart postgres 16", "tags": ["db"]}`, &updated)
+	if resp.StatusCode != http.StatusOK || updated.Note != "restart postgres 16" || !reflect.DeepEqual(updated.Tags, []string{"db"}) {
+		t.Errorf("PUT /notes/1 = %d %+v", resp.StatusCode, updated)
+	}
+
+	var list []render.Record
+	if resp := do(t, server, http.MethodGet, "/notes?tag=db", "", &list); resp.StatusCode != http.StatusOK || len(list) != 1 || list[0].Id != 1 {
+		t.Errorf("GET /notes?tag=db = %d %+v", resp.StatusCode, list)
+	}
+	if do(t, server, http.MethodGet, "/notes", "", &list); len(list) != 2 {
+		t.Errorf("GET /notes = %+v, want 2 notes", list)
+	}
+
+	var found []render.Record
+	if resp := do(t, server, http.MethodGet, "/search?q=rotate", "", &found); resp.StatusCode != http.StatusOK || len(found) != 1 || found[0].Id != 2 {
+		t.Errorf("GET /search?q=rotate = %d %+v", resp.StatusCode, found)
+	}
+
+	var tags []TagResponse
+	if resp := do(t, server, http.MethodGet, "/tags", "", &tags); resp.StatusCode != http.StatusOK || !reflect.DeepEqual(tags, []TagResponse{{Name: "db", Notes: 1}, {Name: "oncall", Notes: 0}}) {
+		t.Errorf("GET /tags = %d %+v", resp.StatusCode, tags)
+	}
+
+	if resp := do(t, server, http.MethodDelete, "/notes/1", "", nil); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("DELETE /notes/1 status = %d, want 204", resp.StatusCode)
+	}
+	if resp := do(t, server, http.MethodGet, "/notes/1", "", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET /notes/1 after DELETE status = %d, want 404", resp.StatusCode)
+	}
+	if resp := do(t, server, http.MethodDelete, "/notes/1", "", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("second DELETE /notes/1 status = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestAPI_Errors(t *testing.T) {
+	server := newTestServer(t)
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{name: "empty note", method: http.MethodPost, path: "/notes", body: `{"note": " "}`, want: http.StatusBadRequest},
+		{name: "malformed body", method: http.MethodPost, path: "/notes", body: `{"note":`, want: http.StatusBadRequest},
+		{name: "unknown field", method: http.MethodPost, path: "/notes", body: `{"text": "x"}`, want: http.StatusBadRequest},
+		{name: "unknown note", method: http.MethodGet, path: "/notes/42", want: http.StatusNotFound},
+		{name: "update unknown note", method: http.MethodPut, path: "/notes/42", body: `{"note": "x"}`, want: http.StatusNotFound},
+		{name: "invalid id", method: http.MethodGet, path: "/notes/abc", want: http.StatusNotFound},
+		{name: "missing query", method: http.MethodGet, path: "/search", want: http.StatusBadRequest},
+		{name: "invalid date", method: http.MethodGet, path: "/notes?since=someday", want: http.StatusBadRequest},
+		{name: "wrong method", method: http.MethodDelete, path: "/notes", want: http.StatusMethodNotAllowed},
+		{name: "unknown endpoint", method: http.MethodGet, path: "/nope", want: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body ErrorResponse
+			resp := do(t, server, tt.method, tt.path, tt.body, &body)
+			if resp.StatusCode != tt.want {
+				t.Errorf("%s %s status = %d, want %d", tt.method, tt.path, resp.StatusCode, tt.want)
+			}
+			if body.Error == "" {
+				t.Errorf("%s %s returned no error message", tt.method, tt.path)
+			}
+			if got := resp.Header.Get("Content-Type"); got != "application/json" {
+				t.Errorf("%s %s Content-Type = %q", tt.method, tt.path, got)
+			}
+		})
+	}
+}
+
+func TestAPI_EncryptedNote(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemoryStore()
+	if err := s.Open(ctx); err != nil {
+		t.Fatal(err)
+	}
+	crypter, err := service.NewCrypter("passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := crypter.Encrypt("vpn: alice / s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Write(ctx, sealed, []string{"oncall"}); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(NewHandler(service.NewNoteService(s), "127.0.0.1:0"))
+	t.Cleanup(server.Close)
+
+	var got render.Record
+	do(t, server, http.MethodGet, "/notes/1", "", &got)
+	if got.Note != service.EncryptedPlaceholder {
+		t.Fatalf("GET /notes/1 = %+v, want the note concealed", got)
+	}
+	var updated render.Record
+	resp := do(t, server, http.MethodPut, "/notes/1", `{"note": "[encrypted]", "tags": ["vpn"]}`, &updated)
+	if resp.StatusCode != http.StatusOK || updated.Note != service.EncryptedPlaceholder || !reflect.DeepEqual(updated.Tags, []string{"vpn"}) {
+		t.Errorf("PUT /notes/1 of the placeholder = %d %+v", resp.StatusCode, updated)
+	}
+	var body ErrorResponse
+	if resp := do(t, server, http.MethodPut, "/notes/1", `{"note": "vpn: alice / hunter2"}`, &body); resp.StatusCode != http.StatusConflict {
+		t.Errorf("PUT /notes/1 of new text = %d %+v, want 409", resp.StatusCode, body)
+	}
+
+	notes, err := s.Read(ctx, model.Note{Id: 1}, model.DateRange{})
+	if err != nil || len(notes) != 1 {
+		t.Fatalf("Read() = %v, %v", notes, err)
+	}
+	if text, err := crypter.Decrypt(notes[0].Value); err != nil || text != "vpn: alice / s3cret" {
+		t.Errorf("stored note decrypts to %q, %v, want the original secret", text, err)
+	}
+}
+
+func TestAPI_RejectsCrossSiteRequests(t *testing.T) {
+	server := newTestServer(t)
+	tests := []struct {
+		name        string
+		method      string
+		host        string
+		contentType string
+		want        int
+	}{
+		{name: "form post", method: http.MethodPost, contentType: "application/x-www-form-urlencoded", want: http.StatusUnsupportedMediaType},
+		{name: "plain text put", method: http.MethodPut, contentType: "text/plain", want: http.StatusUnsupportedMediaType},
+		{name: "no content type", method: http.MethodPost, want: http.StatusUnsupportedMediaType},
+		{name: "rebound host", method: http.MethodGet, host: "evil.example:8080", want: http.StatusForbidden},
+		{name: "rebound host post", method: http.MethodPost, host: "evil.example", contentType: "application/json", want: http.StatusForbidden},
+		{name: "json with charset", method: http.MethodPost, contentType: "application/json; charset=utf-8", want: http.StatusCreated},
+		{name: "localhost", method: http.MethodGet, host: "localhost:8080", want: http.StatusOK},
+		{name: "loopback ipv6", method: http.MethodGet, host: "[::1]:8080", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := "/notes"
+			if tt.method == http.MethodPut {
+				path = "/notes/1"
+			}
+			req, err := http.NewRequest(tt.method, server.URL+path, strings.NewReader(`{"note": "x"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.host != "" {
+				req.Host = tt.host
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			resp, err := server.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("%s %s with Host %q and Content-Type %q status = %d, want %d", tt.method, path, tt.host, tt.contentType, resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPI_AllowsListenHost(t *testing.T) {
+	s := store.NewMemoryStore()
+	if err := s.Open(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	handler := NewHandler(service.NewNoteService(s), "notes.lan:8080")
+	for host, want := range map[string]int{"notes.lan": http.StatusOK, "NOTES.LAN:8080": http.StatusOK, "other.lan:8080": http.StatusForbidden} {
+		req := httptest.NewRequest(http.MethodGet, "/tags", nil)
+		req.Host = host
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("GET /tags with Host %q status = %d, want %d", host, rec.Code, want)
+		}
+	}
+}
@@ -4,38 +4,98 @@ Copyright © 2023 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
-	"log"
+	"errors"
+	"io"
+	"os"
 	"strings"
 
 	"github.com/iamunni/hugnin/service"
-	"github.com/iamunni/hugnin/store"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
+var encryptNote bool
+var addFile string
+
 // addCmd represents the add command
 var addCmd = &cobra.Command{
-	Use:   "add",
-	Short: "A brief description of your command",
-	Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
-
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
-	Args: cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		note.Value = strings.Join(args, " ")
-		noteService := service.NewNoteService(store.NewSQLiteStore())
-
-		err := noteService.Add(note)
+	Use:   "add [note... | -]",
+	Short: "Add a note",
+	Long: `Add a note from the arguments, which are joined with spaces, from a
+file with --file, or from standard input when the only argument is "-" or
+when there are no arguments and the input is not a terminal:
+
+  hugnin add "restart postgres" -t db
+  kubectl describe pod web-0 | hugnin add -t k8s
+  hugnin add --file runbook.md
+
+Notes read from a file or standard input keep their lines; only the
+trailing newlines are dropped.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var err error
+		note.Value, err = noteBody(args, addFile, cmd.InOrStdin(), term.IsTerminal(int(os.Stdin.Fd())))
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
+		out := newPresenter(cmd)
+		noteService := service.NewNoteService(noteStore)
+		if encryptNote {
+			crypter, err := newCrypter(passphraseEnv, "Passphrase", true)
+			if err != nil {
+				return err
+			}
+			note.Value, err = crypter.Encrypt(note.Value)
+			if err != nil {
+				return err
+			}
+		}
+
+		added, err := noteService.Add(cmd.Context(), note)
+		if err != nil {
+			return err
+		}
+		out.messagef("note %d added", added.Id)
+		return nil
 	},
 }
 
+// noteBody returns the text of the note to add: the contents of file,
+// of stdin or the arguments, without a UTF-8 byte order mark or trailing
+// newlines. stdin is read when the only argument is "-", or when there
+// are no arguments and it is not a terminal.
+func noteBody(args []string, file string, stdin io.Reader, stdinIsTerminal bool) (string, error) {
+	var body []byte
+	var err error
+	switch {
+	case file != "":
+		if len(args) > 0 {
+			return "", errors.New("pass the note as arguments or with --file, not both")
+		}
+		body, err = os.ReadFile(file)
+	case len(args) == 1 && args[0] == "-":
+		body, err = io.ReadAll(stdin)
+	case len(args) == 0:
+		if stdinIsTerminal {
+			return "", errors.New(`no note given, pass it as arguments, with --file or on standard input with "-"`)
+		}
+		body, err = io.ReadAll(stdin)
+	default:
+		body = []byte(strings.Join(args, " "))
+	}
+	if err != nil {
+		return "", err
+	}
+	value := strings.TrimRight(strings.TrimPrefix(string(body), "\xef\xbb\xbf"), "\r\n")
+	if strings.TrimSpace(value) == "" {
+		return "", errors.New("the note is empty")
+	}
+	return value, nil
+}
+
 func init() {
 	rootCmd.AddCommand(addCmd)
 
-	addCmd.PersistentFlags().StringVarP(&note.Tag, "tag", "t", "", "Tag for the note")
+	addCmd.PersistentFlags().StringSliceVarP(&note.Tags, "tag", "t", nil, "Comma separated tags for the note")
+	addCmd.Flags().BoolVar(&encryptNote, "encrypt", false, "Encrypt the note with a passphrase; its tags stay readable")
+	addCmd.Flags().StringVarP(&addFile, "file", "f", "", "Read the note from a file")
 }
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNoteBody(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "note.txt")
+	if err := os.WriteFile(file, []byte("\xef\xbb\xbffrom a file\r\n  indented\r\n\r\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		args     []string
+		file     string
+		stdin    string
+		terminal bool
+		want     string
+		wantErr  string
+	}{
+		{name: "arguments are joined", args: []string{"restart", "postgres"}, stdin: "ignored", want: "restart postgres"},
+		{name: "a single argument", args: []string{"two\nlines"}, terminal: true, want: "two\nlines"},
+		{name: "dash reads stdin", args: []string{"-"}, stdin: "first\n\nsecond\n\n", terminal: true, want: "first\n\nsecond"},
+		{name: "piped stdin", stdin: "piped\r\nnote\r\n", want: "piped\r\nnote"},
+		{name: "stdin byte order mark", args: []string{"-"}, stdin: "\xef\xbb\xbfnote\n", want: "note"},
+		{name: "file", file: file, stdin: "ignored", want: "from a file\r\n  indented"},
+		{name: "leading and inner blank lines are kept", args: []string{"-"}, stdin: "\n  a\n\n b  \n", want: "\n  a\n\n b  "},
+		{name: "terminal without arguments", terminal: true, wantErr: "no note given"},
+		{name: "arguments and file", args: []string{"x"}, file: file, wantErr: "not both"},
+		{name: "empty stdin", stdin: "", wantErr: "the note is empty"},
+		{name: "blank stdin", args: []string{"-"}, stdin: "\xef\xbb\xbf \r\n\t\n", wantErr: "the note is empty"},
+		{name: "missing file", file: filepath.Join(t.TempDir(), "missing.txt"), wantErr: "no such file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := noteBody(tt.args, tt.file, strings.NewReader(tt.stdin), tt.terminal)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("noteBody() = %q, %v, want an error containing %q", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("noteBody() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("noteBody() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) { return 0, errors.New("read failed") }
+
+func TestNoteBody_ReadError(t *testing.T) {
+	if _, err := noteBody([]string{"-"}, "", failingReader{}, false); err == nil || err.Error() != "read failed" {
+		t.Errorf("noteBody() error = %v, want read failed", err)
+	}
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/iamunni/hugnin/store"
+	"github.com/spf13/cobra"
+)
+
+// dbCmd groups the database maintenance commands
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Manage the notes database",
+	// db migrate and db status work on the schema as it is on disk, so
+	// they open the database without migrating it; db reindex needs it up
+	// to date like every other command.
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if cmd != dbMigrateCmd && cmd != dbStatusCmd {
+			return rootCmd.PersistentPreRunE(cmd, args)
+		}
+		cmd.SilenceUsage = true
+		return openStoreWith(cmd.Context(), store.NewUnmigratedSQLiteStore)
+	},
+}
+
+// dbMigrateCmd represents the db migrate command
+var dbMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Apply pending schema migrations",
+	Long: `Apply every schema migration the database has not seen yet.
+
+Migrations also run automatically whenever other commands open the
+database, so this command is mostly useful to upgrade a database
+explicitly, and see what changed, after installing a new hugnin binary.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := newPresenter(cmd)
+		migrator, err := sqliteMigrator()
+		if err != nil {
+			return err
+		}
+		applied, err := migrator.Migrate(cmd.Context())
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			out.messagef("database schema is up to date")
+			return nil
+		}
+		for _, m := range applied {
+			out.messagef("applied %d: %s", m.Version, m.Name)
+		}
+		return nil
+	},
+}
+
+// dbStatusCmd represents the db status command
+var dbStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the schema version and migration status",
+	Long: `List every schema migration and whether the database has had it
+applied. Pending migrations are applied by "hugnin db migrate" or by the
+next command that opens the database.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := newPresenter(cmd)
+		migrator, err := sqliteMigrator()
+		if err != nil {
+			return err
+		}
+		status, err := migrator.Migrations(cmd.Context())
+		if err != nil {
+			return err
+		}
+		for _, m := range status {
+			state := "pending"
+			if m.Applied {
+				state = "applied"
+			}
+			out.messagef("%4d  %-8s %s", m.Version, state, m.Name)
+		}
+		return nil
+	},
+}
+
+// dbReindexCmd represents the db reindex command
+var dbReindexCmd = &cobra.Command{
+	Use:   "reindex",
+	Short: "Rebuild the full-text search index",
+	Long: `Rebuild the full-text search index from the stored notes.
+
+Use it on databases created before full-text search existed, or created
+or changed by a hugnin binary built without FTS5 support
+(-tags sqlite_fts5).`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		reindexer, ok := noteStore.(store.Reindexer)
+		if !ok {
+			return errors.New("database does not have a search index")
+		}
+		err := reindexer.RebuildSearchIndex(cmd.Context())
+		if err != nil {
+			return err
+		}
+		newPresenter(cmd).messagef("search index rebuilt")
+		return nil
+	},
+}
+
+func sqliteMigrator() (store.Migrator, error) {
+	migrator, ok := noteStore.(store.Migrator)
+	if !ok {
+		return nil, errors.New("database does not support schema migrations")
+	}
+	return migrator, nil
+}
+
+func init() {
+	rootCmd.AddCommand(dbCmd)
+
+	dbCmd.AddCommand(dbMigrateCmd)
+	dbCmd.AddCommand(dbStatusCmd)
+	dbCmd.AddCommand(dbReindexCmd)
+}
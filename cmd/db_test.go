@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/spf13/pflag"
+)
+
+// runCommand runs hugnin with args against a fresh home directory and
+// returns its output.
+func runCommand(t *testing.T, args ...string) string {
+	t.Helper()
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	rootCmd.SetArgs(args)
+	t.Cleanup(func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetArgs(nil)
+		resetFlags(t)
+	})
+	if err := rootCmd.ExecuteContext(context.Background()); err != nil {
+		t.Fatalf("hugnin %s: %v", strings.Join(args, " "), err)
+	}
+	return out.String()
+}
+
+// resetFlags puts the global flags, which outlive a run, back to their
+// defaults.
+func resetFlags(t *testing.T) {
+	rootCmd.PersistentFlags().VisitAll(func(f *pflag.Flag) {
+		if err := f.Value.Set(f.DefValue); err != nil {
+			t.Fatal(err)
+		}
+		f.Changed = false
+	})
+}
+
+// newVersion1DB creates a database at the schema of migration 1 holding
+// one note.
+func newVersion1DB(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_DATA_HOME", home)
+	path := filepath.Join(home, "old.db")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	_, err = db.Exec(`CREATE TABLE notes (id INTEGER PRIMARY KEY AUTOINCREMENT, note TEXT);
+		CREATE TABLE tags (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL UNIQUE);
+		CREATE TABLE note_tags (note_id INTEGER NOT NULL REFERENCES notes(id) ON DELETE CASCADE,
+			tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+			PRIMARY KEY (note_id, tag_id));
+		INSERT INTO notes (note) VALUES ('restart postgres');
+		PRAGMA user_version = 1;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDBMigrate_OldDatabase(t *testing.T) {
+	path := newVersion1DB(t)
+
+	got := runCommand(t, "--db", path, "db", "migrate")
+	want := `applied 2: add created_at and updated_at to notes
+applied 3: create notes_fts full-text index
+applied 4: add deleted_at to notes for the trash
+applied 5: create note_revisions table
+applied 6: add uuid to notes and create tombstones table
+applied 7: create saved_searches table
+applied 8: store revision tags as JSON arrays
+`
+	if got != want {
+		t.Errorf("db migrate =\n%s\nwant\n%s", got, want)
+	}
+
+	if got := runCommand(t, "--db", path, "db", "migrate"); got != "database schema is up to date\n" {
+		t.Errorf("second db migrate = %q", got)
+	}
+	if got := runCommand(t, "--db", path, "view"); !strings.Contains(got, "restart postgres") {
+		t.Errorf("view after db migrate =\n%s", got)
+	}
+}
+
+func TestDBStatus_ShowsPending(t *testing.T) {
+	path := newVersion1DB(t)
+
+	got := runCommand(t, "--db", path, "db", "status")
+	want := `   1  applied  create notes, tags and note_tags tables
+   2  pending  add created_at and updated_at to notes
+   3  pending  create notes_fts full-text index
+   4  pending  add deleted_at to notes for the trash
+   5  pending  create note_revisions table
+   6  pending  add uuid to notes and create tombstones table
+   7  pending  create saved_searches table
+   8  pending  store revision tags as JSON arrays
+`
+	if got != want {
+		t.Errorf("db status =\n%s\nwant\n%s", got, want)
+	}
+
+	runCommand(t, "--db", path, "db", "migrate")
+	if got := runCommand(t, "--db", path, "db", "status"); strings.Contains(got, "pending") {
+		t.Errorf("db status after db migrate =\n%s", got)
+	}
+}
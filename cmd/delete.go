@@ -4,10 +4,7 @@ Copyright © 2023 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
-	"log"
-
 	"github.com/iamunni/hugnin/service"
-	"github.com/iamunni/hugnin/store"
 	"github.com/spf13/cobra"
 )
 
@@ -23,15 +20,18 @@ and usage of using your command. For example:
 Cobra is a CLI library for Go that empowers applications.
 This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		noteService := service.NewNoteService(store.NewSQLiteStore())
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := newPresenter(cmd)
+		noteService := service.NewNoteService(noteStore)
 		if deleteAll {
 			note.Id = -1
 		}
-		err := noteService.Delete(note)
+		deleted, err := noteService.Delete(cmd.Context(), note)
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
+		out.messagef("%d notes moved to the trash", deleted)
+		return nil
 	},
 }
 
@@ -39,6 +39,6 @@ func init() {
 	rootCmd.AddCommand(deleteCmd)
 
 	deleteCmd.PersistentFlags().Int64VarP(&note.Id, "id", "i", 0, "Search by note Id")
-	deleteCmd.PersistentFlags().StringVarP(&note.Tag, "tags", "t", "", "Search by Tags")
+	deleteCmd.PersistentFlags().StringSliceVarP(&note.Tags, "tags", "t", nil, "Delete the notes carrying these tags or a tag below them")
 	deleteCmd.PersistentFlags().BoolVarP(&deleteAll, "all", "a", false, "Delete All Records")
 }
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/iamunni/hugnin/service"
+	"github.com/spf13/cobra"
+)
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff <id> [rev1] [rev2]",
+	Short: "Show the changes between revisions of a note",
+	Long: `Show a unified diff between two revisions of a note, tags included.
+
+Without revisions the previous version is compared with the current one,
+with one revision that revision is compared with the current one.`,
+	Args: cobra.RangeArgs(1, 3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseNoteId(args[0])
+		if err != nil {
+			return err
+		}
+		var from, to int
+		if len(args) > 1 {
+			from, err = parseRevision(args[1])
+			if err != nil {
+				return err
+			}
+		}
+		if len(args) > 2 {
+			to, err = parseRevision(args[2])
+			if err != nil {
+				return err
+			}
+		}
+		diff, err := service.NewNoteService(noteStore).Diff(cmd.Context(), id, from, to)
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(cmd.OutOrStdout(), diff)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/iamunni/hugnin/service"
+	"github.com/spf13/cobra"
+)
+
+var editMessage string
+var editTags []string
+
+// editCmd represents the edit command
+var editCmd = &cobra.Command{
+	Use:     "edit <id>",
+	Aliases: []string{"amend"},
+	Short:   "Edit a note in $VISUAL or $EDITOR",
+	Long: `Edit a note in place, keeping its Id.
+
+The note and its tags are written to a temporary file with a small front
+matter header and opened in $VISUAL, $EDITOR or vi. Saving the file updates
+the note; leaving it unchanged does nothing. The previous version is kept
+and can be listed with "hugnin history <id>".
+
+Pass --message and/or --tag to change the note without an editor:
+
+  hugnin edit 12 --message "new text" --tag db,oncall`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := newPresenter(cmd)
+		id, err := parseNoteId(args[0])
+		if err != nil {
+			return err
+		}
+		noteService := service.NewNoteService(noteStore)
+		current, err := noteService.Get(cmd.Context(), id)
+		if err != nil {
+			return err
+		}
+
+		updated := current
+		if cmd.Flags().Changed("message") || cmd.Flags().Changed("tag") {
+			if cmd.Flags().Changed("message") {
+				updated.Value = editMessage
+			}
+			if cmd.Flags().Changed("tag") {
+				updated.Tags = editTags
+			}
+		} else if service.IsEncrypted(current.Value) {
+			return fmt.Errorf("note %d is encrypted, change its tags with --tag", current.Id)
+		} else {
+			original := service.FormatNoteFile(current)
+			edited, err := editInEditor(original)
+			if err != nil {
+				return err
+			}
+			if edited == original {
+				out.messagef("note %d unchanged", current.Id)
+				return nil
+			}
+			updated, err = service.ParseNoteFile(edited)
+			if err != nil {
+				return err
+			}
+			updated.Id = current.Id
+		}
+
+		updated, err = noteService.Update(cmd.Context(), updated)
+		if err != nil {
+			return err
+		}
+		out.messagef("note %d updated", updated.Id)
+		return nil
+	},
+}
+
+// editInEditor writes text to a temporary file, opens it in the user's
+// editor and returns the saved contents.
+func editInEditor(text string) (string, error) {
+	file, err := os.CreateTemp("", "hugnin-*.md")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(text)
+	if err != nil {
+		file.Close()
+		return "", err
+	}
+	err = file.Close()
+	if err != nil {
+		return "", err
+	}
+
+	editor := strings.Fields(editorCommand())
+	c := exec.Command(editor[0], append(editor[1:], file.Name())...)
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	err = c.Run()
+	if err != nil {
+		return "", fmt.Errorf("running editor %q: %w", strings.Join(editor, " "), err)
+	}
+
+	edited, err := os.ReadFile(file.Name())
+	if err != nil {
+		return "", err
+	}
+	return string(bytes.TrimPrefix(edited, []byte("\xef\xbb\xbf"))), nil
+}
+
+// editorCommand returns $VISUAL, then $EDITOR, then vi.
+func editorCommand() string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if editor := strings.TrimSpace(os.Getenv(env)); editor != "" {
+			return editor
+		}
+	}
+	return "vi"
+}
+
+func init() {
+	rootCmd.AddCommand(editCmd)
+
+	editCmd.Flags().StringVarP(&editMessage, "message", "m", "", "Replace the note text without opening an editor")
+	editCmd.Flags().StringSliceVarP(&editTags, "tag", "t", nil, "Replace the note tags without opening an editor")
+}
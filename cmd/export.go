@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/iamunni/hugnin/model"
+	"github.com/iamunni/hugnin/service"
+	"github.com/spf13/cobra"
+)
+
+var exportFormat, exportDir string
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export every note as JSON or as a directory of Markdown files",
+	Long: `Export every note, with its Id, tags, timestamps and trash state, to
+standard output. The JSON format is versioned and read back by
+"hugnin import":
+
+  hugnin export --format json > notes.json
+
+The markdown format writes each note that is not in the trash to
+<slug>.md in --dir, with a YAML front matter holding its Id, tags and
+timestamps:
+
+  hugnin export --format markdown --dir notes/`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := newPresenter(cmd)
+		notes, err := service.NewNoteService(noteStore).Export(cmd.Context())
+		if err != nil {
+			return err
+		}
+		switch exportFormat {
+		case "json":
+			err = service.WriteArchive(cmd.OutOrStdout(), notes, time.Now())
+		case "markdown":
+			if exportDir == "" {
+				return errors.New("--format markdown needs --dir")
+			}
+			var live []model.Note
+			for _, note := range notes {
+				if note.DeletedAt.IsZero() {
+					live = append(live, note)
+				}
+			}
+			err = service.WriteMarkdownDir(exportDir, live)
+			if err == nil {
+				out.messagef("%d notes written to %s", len(live), exportDir)
+			}
+		default:
+			return fmt.Errorf("unknown export format %q, supported formats: json, markdown", exportFormat)
+		}
+		return err
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+
+	exportCmd.Flags().StringVar(&exportFormat, "format", "json", "Export format: json or markdown")
+	exportCmd.Flags().StringVar(&exportDir, "dir", "", "Directory the markdown format writes to")
+}
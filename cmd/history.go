@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"github.com/iamunni/hugnin/service"
+	"github.com/spf13/cobra"
+)
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history <id>",
+	Short: "List the revisions of a note",
+	Long: `List every version of a note, oldest first, with the time it was
+written, its tags and its first line. The last one is the current version.
+
+Use "hugnin diff" to compare revisions and "hugnin revert" to bring one
+back.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := newPresenter(cmd)
+		id, err := parseNoteId(args[0])
+		if err != nil {
+			return err
+		}
+		history, err := service.NewNoteService(noteStore).History(cmd.Context(), id)
+		if err != nil {
+			return err
+		}
+		return out.revisions(history)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+
+	"github.com/iamunni/hugnin/model"
+	"github.com/iamunni/hugnin/service"
+	"github.com/iamunni/hugnin/store"
+	"github.com/spf13/cobra"
+)
+
+var importMerge, importReplace, importYes bool
+
+// importCmd represents the import command
+var importCmd = &cobra.Command{
+	Use:   "import <file|dir>",
+	Short: "Import notes from a JSON export or a directory of Markdown files",
+	Long: `Import the notes of a file written by "hugnin export", or of standard
+input when the file is "-". The import runs in a single transaction, so it
+either adds every note or none.
+
+A directory is read as Markdown: every .md file below it becomes a note,
+as written by "hugnin export --format markdown" or kept in an Obsidian
+vault. Front matter tags and inline #hashtags both become tags.
+
+--merge (the default) adds the notes whose text and tags match no stored
+note, under new Ids. --replace deletes every stored note, the trash and
+revision history included, and keeps the Ids of the file.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := newPresenter(cmd)
+		notes, err := readImport(cmd, args[0])
+		if err != nil {
+			return err
+		}
+
+		mode := store.ImportMerge
+		if importReplace {
+			mode = store.ImportReplace
+			if !importYes {
+				if args[0] == "-" {
+					return errors.New("--replace with notes from standard input needs --yes")
+				}
+				if !confirm(cmd, "Replace every stored note with the notes of "+args[0]+"?") {
+					return nil
+				}
+			}
+		}
+		imported, err := service.NewNoteService(noteStore).Import(cmd.Context(), notes, mode)
+		if err != nil {
+			return err
+		}
+		out.messagef("%d notes imported, %d skipped", imported, int64(len(notes))-imported)
+		return nil
+	},
+}
+
+// readImport reads the notes of a JSON archive, standard input for "-", or
+// a directory of Markdown files.
+func readImport(cmd *cobra.Command, path string) ([]model.Note, error) {
+	if path == "-" {
+		return service.ReadArchive(cmd.InOrStdin())
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return service.ReadMarkdownDir(path)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return service.ReadArchive(file)
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+
+	importCmd.Flags().BoolVar(&importMerge, "merge", false, "Add the notes that are not stored yet (default)")
+	importCmd.Flags().BoolVar(&importReplace, "replace", false, "Delete every stored note first and keep the imported Ids")
+	importCmd.Flags().BoolVarP(&importYes, "yes", "y", false, "Do not ask for confirmation")
+	importCmd.MarkFlagsMutuallyExclusive("merge", "replace")
+}
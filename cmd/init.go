@@ -4,11 +4,8 @@ Copyright © 2023 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
-	"fmt"
-	"log"
-
-	"github.com/iamunni/hugnin/store"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 // initCmd represents the init command
@@ -21,13 +18,9 @@ and usage of using your command. For example:
 Cobra is a CLI library for Go that empowers applications.
 This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("initializing the database")
-		dbFile := "sqlite-database.db"
-		err := store.NewSQLiteStore().Init(dbFile)
-		if err != nil {
-			log.Fatal(err)
-		}
+	RunE: func(cmd *cobra.Command, args []string) error {
+		newPresenter(cmd).messagef("initializing the database")
+		return noteStore.Init(cmd.Context(), viper.GetString("db_path"))
 	},
 }
 
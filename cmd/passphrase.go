@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/iamunni/hugnin/model"
+	"github.com/iamunni/hugnin/service"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// Environment variables that supply passphrases to scripts.
+const (
+	passphraseEnv    = "HUGNIN_PASSPHRASE"
+	newPassphraseEnv = "HUGNIN_NEW_PASSPHRASE"
+)
+
+// readPassphrase returns the passphrase in the env environment variable
+// or prompts for it on the terminal without echoing it. When confirm is
+// set the passphrase is asked twice.
+func readPassphrase(env, prompt string, confirm bool) (string, error) {
+	if passphrase, ok := os.LookupEnv(env); ok {
+		return passphrase, nil
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("no terminal to ask for the passphrase, set %s", env)
+	}
+	passphrase, err := promptPassphrase(fd, prompt+": ")
+	if err != nil {
+		return "", err
+	}
+	if confirm {
+		again, err := promptPassphrase(fd, "Repeat "+prompt+": ")
+		if err != nil {
+			return "", err
+		}
+		if again != passphrase {
+			return "", errors.New("passphrases do not match")
+		}
+	}
+	return passphrase, nil
+}
+
+func promptPassphrase(fd int, prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	passphrase, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	return string(passphrase), err
+}
+
+// newCrypter reads a passphrase as readPassphrase does and returns a
+// Crypter for it.
+func newCrypter(env, prompt string, confirm bool) (*service.Crypter, error) {
+	passphrase, err := readPassphrase(env, prompt, confirm)
+	if err != nil {
+		return nil, err
+	}
+	return service.NewCrypter(passphrase)
+}
+
+var decryptNotes bool
+
+// addDecryptFlag adds --decrypt to a command listing notes.
+func addDecryptFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&decryptNotes, "decrypt", false, "Ask for the passphrase and show encrypted notes decrypted")
+}
+
+// decryptNotesIfAsked decrypts the encrypted notes when --decrypt is set.
+// Notes left encrypted are shown as service.EncryptedPlaceholder.
+func decryptNotesIfAsked(notes []model.Note) ([]model.Note, error) {
+	if !decryptNotes {
+		return notes, nil
+	}
+	crypter, err := newCrypter(passphraseEnv, "Passphrase", false)
+	if err != nil {
+		return nil, err
+	}
+	return crypter.DecryptNotes(notes)
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/iamunni/hugnin/model"
+	"github.com/iamunni/hugnin/render"
+	"github.com/iamunni/hugnin/service"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// presenter writes command results to an io.Writer, keeping the service
+// layer free of any output concerns.
+type presenter struct {
+	out    io.Writer
+	format string
+}
+
+// newPresenter returns a presenter writing to the command's output in the
+// format selected by --output or the output config key.
+func newPresenter(cmd *cobra.Command) *presenter {
+	return &presenter{
+		out:    cmd.OutOrStdout(),
+		format: viper.GetString("output"),
+	}
+}
+
+// notes renders a list of notes, showing the encrypted ones as
+// service.EncryptedPlaceholder.
+func (p *presenter) notes(notes []model.Note) error {
+	renderer, err := render.New(p.format)
+	if err != nil {
+		return err
+	}
+	return renderer.Render(p.out, service.ConcealEncrypted(notes))
+}
+
+// body prints the full text of a note, or renders it like a list of one
+// note when --output selects another format than the table.
+func (p *presenter) body(note model.Note) error {
+	if p.format != "table" {
+		return p.notes([]model.Note{note})
+	}
+	_, err := fmt.Fprintln(p.out, note.Value)
+	return err
+}
+
+// messagef prints a one line status message.
+func (p *presenter) messagef(format string, args ...interface{}) {
+	fmt.Fprintf(p.out, format+"\n", args...)
+}
+
+// revisions lists the versions of a note, one per line, marking the last
+// one as current.
+func (p *presenter) revisions(history []model.Revision) error {
+	tw := tabwriter.NewWriter(p.out, 0, 4, 2, ' ', 0)
+	for i, r := range history {
+		first := render.FirstLine(r.Value)
+		if service.IsEncrypted(r.Value) {
+			first = service.EncryptedPlaceholder
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s", r.Number, r.CreatedAt.Local().Format("2006-01-02 15:04"), strings.Join(r.Tags, ","), first)
+		if i == len(history)-1 {
+			fmt.Fprint(tw, "\t(current)")
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+// tags lists tags, one per line, with their note counts and the day they
+// were last used.
+func (p *presenter) tags(tags []model.Tag) error {
+	tw := tabwriter.NewWriter(p.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TAG\tNOTES\tLAST USED")
+	for _, tag := range tags {
+		lastUsed := "-"
+		if !tag.LastUsed.IsZero() {
+			lastUsed = tag.LastUsed.Local().Format("2006-01-02")
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", tag.Name, tag.Notes, lastUsed)
+	}
+	return tw.Flush()
+}
+
+// savedSearches prints the saved searches with their queries.
+func (p *presenter) savedSearches(searches []model.SavedSearch) error {
+	tw := tabwriter.NewWriter(p.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tQUERY")
+	for _, search := range searches {
+		fmt.Fprintf(tw, "@%s\t%s\n", search.Name, search.Query)
+	}
+	return tw.Flush()
+}
+
+// tagTree prints the hierarchy of tags, each one indented below its
+// parent with the last segment of its name only.
+func (p *presenter) tagTree(nodes []model.TagNode) error {
+	tw := tabwriter.NewWriter(p.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TAG\tNOTES\tLAST USED")
+	var printNodes func(nodes []model.TagNode, depth int)
+	printNodes = func(nodes []model.TagNode, depth int) {
+		for _, node := range nodes {
+			lastUsed := "-"
+			if !node.LastUsed.IsZero() {
+				lastUsed = node.LastUsed.Local().Format("2006-01-02")
+			}
+			name := node.Name[strings.LastIndex(node.Name, "/")+1:]
+			fmt.Fprintf(tw, "%s%s\t%d\t%s\n", strings.Repeat("  ", depth), name, node.Notes, lastUsed)
+			printNodes(node.Children, depth+1)
+		}
+	}
+	printNodes(nodes, 0)
+	return tw.Flush()
+}
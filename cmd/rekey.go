@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"github.com/iamunni/hugnin/service"
+	"github.com/spf13/cobra"
+)
+
+// rekeyCmd represents the rekey command
+var rekeyCmd = &cobra.Command{
+	Use:   "rekey",
+	Short: "Change the passphrase of the encrypted notes",
+	Long: `Re-encrypt every encrypted note, its earlier versions and the trashed
+ones with a new passphrase. The change is made in a single transaction:
+when any note does not decrypt with the current passphrase nothing is
+changed.
+
+The passphrases are asked on the terminal, or read from
+HUGNIN_PASSPHRASE and HUGNIN_NEW_PASSPHRASE.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := newPresenter(cmd)
+		current, err := newCrypter(passphraseEnv, "Current passphrase", false)
+		if err != nil {
+			return err
+		}
+		replacement, err := newCrypter(newPassphraseEnv, "New passphrase", true)
+		if err != nil {
+			return err
+		}
+		rekeyed, err := service.NewNoteService(noteStore).Rekey(cmd.Context(), current, replacement)
+		if err != nil {
+			return err
+		}
+		out.messagef("%d encrypted notes and revisions rekeyed", rekeyed)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rekeyCmd)
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"github.com/iamunni/hugnin/service"
+	"github.com/spf13/cobra"
+)
+
+// revertCmd represents the revert command
+var revertCmd = &cobra.Command{
+	Use:   "revert <id> <rev>",
+	Short: "Restore an earlier revision of a note",
+	Long: `Make an earlier revision of a note its current version again. The
+version it replaces is kept in the history, so a revert can be reverted.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := newPresenter(cmd)
+		id, err := parseNoteId(args[0])
+		if err != nil {
+			return err
+		}
+		number, err := parseRevision(args[1])
+		if err != nil {
+			return err
+		}
+		reverted, err := service.NewNoteService(noteStore).Revert(cmd.Context(), id, number)
+		if err != nil {
+			return err
+		}
+		out.messagef("note %d reverted to revision %d", reverted.Id, number)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(revertCmd)
+}
@@ -4,18 +4,45 @@ Copyright © 2023 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/iamunni/hugnin/model"
+	"github.com/iamunni/hugnin/render"
+	"github.com/iamunni/hugnin/service"
+	"github.com/iamunni/hugnin/store"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var cfgFile string
+var dbFile string
+
+// legacyDBFile is where hugnin kept its database before the location
+// became configurable.
+const legacyDBFile = "sqlite-database.db"
 
 var note model.Note
 
+var dateSince, dateUntil, dateOn string
+
+var outputFormat string
+
+// noteStore is the store shared by every command of the process. It is
+// opened by PersistentPreRunE and closed by PersistentPostRunE.
+var noteStore store.Store
+
+// noteStorePath is the database file or directory noteStore was opened
+// from.
+var noteStorePath string
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "hugnin",
@@ -29,12 +56,46 @@ to quickly create a Cobra application.`,
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if !usesStore(cmd) {
+			return nil
+		}
+		// The arguments were accepted; failures from here on are not usage
+		// errors.
+		cmd.SilenceUsage = true
+		err := openStore(cmd.Context())
+		if err != nil {
+			return err
+		}
+		return purgeExpiredTrash(cmd.Context())
+	},
+	PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
+		if noteStore == nil {
+			return nil
+		}
+		return noteStore.Close()
+	},
+}
+
+// closeStore closes noteStore after a command that failed, which skips
+// PersistentPostRunE, so the files backend lock and the database are
+// released before hugnin exits.
+func closeStore() {
+	if noteStore == nil {
+		return
+	}
+	err := noteStore.Close()
+	if err != nil {
+		log.Println(err)
+	}
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	err := rootCmd.Execute()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	err := rootCmd.ExecuteContext(ctx)
 	if err != nil {
 		os.Exit(1)
 	}
@@ -42,12 +103,18 @@ func Execute() {
 
 func init() {
 	cobra.OnInitialize(initConfig)
+	cobra.OnFinalize(closeStore)
 
 	// Here you will define your flags and configuration settings.
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.hugnin.yaml)")
+	rootCmd.PersistentFlags().StringVar(&dbFile, "db", "", "notes database file, or directory for the files backend (default is $XDG_DATA_HOME/hugnin/notes.db or notes/)")
+	cobra.CheckErr(viper.BindPFlag("db_path", rootCmd.PersistentFlags().Lookup("db")))
+	cobra.CheckErr(viper.BindEnv("db_path", "HUGNIN_DB"))
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "table", "output format: "+strings.Join(render.Formats(), ", ")+" or a Go template such as '{{.Id}} {{.Note}}'")
+	cobra.CheckErr(viper.BindPFlag("output", rootCmd.PersistentFlags().Lookup("output")))
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
@@ -71,9 +138,137 @@ func initConfig() {
 	}
 
 	viper.AutomaticEnv() // read in environment variables that match
+	viper.SetDefault("db_path", defaultDBPath())
+	viper.SetDefault("trash_retention", "30d")
+	viper.SetDefault("backend", "sqlite")
+	viper.SetDefault("git_dir", filepath.Join(filepath.Dir(defaultDBPath()), "git"))
+	viper.SetDefault("git_branch", "main")
 
 	// If a config file is found, read it in.
 	if err := viper.ReadInConfig(); err == nil {
 		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 	}
 }
+
+// defaultDBPath returns $XDG_DATA_HOME/hugnin/notes.db, falling back to
+// ~/.local/share when XDG_DATA_HOME is not set.
+func defaultDBPath() string {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		home, err := os.UserHomeDir()
+		cobra.CheckErr(err)
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dataHome, "hugnin", "notes.db")
+}
+
+// openStore opens the notes database configured through --db, HUGNIN_DB or
+// the db_path config key into noteStore, using the backend config key to
+// pick its kind.
+func openStore(ctx context.Context) error {
+	return openStoreWith(ctx, store.NewSQLiteStore)
+}
+
+// openStoreWith is openStore creating SQLite stores with newSQLiteStore.
+func openStoreWith(ctx context.Context, newSQLiteStore func(path string) store.Store) error {
+	path := viper.GetString("db_path")
+	var s store.Store
+	switch backend := viper.GetString("backend"); backend {
+	case "sqlite":
+		if path == defaultDBPath() {
+			err := checkLegacyDB(path)
+			if err != nil {
+				return err
+			}
+		}
+		s = newSQLiteStore(path)
+	case "files":
+		if path == defaultDBPath() {
+			path = filepath.Join(filepath.Dir(path), "notes")
+		}
+		s = store.NewFileStore(path)
+	default:
+		return fmt.Errorf("unknown backend %q, supported backends: sqlite, files", backend)
+	}
+	err := s.Open(ctx)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", path, err)
+	}
+	noteStore, noteStorePath = s, path
+	return nil
+}
+
+// checkLegacyDB refuses to create a new database at the default path
+// while ./sqlite-database.db from an older hugnin exists, which would
+// leave its notes behind unnoticed, and tells how to move or keep it.
+// When both exist it only points the old one out.
+func checkLegacyDB(path string) error {
+	if _, err := os.Stat(legacyDBFile); err != nil {
+		return nil
+	}
+	if _, err := os.Stat(path); err == nil {
+		fmt.Fprintf(os.Stderr, "found ./%s from an older hugnin; pass --db %s to use it instead of %s\n", legacyDBFile, legacyDBFile, path)
+		return nil
+	}
+	return fmt.Errorf("found ./%s from an older hugnin and no database at %s yet; move it there with\n\n\tmkdir -p %s && mv %s %s\n\nor pass --db %s to keep using it where it is",
+		legacyDBFile, path, filepath.Dir(path), legacyDBFile, path, legacyDBFile)
+}
+
+// purgeExpiredTrash permanently removes the notes that have been in the
+// trash longer than the trash_retention config key allows.
+func purgeExpiredTrash(ctx context.Context) error {
+	cutoff, err := service.ParseRetention(viper.GetString("trash_retention"), time.Now())
+	if err != nil {
+		return err
+	}
+	purged, err := service.NewNoteService(noteStore).PurgeTrash(ctx, cutoff)
+	if err != nil {
+		return err
+	}
+	if purged > 0 {
+		log.Printf("purged %d notes from the trash", purged)
+	}
+	return nil
+}
+
+// usesStore reports whether cmd works on the notes database. Help and
+// shell completion commands run without opening it.
+func usesStore(cmd *cobra.Command) bool {
+	for c := cmd; c != nil; c = c.Parent() {
+		switch c.Name() {
+		case "help", "completion", cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+			return false
+		}
+	}
+	return true
+}
+
+// parseNoteId reads a note Id argument.
+func parseNoteId(arg string) (int64, error) {
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid note id %q", arg)
+	}
+	return id, nil
+}
+
+// parseRevision reads a revision number argument.
+func parseRevision(arg string) (int, error) {
+	number, err := strconv.Atoi(arg)
+	if err != nil || number <= 0 {
+		return 0, fmt.Errorf("invalid revision %q", arg)
+	}
+	return number, nil
+}
+
+// addDateRangeFlags registers the --since, --until and --on filters on c.
+func addDateRangeFlags(c *cobra.Command) {
+	c.Flags().StringVar(&dateSince, "since", "", "Only notes created on or after this date (2024-01-31, 3d, 2w, last-week, ...)")
+	c.Flags().StringVar(&dateUntil, "until", "", "Only notes created up to and including this date")
+	c.Flags().StringVar(&dateOn, "on", "", "Only notes created on this day, week or month")
+}
+
+// dateRange returns the filter selected by the date range flags.
+func dateRange() (model.DateRange, error) {
+	return service.ParseDateRange(dateSince, dateUntil, dateOn, time.Now())
+}
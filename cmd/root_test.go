@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/iamunni/hugnin/model"
+	"github.com/iamunni/hugnin/store"
+)
+
+// chdir moves the test into dir until it ends.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestOpenStore_LegacyDatabase(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_DATA_HOME", home)
+	chdir(t, t.TempDir())
+	if err := os.WriteFile(legacyDBFile, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	rootCmd.SetArgs([]string{"view"})
+	t.Cleanup(func() {
+		rootCmd.SetArgs(nil)
+		resetFlags(t)
+	})
+	err := rootCmd.ExecuteContext(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "mv "+legacyDBFile) {
+		t.Fatalf("view next to a legacy database error = %v, want the command moving it", err)
+	}
+	if _, err := os.Stat(filepath.Join(home, "hugnin", "notes.db")); !os.IsNotExist(err) {
+		t.Errorf("view created a new database next to a legacy one: %v", err)
+	}
+
+	// Chosen explicitly, the legacy database is used.
+	runCommand(t, "--db", legacyDBFile, "view")
+	// Once there is a database at the default path, it is used.
+	if err := os.MkdirAll(filepath.Join(home, "hugnin"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(legacyDBFile, filepath.Join(home, "hugnin", "notes.db")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(legacyDBFile, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	runCommand(t, "view")
+}
+
+func TestExecute_ClosesStoreOnError(t *testing.T) {
+	path := newVersion1DB(t)
+	rootCmd.SetArgs([]string{"--db", path, "show", "42"})
+	rootCmd.SetErr(io.Discard)
+	t.Cleanup(func() {
+		rootCmd.SetArgs(nil)
+		rootCmd.SetErr(nil)
+		resetFlags(t)
+	})
+	err := rootCmd.ExecuteContext(context.Background())
+	if !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("show 42 error = %v, want %v", err, store.ErrNotFound)
+	}
+	if _, err := noteStore.Read(context.Background(), model.Note{}, model.DateRange{}); err == nil {
+		t.Error("the store is still open after a failed command")
+	}
+}
@@ -4,37 +4,149 @@ Copyright © 2023 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
-	"log"
 	"strings"
 
+	"github.com/iamunni/hugnin/model"
 	"github.com/iamunni/hugnin/service"
-	"github.com/iamunni/hugnin/store"
 	"github.com/spf13/cobra"
 )
 
-var keyword string
+var (
+	keyword      string
+	searchFilter string
+)
 
 // searchCmd represents the search command
 var searchCmd = &cobra.Command{
 	Use:   "search",
-	Short: "A brief description of your command",
-	Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
-
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	Short: "Full-text search notes and tags",
+	Long: `Search notes and tags, best matches first.
+
+The query supports prefixes (kube*), phrases ("on call") and the AND, OR
+and NOT operators. Without FTS5 support in the binary, search falls back
+to a case insensitive substring match.
+
+--filter limits the results to the notes matching a query in the
+language of view, such as 'tag:work -tag:archived created:>2w'.
+
+The save, list and delete subcommands keep queries under a name for
+hugnin view @name. To search for one of their names, quote it in the
+query, as in hugnin search '"list"'.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
 		keyword = strings.Join(args, " ")
-		noteService := service.NewNoteService(store.NewSQLiteStore())
+		out := newPresenter(cmd)
+		noteService := service.NewNoteService(noteStore)
+
+		period, err := dateRange()
+		if err != nil {
+			return err
+		}
+		notes, err := noteService.SearchWithin(cmd.Context(), keyword, searchFilter, period)
+		if err != nil {
+			return queryError(searchFilter, err)
+		}
+		notes, err = decryptNotesIfAsked(notes)
+		if err != nil {
+			return err
+		}
+		return out.notes(notes)
+	},
+}
+
+// searchSaveCmd represents the search save command
+var searchSaveCmd = &cobra.Command{
+	Use:   "save <name> <query>",
+	Short: "Save a query to run with view @name",
+	Long: `Save a query in the language of view under a name, replacing the query
+saved under that name if any. Unquoted values written $name are
+parameters, given when the query runs:
 
-		err := noteService.Search(keyword)
+  hugnin search save oncall 'tag:oncall created:this-week -tag:done'
+  hugnin search save bytag 'tag:$tag updated:>$since'
+  hugnin view @bytag tag=db since=2w`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := newPresenter(cmd)
+		query := strings.Join(args[1:], " ")
+		err := service.NewNoteService(noteStore).SaveSearch(cmd.Context(), args[0], query)
 		if err != nil {
-			log.Fatal(err)
+			return queryError(query, err)
 		}
+		out.messagef("search @%s saved", args[0])
+		return nil
 	},
 }
 
+// searchListCmd represents the search list command
+var searchListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the saved searches",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := newPresenter(cmd)
+		searches, err := service.NewNoteService(noteStore).SavedSearches(cmd.Context())
+		if err != nil {
+			return err
+		}
+		return out.savedSearches(searches)
+	},
+}
+
+// searchDeleteCmd represents the search delete command
+var searchDeleteCmd = &cobra.Command{
+	Use:               "delete <name>",
+	Short:             "Delete a saved search",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeSearchNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := newPresenter(cmd)
+		name := strings.TrimPrefix(args[0], "@")
+		err := service.NewNoteService(noteStore).DeleteSavedSearch(cmd.Context(), name)
+		if err != nil {
+			return err
+		}
+		out.messagef("search @%s deleted", name)
+		return nil
+	},
+}
+
+// savedSearches opens the store to read the saved searches for shell
+// completion, which runs without the store the commands get.
+func savedSearches(cmd *cobra.Command) ([]model.SavedSearch, error) {
+	err := openStore(cmd.Context())
+	if err != nil {
+		return nil, err
+	}
+	defer noteStore.Close()
+	return service.NewNoteService(noteStore).SavedSearches(cmd.Context())
+}
+
+// completeSearchNames offers the names of the saved searches, with their
+// queries as descriptions.
+func completeSearchNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	searches, err := savedSearches(cmd)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	var names []string
+	for _, search := range searches {
+		names = append(names, search.Name+"\t"+search.Query)
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
 func init() {
 	rootCmd.AddCommand(searchCmd)
+
+	searchCmd.AddCommand(searchSaveCmd)
+	searchCmd.AddCommand(searchListCmd)
+	searchCmd.AddCommand(searchDeleteCmd)
+
+	addDateRangeFlags(searchCmd)
+	addDecryptFlag(searchCmd)
+
+	searchCmd.Flags().StringVarP(&searchFilter, "filter", "f", "", "Only notes matching this query, see view")
 }
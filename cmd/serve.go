@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/iamunni/hugnin/api"
+	"github.com/iamunni/hugnin/service"
+	"github.com/spf13/cobra"
+)
+
+var serveAddr string
+
+// shutdownTimeout bounds how long serve waits for requests in flight
+// once it is interrupted.
+const shutdownTimeout = 5 * time.Second
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the notes over a JSON HTTP API",
+	Long: `Serve the notes over a JSON HTTP API until interrupted:
+
+  hugnin serve --addr 127.0.0.1:8080
+  curl -H 'Content-Type: application/json' -d '{"note": "restart postgres", "tags": ["db"]}' localhost:8080/notes
+  curl localhost:8080/notes?tag=db
+
+The API has no authentication, so it listens on the loopback interface
+by default. It only answers requests addressed to localhost or to --addr,
+and only takes request bodies sent as application/json, so web pages open
+in a browser cannot use it.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		listener, err := net.Listen("tcp", serveAddr)
+		if err != nil {
+			return err
+		}
+		server := &http.Server{
+			Handler:           api.NewHandler(service.NewNoteService(noteStore), serveAddr),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		// Serve returns as soon as Shutdown starts; wait for the requests in
+		// flight before the store is closed.
+		stopped := make(chan struct{})
+		go func() {
+			defer close(stopped)
+			<-cmd.Context().Done()
+			ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			defer cancel()
+			err := server.Shutdown(ctx)
+			if err != nil {
+				log.Println(err)
+			}
+		}()
+
+		newPresenter(cmd).messagef("listening on http://%s", listener.Addr())
+		err = server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		<-stopped
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().StringVar(&serveAddr, "addr", "127.0.0.1:8080", "Address to listen on")
+}
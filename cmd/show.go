@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/iamunni/hugnin/model"
+	"github.com/iamunni/hugnin/service"
+	"github.com/spf13/cobra"
+)
+
+// showCmd represents the show command
+var showCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Print the full text of a note",
+	Long: `Print the full text of a note, every line of it, where the table of
+view and search only shows the first one. Encrypted notes need --decrypt.
+
+With --output set to another format than table the note is rendered in
+that format instead.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := newPresenter(cmd)
+		id, err := parseNoteId(args[0])
+		if err != nil {
+			return err
+		}
+		shown, err := service.NewNoteService(noteStore).Get(cmd.Context(), id)
+		if err != nil {
+			return err
+		}
+		notes, err := decryptNotesIfAsked([]model.Note{shown})
+		if err != nil {
+			return err
+		}
+		shown = notes[0]
+		if service.IsEncrypted(shown.Value) {
+			return fmt.Errorf("note %d is encrypted, pass --decrypt to show it", shown.Id)
+		}
+		return out.body(shown)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(showCmd)
+
+	addDecryptFlag(showCmd)
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/iamunni/hugnin/service"
+	"github.com/iamunni/hugnin/store"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// syncCmd represents the sync command
+var syncCmd = &cobra.Command{
+	Use:   "sync <other.db|dir>",
+	Short: "Reconcile the notes with another hugnin database",
+	Long: `Merge the notes of this database and another one in both directions,
+so that both end up with the same notes. A directory is opened with the
+files backend and anything else as a SQLite database, which is created
+when missing.
+
+Notes are matched by a UUID that stays the same in every database they
+are synced to. A note edited on one side only takes the newer version.
+A note edited on both sides since the last sync is kept in both versions,
+each tagged "conflict"; edit or delete one of them and remove the tag.
+Trashing and restoring move across too, and notes purged from the trash
+on one side are removed from the other unless they were edited there
+afterwards.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := newPresenter(cmd)
+		other, err := openSyncPeer(cmd.Context(), args[0])
+		if err != nil {
+			return err
+		}
+		defer other.Close()
+		report, err := service.NewNoteService(noteStore).Sync(cmd.Context(), other)
+		if err != nil {
+			return err
+		}
+		out.syncReport(report)
+		return nil
+	},
+}
+
+// syncGitCmd represents the sync git command
+var syncGitCmd = &cobra.Command{
+	Use:   "git",
+	Short: "Sync the notes through a git repository",
+	Long: `Write the notes to a git working tree as one Markdown file per note,
+commit them, merge the branch of the git_remote config key (or --remote)
+and read the merged notes back, then push. Any repository git can reach
+works as a remote, such as a bare repository made with
+"git init --bare" on a USB stick or a server.
+
+The working tree is the git_dir config key, $XDG_DATA_HOME/hugnin/git by
+default, and keeps the whole history of the notes. Notes edited on both
+sides are settled as by "hugnin sync". Without a remote the notes are
+only committed.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := newPresenter(cmd)
+		repo := service.GitRepo{
+			Dir:    viper.GetString("git_dir"),
+			Remote: viper.GetString("git_remote"),
+			Branch: viper.GetString("git_branch"),
+		}
+		report, err := service.NewNoteService(noteStore).SyncGit(cmd.Context(), repo)
+		if err != nil {
+			return err
+		}
+		out.syncReport(report)
+		return nil
+	},
+}
+
+// openSyncPeer opens the store at path, refusing the one hugnin already
+// works on.
+func openSyncPeer(ctx context.Context, path string) (store.Store, error) {
+	self, err := filepath.Abs(noteStorePath)
+	if err != nil {
+		return nil, err
+	}
+	peer, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if self == peer {
+		return nil, fmt.Errorf("%s is the database hugnin is using, sync needs another one", path)
+	}
+
+	var s store.Store
+	info, err := os.Stat(path)
+	if err == nil && info.IsDir() {
+		s = store.NewFileStore(path)
+	} else {
+		s = store.NewSQLiteStore(path)
+	}
+	err = s.Open(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// syncReport prints what a sync pulled and pushed.
+func (p *presenter) syncReport(report service.SyncReport) {
+	p.messagef("pulled: %s", report.Pulled)
+	p.messagef("pushed: %s", report.Pushed)
+	if report.Conflicts > 0 {
+		p.messagef("conflicts: %d, both versions kept and tagged %q", report.Conflicts, service.ConflictTag)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+	syncCmd.AddCommand(syncGitCmd)
+
+	syncGitCmd.Flags().String("remote", "", "Repository to merge from and push to (default is the git_remote config key)")
+	cobra.CheckErr(viper.BindPFlag("git_remote", syncGitCmd.Flags().Lookup("remote")))
+	syncGitCmd.Flags().String("dir", "", "Working tree the notes are committed to (default is $XDG_DATA_HOME/hugnin/git)")
+	cobra.CheckErr(viper.BindPFlag("git_dir", syncGitCmd.Flags().Lookup("dir")))
+	syncGitCmd.Flags().String("branch", "", "Branch to sync (default is main)")
+	cobra.CheckErr(viper.BindPFlag("git_branch", syncGitCmd.Flags().Lookup("branch")))
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"github.com/iamunni/hugnin/service"
+	"github.com/spf13/cobra"
+)
+
+var (
+	tagsMergeInto string
+	tagsTree      bool
+)
+
+// tagsCmd represents the tags command
+var tagsCmd = &cobra.Command{
+	Use:   "tags",
+	Short: "List the tags with their note counts",
+	Long: `List every tag with the number of notes outside the trash carrying it
+and when one of them was last updated. Tags are a hierarchy split on
+"/": with --tree, each tag is listed under its parent and counts the
+notes of the tags below it too. The subcommands tidy them up:
+
+  hugnin tags rename k8s kubernetes
+  hugnin tags merge k8s K8S --into kubernetes
+  hugnin tags prune
+
+Renaming and merging change the notes in a single transaction and keep
+their previous tags in the history.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := newPresenter(cmd)
+		noteService := service.NewNoteService(noteStore)
+		if tagsTree {
+			nodes, err := noteService.TagTree(cmd.Context())
+			if err != nil {
+				return err
+			}
+			return out.tagTree(nodes)
+		}
+		tags, err := noteService.Tags(cmd.Context())
+		if err != nil {
+			return err
+		}
+		return out.tags(tags)
+	},
+}
+
+// tagsRenameCmd represents the tags rename command
+var tagsRenameCmd = &cobra.Command{
+	Use:   "rename <old> <new>",
+	Short: "Rename a tag and the tags below it",
+	Long: `Rename a tag and the tags below it on every note carrying them, so
+renaming work to job also renames work/oncall to job/oncall.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := newPresenter(cmd)
+		renamed, err := service.NewNoteService(noteStore).RenameTag(cmd.Context(), args[0], args[1])
+		if err != nil {
+			return err
+		}
+		out.messagef("tag %q renamed to %q on %d notes", args[0], args[1], renamed)
+		return nil
+	},
+}
+
+// tagsMergeCmd represents the tags merge command
+var tagsMergeCmd = &cobra.Command{
+	Use:   "merge <tag>... --into <tag>",
+	Short: "Replace several tags with one",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := newPresenter(cmd)
+		merged, err := service.NewNoteService(noteStore).MergeTags(cmd.Context(), args, tagsMergeInto)
+		if err != nil {
+			return err
+		}
+		out.messagef("%d notes now tagged %q", merged, tagsMergeInto)
+		return nil
+	},
+}
+
+// tagsPruneCmd represents the tags prune command
+var tagsPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove the tags no note carries",
+	Long: `Remove the tags no note carries. Tags of notes in the trash are kept,
+so restoring a note brings its tags back.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := newPresenter(cmd)
+		pruned, err := service.NewNoteService(noteStore).PruneTags(cmd.Context())
+		if err != nil {
+			return err
+		}
+		out.messagef("%d unused tags removed", pruned)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(tagsCmd)
+
+	tagsCmd.AddCommand(tagsRenameCmd)
+	tagsCmd.AddCommand(tagsMergeCmd)
+	tagsCmd.AddCommand(tagsPruneCmd)
+
+	tagsCmd.Flags().BoolVar(&tagsTree, "tree", false, "List the tags as a hierarchy with rolled-up counts")
+	tagsMergeCmd.Flags().StringVar(&tagsMergeInto, "into", "", "Tag that replaces the merged ones")
+	cobra.CheckErr(tagsMergeCmd.MarkFlagRequired("into"))
+}
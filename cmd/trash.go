@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+
+	"github.com/iamunni/hugnin/service"
+	"github.com/spf13/cobra"
+)
+
+var trashEmptyYes bool
+
+// trashCmd groups the trash commands
+var trashCmd = &cobra.Command{
+	Use:   "trash",
+	Short: "List, restore or empty deleted notes",
+	Long: `Deleted notes are kept in the trash until they are restored, the trash
+is emptied, or they have been there longer than the trash_retention config
+key allows (30d by default, "never" keeps them forever). Expired notes are
+purged the next time hugnin opens the database.`,
+}
+
+// trashListCmd represents the trash list command
+var trashListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the notes in the trash, most recently deleted first",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := newPresenter(cmd)
+		notes, err := service.NewNoteService(noteStore).Trash(cmd.Context())
+		if err != nil {
+			return err
+		}
+		return out.notes(notes)
+	},
+}
+
+// trashRestoreCmd represents the trash restore command
+var trashRestoreCmd = &cobra.Command{
+	Use:   "restore <id>",
+	Short: "Move a note out of the trash",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := newPresenter(cmd)
+		id, err := parseNoteId(args[0])
+		if err != nil {
+			return err
+		}
+		restored, err := service.NewNoteService(noteStore).Restore(cmd.Context(), id)
+		if err != nil {
+			return err
+		}
+		out.messagef("note %d restored", restored.Id)
+		return nil
+	},
+}
+
+// trashEmptyCmd represents the trash empty command
+var trashEmptyCmd = &cobra.Command{
+	Use:   "empty",
+	Short: "Permanently delete every note in the trash",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := newPresenter(cmd)
+		noteService := service.NewNoteService(noteStore)
+		if !trashEmptyYes {
+			trashed, err := noteService.Trash(cmd.Context())
+			if err != nil {
+				return err
+			}
+			if len(trashed) == 0 {
+				out.messagef("the trash is empty")
+				return nil
+			}
+			if !confirm(cmd, fmt.Sprintf("Permanently delete %d notes?", len(trashed))) {
+				return nil
+			}
+		}
+		purged, err := noteService.EmptyTrash(cmd.Context())
+		if err != nil {
+			return err
+		}
+		out.messagef("%d notes permanently deleted", purged)
+		return nil
+	},
+}
+
+// confirm asks question on the command's output and reports whether the
+// answer read from its input is yes.
+func confirm(cmd *cobra.Command, question string) bool {
+	fmt.Fprintf(cmd.OutOrStdout(), "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+func init() {
+	rootCmd.AddCommand(trashCmd)
+
+	trashCmd.AddCommand(trashListCmd)
+	trashCmd.AddCommand(trashRestoreCmd)
+	trashCmd.AddCommand(trashEmptyCmd)
+
+	trashEmptyCmd.Flags().BoolVarP(&trashEmptyYes, "yes", "y", false, "Do not ask for confirmation")
+}
@@ -4,35 +4,154 @@ Copyright © 2023 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"strings"
 
+	"github.com/iamunni/hugnin/filter"
+	"github.com/iamunni/hugnin/model"
 	"github.com/iamunni/hugnin/service"
-	"github.com/iamunni/hugnin/store"
 	"github.com/spf13/cobra"
 )
 
+var viewExact bool
+
 // viewCmd represents the view command
 var viewCmd = &cobra.Command{
-	Use:   "view",
-	Short: "A brief description of your command",
-	Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
-
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		noteService := service.NewNoteService(store.NewSQLiteStore())
-		err := noteService.View(note)
+	Use:   "view [query | @name [param=value]...]",
+	Short: "List notes, optionally filtered",
+	Long: `List the notes outside the trash, oldest first. --note, --tags and
+--exact filter them, or a query does:
+
+  hugnin view 'tag:work AND (tag:work/db OR "postgres") -tag:archived created:>2024-01-01'
+
+Bare words and quoted phrases match the text of a note, ignoring case,
+tag:name the notes carrying the tag or a tag below it, and created: and
+updated: compare a date with <, <=, >, >= or, on their own, name a day,
+week or month. Terms next to each other are ANDed; AND, OR, NOT (in
+capitals), a leading "-" and parentheses combine them.
+
+@name runs the query saved under name with hugnin search save, followed
+by the values of its parameters:
+
+  hugnin view @bytag tag=db`,
+	ValidArgsFunction: completeView,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := newPresenter(cmd)
+		noteService := service.NewNoteService(noteStore)
+		period, err := dateRange()
 		if err != nil {
-			log.Fatal(err)
+			return err
+		}
+		var notes []model.Note
+		if len(args) > 0 {
+			if note.Value != "" || len(note.Tags) > 0 || viewExact {
+				return errors.New("a query cannot be combined with --note, --tags or --exact, use tag: in the query")
+			}
+			query, params := strings.Join(args, " "), map[string]string(nil)
+			if name, ok := strings.CutPrefix(args[0], "@"); ok {
+				search, err := noteService.SavedSearch(cmd.Context(), name)
+				if err != nil {
+					return err
+				}
+				query = search.Query
+				params, err = parseParams(args[1:])
+				if err != nil {
+					return err
+				}
+			}
+			notes, err = noteService.FindParams(cmd.Context(), query, params, period)
+			if err != nil {
+				return queryError(query, err)
+			}
+		} else {
+			view := noteService.View
+			if viewExact {
+				view = noteService.ViewExact
+			}
+			notes, err = view(cmd.Context(), note, period)
+			if err != nil {
+				return err
+			}
 		}
+		notes, err = decryptNotesIfAsked(notes)
+		if err != nil {
+			return err
+		}
+		return out.notes(notes)
 	},
 }
 
+// parseParams reads the name=value parameters of a saved search.
+func parseParams(args []string) (map[string]string, error) {
+	params := make(map[string]string, len(args))
+	for _, arg := range args {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid parameter %q, want name=value", arg)
+		}
+		params[name] = value
+	}
+	return params, nil
+}
+
+// completeView offers the saved searches as @name and then the
+// parameters of the chosen one as name=.
+func completeView(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	offerNames := len(args) == 0 && (toComplete == "" || strings.HasPrefix(toComplete, "@"))
+	offerParams := len(args) > 0 && strings.HasPrefix(args[0], "@") && !strings.Contains(toComplete, "=")
+	if !offerNames && !offerParams {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	searches, err := savedSearches(cmd)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	var completions []string
+	for _, search := range searches {
+		if offerNames {
+			completions = append(completions, "@"+search.Name+"\t"+search.Query)
+			continue
+		}
+		if "@"+search.Name != args[0] {
+			continue
+		}
+		names, err := filter.Params(search.Query)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+		given := map[string]bool{}
+		for _, arg := range args[1:] {
+			name, _, _ := strings.Cut(arg, "=")
+			given[name] = true
+		}
+		for _, name := range names {
+			if !given[name] {
+				completions = append(completions, name+"=")
+			}
+		}
+		return completions, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// queryError returns err, pointing at the column a malformed query went
+// wrong at.
+func queryError(query string, err error) error {
+	var queryErr *filter.Error
+	if errors.As(err, &queryErr) {
+		return fmt.Errorf("%w\n\t%s\n\t%s^", err, query, strings.Repeat(" ", queryErr.Column-1))
+	}
+	return err
+}
+
 func init() {
 	rootCmd.AddCommand(viewCmd)
 
+	addDateRangeFlags(viewCmd)
+	addDecryptFlag(viewCmd)
+
 	viewCmd.PersistentFlags().StringVarP(&note.Value, "note", "n", "", "Search by note")
-	viewCmd.PersistentFlags().StringVarP(&note.Tag, "tags", "t", "", "Search by tags")
+	viewCmd.PersistentFlags().StringSliceVarP(&note.Tags, "tags", "t", nil, "Search by tags, including the tags below them")
+	viewCmd.PersistentFlags().BoolVar(&viewExact, "exact", false, "Match the tags exactly, leaving out the tags below them")
 }
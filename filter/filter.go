@@ -0,0 +1,111 @@
+// Package filter parses the query language of view and search into an
+// AST, which the stores compile to their own form:
+//
+//	tag:work AND (tag:db OR "postgres") -tag:archived created:>2024-01-01
+//
+// Bare words and quoted phrases match the text of a note, tag:name the
+// notes carrying the tag or a tag below it, and created: and updated:
+// compare a date with =, <, <=, > or >= (":" alone means =). Terms next to
+// each other are ANDed; AND, OR and NOT are written in capitals, "-"
+// negates the term it prefixes and parentheses group. NOT binds tighter
+// than AND, which binds tighter than OR.
+//
+// A query may take parameters, unquoted values such as $tag in tag:$tag,
+// which are given when it is parsed with ParseParams.
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Expr is a node of a parsed query.
+type Expr interface {
+	// String returns the node in the query language, fully parenthesised.
+	String() string
+	expr()
+}
+
+// And matches the notes both Left and Right match.
+type And struct {
+	Left, Right Expr
+}
+
+// Or matches the notes Left or Right match.
+type Or struct {
+	Left, Right Expr
+}
+
+// Not matches the notes Expr does not match.
+type Not struct {
+	Expr Expr
+}
+
+// Text matches the notes whose text contains Value, ignoring the case of
+// ASCII letters.
+type Text struct {
+	Value string
+}
+
+// Tag matches the notes carrying Name or a tag below it, as "work/oncall"
+// is below "work".
+type Tag struct {
+	Name string
+}
+
+// The fields a Date compares.
+const (
+	Created = "created"
+	Updated = "updated"
+)
+
+// Date matches the notes whose Field time lies in [Since, Until). A zero
+// bound leaves that side open.
+type Date struct {
+	Field        string
+	Since, Until time.Time
+}
+
+func (And) expr()  {}
+func (Or) expr()   {}
+func (Not) expr()  {}
+func (Text) expr() {}
+func (Tag) expr()  {}
+func (Date) expr() {}
+
+func (e And) String() string { return "(" + e.Left.String() + " AND " + e.Right.String() + ")" }
+func (e Or) String() string  { return "(" + e.Left.String() + " OR " + e.Right.String() + ")" }
+func (e Not) String() string { return "NOT " + e.Expr.String() }
+func (e Text) String() string {
+	return strconv.Quote(e.Value)
+}
+func (e Tag) String() string {
+	return "tag:" + strconv.Quote(e.Name)
+}
+
+func (e Date) String() string {
+	var conds []string
+	if !e.Since.IsZero() {
+		conds = append(conds, e.Field+":>="+e.Since.Format(time.RFC3339))
+	}
+	if !e.Until.IsZero() {
+		conds = append(conds, e.Field+":<"+e.Until.Format(time.RFC3339))
+	}
+	if len(conds) == 1 {
+		return conds[0]
+	}
+	return "(" + strings.Join(conds, " AND ") + ")"
+}
+
+// Error is a malformed query. Column counts the characters of the query
+// from 1, and points past its end when more was expected.
+type Error struct {
+	Column int
+	Msg    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("invalid query at column %d: %s", e.Column, e.Msg)
+}
@@ -0,0 +1,418 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// DateSpan resolves the value of a created: or updated: term to the
+// half-open span [start, end) it names, or to an instant with start ==
+// end.
+type DateSpan func(value string) (start, end time.Time, err error)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokTerm
+	tokAnd
+	tokOr
+	tokNot
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind   tokenKind
+	column int
+	text   string // as written, for error messages
+	field  string // of a term, "" for text
+	value  string // of a term, unquoted
+	quoted bool
+}
+
+// Parse parses a query. dates resolves the dates of created: and updated:
+// terms; when it is nil, dates are YYYY-MM-DD days in the local time zone.
+// An empty query parses to a nil Expr, which matches every note. A
+// malformed query returns an *Error.
+func Parse(input string, dates DateSpan) (Expr, error) {
+	return ParseParams(input, dates, nil)
+}
+
+// ParseParams is Parse for a query with parameters: an unquoted value
+// $name, as in tag:$name or created:>$name, stands for params[name]. A
+// parameter missing from params is an *Error.
+func ParseParams(input string, dates DateSpan, params map[string]string) (Expr, error) {
+	if dates == nil {
+		dates = localDay
+	}
+	p := &parser{dates: dates, params: params}
+	return p.parse(input)
+}
+
+// Params checks the syntax of a query and returns the names of its
+// parameters, in the order they first appear. Dates are not checked, as
+// they may be parameters.
+func Params(input string) ([]string, error) {
+	p := &parser{collect: true}
+	_, err := p.parse(input)
+	if err != nil {
+		return nil, err
+	}
+	return p.names, nil
+}
+
+func (p *parser) parse(input string) (Expr, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	p.tokens = tokens
+	if p.peek().kind == tokEOF {
+		return nil, nil
+	}
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	// or stops at a token that cannot follow a term, which is ")" here.
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, &Error{Column: tok.column, Msg: `unexpected ")" with no "(" before it`}
+	}
+	return e, nil
+}
+
+// localDay reads a YYYY-MM-DD day in the local time zone.
+func localDay(value string) (time.Time, time.Time, error) {
+	day, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid date %q: use YYYY-MM-DD", value)
+	}
+	return day, day.AddDate(0, 0, 1), nil
+}
+
+// lex splits input into tokens, ending with a tokEOF one past the end.
+func lex(input string) ([]token, error) {
+	runes := []rune(input)
+	var tokens []token
+	i := 0
+	for {
+		for i < len(runes) && unicode.IsSpace(runes[i]) {
+			i++
+		}
+		if i == len(runes) {
+			return append(tokens, token{kind: tokEOF, column: i + 1}), nil
+		}
+		start := i
+		switch r := runes[i]; {
+		case r == '(':
+			tokens = append(tokens, token{kind: tokLParen, column: start + 1, text: "("})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokRParen, column: start + 1, text: ")"})
+			i++
+		case r == '-' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) && runes[i+1] != ')':
+			tokens = append(tokens, token{kind: tokNot, column: start + 1, text: "-"})
+			i++
+		case r == '"':
+			value, end, err := lexQuoted(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+			tokens = append(tokens, token{kind: tokTerm, column: start + 1, text: string(runes[start:i]), value: value, quoted: true})
+		default:
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune(`()"`, runes[i]) {
+				i++
+			}
+			word := string(runes[start:i])
+			tok := token{kind: tokTerm, column: start + 1, text: word, value: word}
+			switch word {
+			case "AND":
+				tok.kind = tokAnd
+			case "OR":
+				tok.kind = tokOr
+			case "NOT":
+				tok.kind = tokNot
+			default:
+				field, value, ok := strings.Cut(word, ":")
+				if !ok {
+					break
+				}
+				tok.field, tok.value = strings.ToLower(field), value
+				// field:"a phrase"
+				if value == "" && i < len(runes) && runes[i] == '"' {
+					var err error
+					tok.value, i, err = lexQuoted(runes, i)
+					if err != nil {
+						return nil, err
+					}
+					tok.text = string(runes[start:i])
+					tok.quoted = true
+				}
+			}
+			if tok.kind == tokTerm && tok.value == "-" && tok.field == "" {
+				return nil, &Error{Column: tok.column, Msg: `expected a term after "-"`}
+			}
+			tokens = append(tokens, tok)
+		}
+	}
+}
+
+// lexQuoted reads the phrase whose opening quote is at runes[i] and
+// returns it with the index past its closing quote. A backslash escapes
+// the next character.
+func lexQuoted(runes []rune, i int) (string, int, error) {
+	var sb strings.Builder
+	for j := i + 1; j < len(runes); j++ {
+		switch runes[j] {
+		case '\\':
+			if j+1 < len(runes) {
+				j++
+				sb.WriteRune(runes[j])
+			}
+		case '"':
+			return sb.String(), j + 1, nil
+		default:
+			sb.WriteRune(runes[j])
+		}
+	}
+	return "", 0, &Error{Column: i + 1, Msg: "unterminated quote"}
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	dates  DateSpan
+	params map[string]string
+	// collect makes the parser record the names of the parameters in
+	// names instead of binding them, for Params.
+	collect bool
+	names   []string
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// or reads AND expressions separated by OR.
+func (p *parser) or() (Expr, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOr {
+		op := p.next()
+		right, err := p.unary(op)
+		if err != nil {
+			return nil, err
+		}
+		right, err = p.andFrom(right)
+		if err != nil {
+			return nil, err
+		}
+		left = Or{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+// and reads unary expressions separated by AND or by nothing.
+func (p *parser) and() (Expr, error) {
+	left, err := p.unary(token{})
+	if err != nil {
+		return nil, err
+	}
+	return p.andFrom(left)
+}
+
+func (p *parser) andFrom(left Expr) (Expr, error) {
+	for {
+		var op token
+		switch p.peek().kind {
+		case tokAnd:
+			op = p.next()
+		case tokTerm, tokNot, tokLParen:
+		default:
+			return left, nil
+		}
+		right, err := p.unary(op)
+		if err != nil {
+			return nil, err
+		}
+		left = And{Left: left, Right: right}
+	}
+}
+
+// unary reads an expression with any number of NOT or "-" in front. after
+// is the token before it, for error messages, or the zero token at the
+// start of the query.
+func (p *parser) unary(after token) (Expr, error) {
+	if p.peek().kind == tokNot {
+		op := p.next()
+		e, err := p.unary(op)
+		if err != nil {
+			return nil, err
+		}
+		return Not{Expr: e}, nil
+	}
+	return p.primary(after)
+}
+
+func (p *parser) primary(after token) (Expr, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokTerm:
+		return p.term(tok)
+	case tokLParen:
+		if p.peek().kind == tokRParen {
+			return nil, &Error{Column: p.peek().column, Msg: "empty parentheses"}
+		}
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, &Error{Column: closing.column, Msg: fmt.Sprintf(`missing ")" to close the "(" at column %d`, tok.column)}
+		}
+		return e, nil
+	case tokEOF:
+		if after.text == "" {
+			return nil, &Error{Column: tok.column, Msg: "unexpected end of query"}
+		}
+		return nil, &Error{Column: tok.column, Msg: fmt.Sprintf("expected a term after %s", after.text)}
+	case tokRParen:
+		if after.text != "" {
+			return nil, &Error{Column: tok.column, Msg: fmt.Sprintf(`expected a term after %s, found ")"`, after.text)}
+		}
+		return nil, &Error{Column: tok.column, Msg: `unexpected ")" with no "(" before it`}
+	default:
+		if after.text != "" {
+			return nil, &Error{Column: tok.column, Msg: fmt.Sprintf("expected a term after %s, found %s", after.text, tok.text)}
+		}
+		return nil, &Error{Column: tok.column, Msg: fmt.Sprintf("expected a term before %s", tok.text)}
+	}
+}
+
+// term builds the leaf node of a term token.
+func (p *parser) term(tok token) (Expr, error) {
+	if tok.field == "" || tok.field == "tag" {
+		value, ok, err := p.bind(tok, tok.value)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return Text{Value: tok.value}, nil
+		}
+		tok.value = value
+	}
+	switch tok.field {
+	case "":
+		if tok.value == "" {
+			return nil, &Error{Column: tok.column, Msg: "empty phrase"}
+		}
+		return Text{Value: tok.value}, nil
+	case "tag":
+		name := strings.Trim(strings.TrimSpace(tok.value), "/")
+		if name == "" {
+			return nil, &Error{Column: tok.column, Msg: "tag: needs a tag name"}
+		}
+		return Tag{Name: name}, nil
+	case Created, Updated:
+		return p.date(tok)
+	default:
+		return nil, &Error{Column: tok.column, Msg: fmt.Sprintf(`unknown field %q, use tag:, created: or updated:, or quote the text to search for it`, tok.field+":")}
+	}
+}
+
+// date builds the Date of a created: or updated: term, whose value may
+// start with a comparison.
+func (p *parser) date(tok token) (Expr, error) {
+	op, value := "=", tok.value
+	if !tok.quoted {
+		for _, prefix := range []string{">=", "<=", ">", "<", "="} {
+			if rest, ok := strings.CutPrefix(value, prefix); ok {
+				op, value = prefix, rest
+				break
+			}
+		}
+	}
+	value, ok, err := p.bind(tok, value)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || p.collect {
+		return Date{Field: tok.field}, nil
+	}
+	if value == "" {
+		return nil, &Error{Column: tok.column, Msg: fmt.Sprintf("%s: needs a date", tok.field)}
+	}
+	start, end, err := p.dates(value)
+	if err != nil {
+		return nil, &Error{Column: tok.column, Msg: err.Error()}
+	}
+	d := Date{Field: tok.field}
+	switch op {
+	case "=":
+		if start.Equal(end) {
+			return nil, &Error{Column: tok.column, Msg: fmt.Sprintf("%s:%s names an instant, compare it with < or > instead", tok.field, value)}
+		}
+		d.Since, d.Until = start, end
+	case ">":
+		d.Since = end
+	case ">=":
+		d.Since = start
+	case "<":
+		d.Until = start
+	case "<=":
+		d.Until = end
+	}
+	return d, nil
+}
+
+// bind returns value, or the value of the parameter it names when tok is
+// not quoted. ok is false when the parser collects parameters instead.
+func (p *parser) bind(tok token, value string) (string, bool, error) {
+	name, isParam := paramName(value)
+	if tok.quoted || !isParam {
+		return value, true, nil
+	}
+	if p.collect {
+		for _, seen := range p.names {
+			if seen == name {
+				return "", false, nil
+			}
+		}
+		p.names = append(p.names, name)
+		return "", false, nil
+	}
+	bound, ok := p.params[name]
+	if !ok {
+		return "", false, &Error{Column: tok.column, Msg: fmt.Sprintf("no value for the parameter $%s, pass %s=<value>", name, name)}
+	}
+	return bound, true, nil
+}
+
+// paramName returns the name of the parameter value stands for: value is
+// "$" and a name made of letters, digits and "_", starting with a letter.
+func paramName(value string) (string, bool) {
+	name, ok := strings.CutPrefix(value, "$")
+	if !ok || name == "" {
+		return "", false
+	}
+	for i, r := range name {
+		if !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r) && r != '_') {
+			return "", false
+		}
+	}
+	return name, true
+}
@@ -0,0 +1,179 @@
+package filter
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// utcDay reads YYYY-MM-DD days in UTC and "3d" as an instant, so the
+// tests do not depend on the local time zone.
+func utcDay(value string) (time.Time, time.Time, error) {
+	if value == "3d" {
+		t := time.Date(2024, 1, 7, 12, 0, 0, 0, time.UTC)
+		return t, t, nil
+	}
+	day, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("invalid date")
+	}
+	return day, day.AddDate(0, 0, 1), nil
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "empty",
+			input: "  ",
+			want:  "<nil>",
+		},
+		{
+			name:  "word",
+			input: "postgres",
+			want:  `"postgres"`,
+		},
+		{
+			name:  "terms next to each other are ANDed",
+			input: `tag:work "on call" failover`,
+			want:  `((tag:"work" AND "on call") AND "failover")`,
+		},
+		{
+			name:  "the example",
+			input: `tag:work AND (tag:db OR "postgres") -tag:archived created:>2024-01-01`,
+			want:  `(((tag:"work" AND (tag:"db" OR "postgres")) AND NOT tag:"archived") AND created:>=2024-01-02T00:00:00Z)`,
+		},
+		{
+			name:  "AND binds tighter than OR",
+			input: "a OR b c OR NOT d",
+			want:  `(("a" OR ("b" AND "c")) OR NOT "d")`,
+		},
+		{
+			name:  "keywords are capitals",
+			input: "salt and pepper",
+			want:  `(("salt" AND "and") AND "pepper")`,
+		},
+		{
+			name:  "hyphens inside a word",
+			input: "-tag:work/on-call ad-hoc",
+			want:  `(NOT tag:"work/on-call" AND "ad-hoc")`,
+		},
+		{
+			name:  "quoted values and escapes",
+			input: `tag:"my tag" "say \"hi\"" "AND"`,
+			want:  `((tag:"my tag" AND "say \"hi\"") AND "AND")`,
+		},
+		{
+			name:  "dates",
+			input: "created:2024-01-01 updated:<=2024-01-01 UPDATED:<2024-01-01 Created:>3d",
+			want:  `((((created:>=2024-01-01T00:00:00Z AND created:<2024-01-02T00:00:00Z) AND updated:<2024-01-02T00:00:00Z) AND updated:<2024-01-01T00:00:00Z) AND created:>=2024-01-07T12:00:00Z)`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := Parse(tt.input, utcDay)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.input, err)
+			}
+			got := "<nil>"
+			if e != nil {
+				got = e.String()
+			}
+			if got != tt.want {
+				t.Errorf("Parse(%q) = %s, want %s", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse_Tree(t *testing.T) {
+	got, err := Parse("tag:db/ -x", utcDay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := And{Left: Tag{Name: "db"}, Right: Not{Expr: Text{Value: "x"}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %#v, want %#v", got, want)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`tag:work AND`, "invalid query at column 13: expected a term after AND"},
+		{`OR tag:work`, "invalid query at column 1: expected a term before OR"},
+		{`a AND OR b`, "invalid query at column 7: expected a term after AND, found OR"},
+		{`(tag:db OR x`, `invalid query at column 13: missing ")" to close the "(" at column 1`},
+		{`tag:db)`, `invalid query at column 7: unexpected ")" with no "(" before it`},
+		{`a ()`, "invalid query at column 4: empty parentheses"},
+		{`NOT )`, `invalid query at column 5: expected a term after NOT, found ")"`},
+		{`a - b`, `invalid query at column 3: expected a term after "-"`},
+		{`"on call`, "invalid query at column 1: unterminated quote"},
+		{`x tag:"on call`, "invalid query at column 7: unterminated quote"},
+		{`""`, "invalid query at column 1: empty phrase"},
+		{`tag:`, "invalid query at column 1: tag: needs a tag name"},
+		{`créé:x`, `invalid query at column 1: unknown field "créé:", use tag:, created: or updated:, or quote the text to search for it`},
+		{`é created:>`, "invalid query at column 3: created: needs a date"},
+		{`updated:2024-13-01`, "invalid query at column 1: invalid date"},
+		{`created:3d`, "invalid query at column 1: created:3d names an instant, compare it with < or > instead"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := Parse(tt.input, utcDay)
+			var perr *Error
+			if !errors.As(err, &perr) {
+				t.Fatalf("Parse(%q) error = %v, want an *Error", tt.input, err)
+			}
+			if err.Error() != tt.want {
+				t.Errorf("Parse(%q) error = %q, want %q", tt.input, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse_LocalDays(t *testing.T) {
+	got, err := Parse("created:<2024-01-31", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Date{Field: Created, Until: time.Date(2024, 1, 31, 0, 0, 0, 0, time.Local)}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %v, want %v", got, want)
+	}
+}
+
+func TestParseParams(t *testing.T) {
+	query := `tag:$tag ($tag OR "$tag" OR $2x) created:>=$since tag:$tag`
+	names, err := Params(query)
+	if err != nil {
+		t.Fatalf("Params() error = %v", err)
+	}
+	if want := []string{"tag", "since"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Params() = %v, want %v", names, want)
+	}
+
+	got, err := ParseParams(query, utcDay, map[string]string{"tag": "db", "since": "2024-01-01"})
+	if err != nil {
+		t.Fatalf("ParseParams() error = %v", err)
+	}
+	want := `(((tag:"db" AND (("db" OR "$tag") OR "$2x")) AND created:>=2024-01-01T00:00:00Z) AND tag:"db")`
+	if got.String() != want {
+		t.Errorf("ParseParams() = %s, want %s", got, want)
+	}
+
+	_, err = ParseParams(query, utcDay, map[string]string{"tag": "db"})
+	if want := "invalid query at column 34: no value for the parameter $since, pass since=<value>"; err == nil || err.Error() != want {
+		t.Errorf("ParseParams() error = %v, want %q", err, want)
+	}
+	if names, err := Params("tag:x created:>3d updated:$when"); err != nil || !reflect.DeepEqual(names, []string{"when"}) {
+		t.Errorf("Params() = %v, %v, want when", names, err)
+	}
+	if _, err := Params("tag:$tag AND"); err == nil {
+		t.Errorf("Params() of a malformed query error = nil")
+	}
+}
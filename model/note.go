@@ -1,7 +1,69 @@
 package model
 
+import "time"
+
 type Note struct {
-	Value string
-	Tag   string
-	Id    int64
+	Value     string
+	Tags      []string
+	Id        int64
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// DeletedAt is when the note was moved to the trash, zero for live
+	// notes.
+	DeletedAt time.Time
+	// Snippet is the highlighted match context, only set by Search.
+	Snippet string
+	// UUID identifies the note across stores, unlike Id which is only
+	// unique within one.
+	UUID string
+}
+
+// DateRange limits notes by creation time. Since is inclusive, Until is
+// exclusive and a zero bound leaves that side open.
+type DateRange struct {
+	Since time.Time
+	Until time.Time
+}
+
+// Revision is an earlier version of a note. Numbers start at 1 for each
+// note; the note itself is the version after the last revision.
+type Revision struct {
+	NoteId int64
+	Number int
+	Value  string
+	Tags   []string
+	// CreatedAt is when this version was written.
+	CreatedAt time.Time
+}
+
+// Tombstone records that the note with UUID was permanently removed, so
+// other stores can remove their copy when they sync. DeletedAt is when the
+// note was moved to the trash.
+type Tombstone struct {
+	UUID      string
+	DeletedAt time.Time
+}
+
+// SavedSearch is a query saved under a name, in the language of package
+// filter. Its $name parameters are given each time it runs.
+type SavedSearch struct {
+	Name  string
+	Query string
+}
+
+// Tag is a tag with the number of notes outside the trash carrying it.
+// LastUsed is the latest update time of those notes, zero when there are
+// none.
+type Tag struct {
+	Name     string
+	Notes    int
+	LastUsed time.Time
+}
+
+// TagNode is a tag in the hierarchy of tags split on "/", with the tags
+// right below it as Children. Its Name is the whole path, and Notes and
+// LastUsed count the notes carrying the tag or any tag below it.
+type TagNode struct {
+	Tag
+	Children []TagNode
 }
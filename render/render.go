@@ -0,0 +1,280 @@
+// Package render writes notes in the output formats selected with
+// --output: a terminal table, JSON, JSON lines, CSV, YAML, Markdown or a Go
+// text/template.
+package render
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/iamunni/hugnin/model"
+	"github.com/olekukonko/tablewriter"
+	"gopkg.in/yaml.v3"
+)
+
+// Renderer writes a list of notes to w.
+type Renderer interface {
+	Render(w io.Writer, notes []model.Note) error
+}
+
+type renderFunc func(w io.Writer, notes []model.Note) error
+
+func (f renderFunc) Render(w io.Writer, notes []model.Note) error {
+	return f(w, notes)
+}
+
+var formats = map[string]renderFunc{
+	"table":    renderTable,
+	"json":     renderJSON,
+	"jsonl":    renderJSONLines,
+	"csv":      renderCSV,
+	"yaml":     renderYAML,
+	"markdown": renderMarkdown,
+}
+
+// Formats lists the names accepted by New, not counting templates.
+func Formats() []string {
+	var names []string
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// templateFuncs are available to output templates on top of the
+// text/template builtins.
+var templateFuncs = template.FuncMap{
+	"join": strings.Join,
+}
+
+// New returns the renderer for format. A format containing "{{" is parsed
+// as a text/template and executed once per note, each followed by a
+// newline; its dot is a Record.
+func New(format string) (Renderer, error) {
+	if strings.Contains(format, "{{") {
+		tmpl, err := template.New("output").Funcs(templateFuncs).Parse(format)
+		if err != nil {
+			return nil, fmt.Errorf("invalid output template: %w", err)
+		}
+		return renderFunc(func(w io.Writer, notes []model.Note) error {
+			return renderTemplate(w, tmpl, notes)
+		}), nil
+	}
+	f, ok := formats[strings.ToLower(format)]
+	if !ok {
+		return nil, fmt.Errorf("unknown output format %q, use one of %s or a Go template", format, strings.Join(Formats(), ", "))
+	}
+	return f, nil
+}
+
+// Record is the shape of a note in the structured formats.
+type Record struct {
+	Id        int64      `json:"id" yaml:"id"`
+	Note      string     `json:"note" yaml:"note"`
+	Tags      []string   `json:"tags" yaml:"tags"`
+	CreatedAt time.Time  `json:"created_at" yaml:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" yaml:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" yaml:"deleted_at,omitempty"`
+	Snippet   string     `json:"snippet,omitempty" yaml:"snippet,omitempty"`
+}
+
+// NewRecord returns the structured form of note.
+func NewRecord(note model.Note) Record {
+	tags := note.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	record := Record{
+		Id:        note.Id,
+		Note:      note.Value,
+		Tags:      tags,
+		CreatedAt: note.CreatedAt,
+		UpdatedAt: note.UpdatedAt,
+		Snippet:   note.Snippet,
+	}
+	if !note.DeletedAt.IsZero() {
+		deletedAt := note.DeletedAt
+		record.DeletedAt = &deletedAt
+	}
+	return record
+}
+
+// NewRecords returns the structured form of notes, never nil.
+func NewRecords(notes []model.Note) []Record {
+	records := make([]Record, 0, len(notes))
+	for _, note := range notes {
+		records = append(records, NewRecord(note))
+	}
+	return records
+}
+
+func hasDeleted(notes []model.Note) bool {
+	for _, note := range notes {
+		if !note.DeletedAt.IsZero() {
+			return true
+		}
+	}
+	return false
+}
+
+func hasSnippets(notes []model.Note) bool {
+	for _, note := range notes {
+		if note.Snippet != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// columns returns the header and rows shared by the table, CSV and
+// Markdown formats.
+func columns(notes []model.Note, formatTime func(time.Time) string) ([]string, [][]string) {
+	withDeleted := hasDeleted(notes)
+	withSnippets := hasSnippets(notes)
+	header := []string{"Id", "Note", "Tag", "Created", "Updated"}
+	if withDeleted {
+		header = append(header, "Deleted")
+	}
+	if withSnippets {
+		header = append(header, "Match")
+	}
+	var data [][]string
+	for _, note := range notes {
+		row := []string{strconv.FormatInt(note.Id, 10), note.Value, strings.Join(note.Tags, ","), formatTime(note.CreatedAt), formatTime(note.UpdatedAt)}
+		if withDeleted {
+			row = append(row, formatTime(note.DeletedAt))
+		}
+		if withSnippets {
+			row = append(row, note.Snippet)
+		}
+		data = append(data, row)
+	}
+	return header, data
+}
+
+// FirstLine returns the first non-blank line of a note, followed by " …"
+// when more lines follow it.
+func FirstLine(value string) string {
+	value = strings.TrimLeft(value, " \t\r\n")
+	first, rest, _ := strings.Cut(value, "\n")
+	first = strings.TrimRight(first, "\r")
+	if strings.TrimSpace(rest) != "" {
+		first += " …"
+	}
+	return first
+}
+
+func localMinutes(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+func rfc3339(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// renderTable shows the first line of each note, the full text is printed
+// by hugnin show.
+func renderTable(w io.Writer, notes []model.Note) error {
+	header, data := columns(notes, localMinutes)
+	for _, row := range data {
+		row[1] = FirstLine(row[1])
+	}
+	table := tablewriter.NewWriter(w)
+	table.SetHeader(header)
+	table.AppendBulk(data)
+	table.Render()
+	return nil
+}
+
+func renderJSON(w io.Writer, notes []model.Note) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(NewRecords(notes))
+}
+
+func renderJSONLines(w io.Writer, notes []model.Note) error {
+	enc := json.NewEncoder(w)
+	for _, note := range notes {
+		err := enc.Encode(NewRecord(note))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func renderCSV(w io.Writer, notes []model.Note) error {
+	_, data := columns(notes, rfc3339)
+	header := []string{"id", "note", "tags", "created_at", "updated_at"}
+	if hasDeleted(notes) {
+		header = append(header, "deleted_at")
+	}
+	if hasSnippets(notes) {
+		header = append(header, "snippet")
+	}
+	cw := csv.NewWriter(w)
+	err := cw.Write(header)
+	if err != nil {
+		return err
+	}
+	err = cw.WriteAll(data)
+	if err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+func renderYAML(w io.Writer, notes []model.Note) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	err := enc.Encode(NewRecords(notes))
+	if err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+var markdownEscaper = strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>")
+
+func renderMarkdown(w io.Writer, notes []model.Note) error {
+	header, data := columns(notes, localMinutes)
+	var sb strings.Builder
+	sb.WriteString("| " + strings.Join(header, " | ") + " |\n")
+	sb.WriteString("|" + strings.Repeat(" --- |", len(header)) + "\n")
+	for _, row := range data {
+		for i := range row {
+			row[i] = markdownEscaper.Replace(row[i])
+		}
+		sb.WriteString("| " + strings.Join(row, " | ") + " |\n")
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func renderTemplate(w io.Writer, tmpl *template.Template, notes []model.Note) error {
+	for _, note := range notes {
+		err := tmpl.Execute(w, NewRecord(note))
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, "\n")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
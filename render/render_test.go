@@ -0,0 +1,215 @@
+package render
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iamunni/hugnin/model"
+)
+
+var testNotes = []model.Note{
+	{
+		Id:        1,
+		Value:     "restart | postgres",
+		Tags:      []string{"db", "oncall"},
+		CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		UpdatedAt: time.Date(2024, 1, 3, 3, 4, 5, 0, time.UTC),
+	},
+	{
+		Id:        2,
+		Value:     "two\nlines",
+		CreatedAt: time.Date(2024, 2, 2, 3, 4, 5, 0, time.UTC),
+		UpdatedAt: time.Date(2024, 2, 2, 3, 4, 5, 0, time.UTC),
+	},
+}
+
+func TestNew_Formats(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: "json",
+			want: `[
+  {
+    "id": 1,
+    "note": "restart | postgres",
+    "tags": [
+      "db",
+      "oncall"
+    ],
+    "created_at": "2024-01-02T03:04:05Z",
+    "updated_at": "2024-01-03T03:04:05Z"
+  },
+  {
+    "id": 2,
+    "note": "two\nlines",
+    "tags": [],
+    "created_at": "2024-02-02T03:04:05Z",
+    "updated_at": "2024-02-02T03:04:05Z"
+  }
+]
+`,
+		},
+		{
+			format: "jsonl",
+			want: `{"id":1,"note":"restart | postgres","tags":["db","oncall"],"created_at":"2024-01-02T03:04:05Z","updated_at":"2024-01-03T03:04:05Z"}
+{"id":2,"note":"two\nlines","tags":[],"created_at":"2024-02-02T03:04:05Z","updated_at":"2024-02-02T03:04:05Z"}
+`,
+		},
+		{
+			format: "csv",
+			want: `id,note,tags,created_at,updated_at
+1,restart | postgres,"db,oncall",2024-01-02T03:04:05Z,2024-01-03T03:04:05Z
+2,"two
+lines",,2024-02-02T03:04:05Z,2024-02-02T03:04:05Z
+`,
+		},
+		{
+			format: "yaml",
+			want: `- id: 1
+  note: restart | postgres
+  tags:
+    - db
+    - oncall
+  created_at: 2024-01-02T03:04:05Z
+  updated_at: 2024-01-03T03:04:05Z
+- id: 2
+  note: |-
+    two
+    lines
+  tags: []
+  created_at: 2024-02-02T03:04:05Z
+  updated_at: 2024-02-02T03:04:05Z
+`,
+		},
+		{
+			format: "{{.Id}}:{{.Note | printf \"%q\"}}:{{join .Tags \"+\"}}",
+			want:   "1:\"restart | postgres\":db+oncall\n2:\"two\\nlines\":\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			r, err := New(tt.format)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			var buf bytes.Buffer
+			if err := r.Render(&buf, testNotes); err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("Render() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNew_Markdown(t *testing.T) {
+	r, err := New("markdown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := r.Render(&buf, testNotes); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("markdown has %d lines, want 4:\n%s", len(lines), buf.String())
+	}
+	if lines[0] != "| Id | Note | Tag | Created | Updated |" {
+		t.Errorf("header = %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[2], `| 1 | restart \| postgres | db,oncall |`) {
+		t.Errorf("row 1 = %q", lines[2])
+	}
+	if !strings.HasPrefix(lines[3], "| 2 | two<br>lines |  |") {
+		t.Errorf("row 2 = %q", lines[3])
+	}
+}
+
+func TestNew_TableShowsSnippets(t *testing.T) {
+	r, err := New("TABLE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	notes := []model.Note{{Id: 1, Value: "restart postgres", Snippet: "restart [postgres]"}}
+	var buf bytes.Buffer
+	if err := r.Render(&buf, notes); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "MATCH") || !strings.Contains(buf.String(), "restart [postgres]") {
+		t.Errorf("table without snippet column:\n%s", buf.String())
+	}
+}
+
+func TestNew_TablePreviewsFirstLine(t *testing.T) {
+	r, err := New("table")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := r.Render(&buf, testNotes); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "two …") || strings.Contains(buf.String(), "lines") {
+		t.Errorf("table without a first line preview:\n%s", buf.String())
+	}
+}
+
+func TestFirstLine(t *testing.T) {
+	tests := map[string]string{
+		"one":              "one",
+		"one\n":            "one",
+		"\n  one\r\ntwo\n": "one …",
+		"one\n\n  \n":      "one",
+		"one\ntwo\nthree":  "one …",
+		"":                 "",
+	}
+	for value, want := range tests {
+		if got := FirstLine(value); got != want {
+			t.Errorf("FirstLine(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestNew_ShowsDeletedAt(t *testing.T) {
+	deleted := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	notes := []model.Note{{Id: 1, Value: "old", DeletedAt: deleted}}
+
+	r, err := New("csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := r.Render(&buf, notes); err != nil {
+		t.Fatal(err)
+	}
+	want := "id,note,tags,created_at,updated_at,deleted_at\n1,old,,,,2024-03-01T09:00:00Z\n"
+	if buf.String() != want {
+		t.Errorf("csv =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	r, err = New("json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := r.Render(&buf, notes); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"deleted_at": "2024-03-01T09:00:00Z"`) {
+		t.Errorf("json without deleted_at:\n%s", buf.String())
+	}
+}
+
+func TestNew_Errors(t *testing.T) {
+	for _, format := range []string{"xml", "{{.Missing"} {
+		if _, err := New(format); err == nil {
+			t.Errorf("New(%q) expected an error", format)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/iamunni/hugnin/model"
+)
+
+// ArchiveVersion is the version of the JSON export format written by
+// WriteArchive. Bump it when a change would make older versions of hugnin
+// misread an archive, and keep ReadArchive able to read every older one.
+const ArchiveVersion = 1
+
+// Archive is the JSON export format:
+//
+//	{
+//	  "version": 1,
+//	  "exported_at": "2024-03-01T09:00:00Z",
+//	  "notes": [
+//	    {
+//	      "id": 12,
+//	      "note": "restart postgres",
+//	      "tags": ["db", "oncall"],
+//	      "created_at": "2024-02-28T10:15:00Z",
+//	      "updated_at": "2024-02-29T08:00:00Z",
+//	      "deleted_at": "2024-03-01T08:30:00Z"
+//	    }
+//	  ]
+//	}
+//
+// deleted_at is only present for notes in the trash. Revision history and
+// note UUIDs are not exported, so imported notes get new UUIDs.
+type Archive struct {
+	Version    int           `json:"version"`
+	ExportedAt time.Time     `json:"exported_at"`
+	Notes      []ArchiveNote `json:"notes"`
+}
+
+// ArchiveNote is one note of an Archive.
+type ArchiveNote struct {
+	Id        int64      `json:"id"`
+	Note      string     `json:"note"`
+	Tags      []string   `json:"tags"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// WriteArchive writes notes to w as an indented JSON Archive.
+func WriteArchive(w io.Writer, notes []model.Note, now time.Time) error {
+	archive := Archive{
+		Version:    ArchiveVersion,
+		ExportedAt: now.UTC().Truncate(time.Second),
+		Notes:      make([]ArchiveNote, 0, len(notes)),
+	}
+	for _, note := range notes {
+		tags := note.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		entry := ArchiveNote{
+			Id:        note.Id,
+			Note:      note.Value,
+			Tags:      tags,
+			CreatedAt: note.CreatedAt,
+			UpdatedAt: note.UpdatedAt,
+		}
+		if !note.DeletedAt.IsZero() {
+			deletedAt := note.DeletedAt
+			entry.DeletedAt = &deletedAt
+		}
+		archive.Notes = append(archive.Notes, entry)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(archive)
+}
+
+// ReadArchive reads a JSON Archive written by WriteArchive and returns its
+// notes. It rejects archives from a newer version of hugnin, notes without
+// text and repeated Ids.
+func ReadArchive(r io.Reader) ([]model.Note, error) {
+	var archive Archive
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&archive)
+	if err != nil {
+		return nil, fmt.Errorf("reading archive: %w", err)
+	}
+	if archive.Version < 1 || archive.Version > ArchiveVersion {
+		return nil, fmt.Errorf("archive version %d is not supported, this hugnin reads versions 1 to %d", archive.Version, ArchiveVersion)
+	}
+
+	notes := make([]model.Note, 0, len(archive.Notes))
+	seen := make(map[int64]bool, len(archive.Notes))
+	for i, entry := range archive.Notes {
+		if entry.Note == "" {
+			return nil, fmt.Errorf("archive note %d (id %d) has no text", i+1, entry.Id)
+		}
+		if entry.Id <= 0 || seen[entry.Id] {
+			return nil, fmt.Errorf("archive note %d has an invalid or repeated id %d", i+1, entry.Id)
+		}
+		seen[entry.Id] = true
+		note := model.Note{
+			Id:        entry.Id,
+			Value:     entry.Note,
+			Tags:      cleanTags(entry.Tags),
+			CreatedAt: entry.CreatedAt.UTC(),
+			UpdatedAt: entry.UpdatedAt.UTC(),
+		}
+		if entry.DeletedAt != nil {
+			note.DeletedAt = entry.DeletedAt.UTC()
+		}
+		notes = append(notes, note)
+	}
+	return notes, nil
+}
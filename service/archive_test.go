@@ -0,0 +1,114 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/iamunni/hugnin/model"
+	"github.com/iamunni/hugnin/store"
+)
+
+func openTestStore(t *testing.T) store.Store {
+	s := store.NewSQLiteStore(filepath.Join(t.TempDir(), "notes.db"))
+	if err := s.Open(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// TestArchive_RoundTrip exports a store, imports the archive into a fresh
+// store and checks that every note comes back unchanged.
+func TestArchive_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	source := NewNoteService(openTestStore(t))
+	for _, note := range []model.Note{
+		{Value: "restart postgres", Tags: []string{"db", "oncall"}},
+		{Value: "two\nlines, \"quotes\" and unicode ✓"},
+		{Value: "to be edited", Tags: []string{"draft"}},
+		{Value: "to be trashed"},
+		{Value: "gone for good"},
+	} {
+		if _, err := source.Add(ctx, note); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := source.Update(ctx, model.Note{Id: 3, Value: "edited", Tags: []string{"final"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := source.Delete(ctx, model.Note{Id: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := source.EmptyTrash(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := source.Delete(ctx, model.Note{Id: 4}); err != nil {
+		t.Fatal(err)
+	}
+	want, err := source.Export(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteArchive(&buf, want, testNow); err != nil {
+		t.Fatalf("WriteArchive() error = %v", err)
+	}
+	notes, err := ReadArchive(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("ReadArchive() error = %v", err)
+	}
+
+	target := NewNoteService(openTestStore(t))
+	if _, err := target.Add(ctx, model.Note{Value: "replaced"}); err != nil {
+		t.Fatal(err)
+	}
+	imported, err := target.Import(ctx, notes, store.ImportReplace)
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	if imported != int64(len(want)) {
+		t.Errorf("Import() = %d, want %d", imported, len(want))
+	}
+	got, err := target.Export(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// UUIDs are not archived; the imported notes get new ones.
+	for i := range got {
+		got[i].UUID, want[i].UUID = "", ""
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip =\n%v\nwant\n%v", got, want)
+	}
+
+	imported, err = target.Import(ctx, notes, store.ImportMerge)
+	if err != nil || imported != 0 {
+		t.Errorf("Import() merging the same archive = %d, %v, want 0", imported, err)
+	}
+}
+
+func TestReadArchive_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		archive string
+	}{
+		{name: "not json", archive: "notes"},
+		{name: "newer version", archive: `{"version": 2, "notes": []}`},
+		{name: "missing version", archive: `{"notes": []}`},
+		{name: "unknown field", archive: `{"version": 1, "notes": [{"id": 1, "note": "x", "colour": "red"}]}`},
+		{name: "empty note", archive: `{"version": 1, "notes": [{"id": 1, "note": ""}]}`},
+		{name: "repeated id", archive: `{"version": 1, "notes": [{"id": 1, "note": "x"}, {"id": 1, "note": "y"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadArchive(strings.NewReader(tt.archive)); err == nil {
+				t.Error("ReadArchive() expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iamunni/hugnin/model"
+)
+
+// dateLayouts are the absolute forms accepted by ParseDateSpan, from the
+// most to the least precise.
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// ParseDateSpan resolves value to the half-open span [start, end) it names,
+// relative to now and in now's location.
+//
+// Absolute dates ("2024-01-31") and the words today, yesterday, this-week,
+// last-week, this-month and last-month name whole days, weeks (starting
+// Monday) or months. Absolute times and relative offsets such as 90m, 12h,
+// 3d, 2w or 6mo ("six months ago") name an instant, returned with
+// start == end.
+func ParseDateSpan(value string, now time.Time) (start, end time.Time, err error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	switch value {
+	case "today":
+		return today, today.AddDate(0, 0, 1), nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), today, nil
+	case "this-week", "last-week":
+		monday := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+		if value == "last-week" {
+			monday = monday.AddDate(0, 0, -7)
+		}
+		return monday, monday.AddDate(0, 0, 7), nil
+	case "this-month", "last-month":
+		first := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		if value == "last-month" {
+			first = first.AddDate(0, -1, 0)
+		}
+		return first, first.AddDate(0, 1, 0), nil
+	}
+
+	if t, ok := parseRelative(value, now); ok {
+		return t, t, nil
+	}
+
+	for _, layout := range dateLayouts {
+		t, err := time.ParseInLocation(layout, value, now.Location())
+		if err != nil {
+			continue
+		}
+		if layout == "2006-01-02" {
+			return t, t.AddDate(0, 0, 1), nil
+		}
+		return t, t, nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("invalid date %q: use YYYY-MM-DD, a relative form like 3d or 2w, or today, yesterday, this-week, last-week, this-month, last-month", value)
+}
+
+// parseRelative reads "<n><unit>" offsets into the past.
+func parseRelative(value string, now time.Time) (time.Time, bool) {
+	units := []struct {
+		suffix string
+		apply  func(n int) time.Time
+	}{
+		{"mo", func(n int) time.Time { return now.AddDate(0, -n, 0) }},
+		{"m", func(n int) time.Time { return now.Add(-time.Duration(n) * time.Minute) }},
+		{"h", func(n int) time.Time { return now.Add(-time.Duration(n) * time.Hour) }},
+		{"d", func(n int) time.Time { return now.AddDate(0, 0, -n) }},
+		{"w", func(n int) time.Time { return now.AddDate(0, 0, -7*n) }},
+		{"y", func(n int) time.Time { return now.AddDate(-n, 0, 0) }},
+	}
+	for _, unit := range units {
+		digits, ok := strings.CutSuffix(value, unit.suffix)
+		if !ok || digits == "" {
+			continue
+		}
+		n, err := strconv.Atoi(digits)
+		if err != nil || n < 0 {
+			continue
+		}
+		return unit.apply(n), true
+	}
+	return time.Time{}, false
+}
+
+// ParseDateRange builds the creation date filter of view and search from
+// the --since, --until and --on flag values. --since includes the named
+// span, --until includes it too, and --on selects exactly one span; empty
+// values are ignored.
+func ParseDateRange(since, until, on string, now time.Time) (model.DateRange, error) {
+	var period model.DateRange
+	if on != "" {
+		if since != "" || until != "" {
+			return period, fmt.Errorf("--on cannot be combined with --since or --until")
+		}
+		start, end, err := ParseDateSpan(on, now)
+		if err != nil {
+			return period, err
+		}
+		if start.Equal(end) {
+			return period, fmt.Errorf("--on needs a day, week or month, not %q", on)
+		}
+		return model.DateRange{Since: start, Until: end}, nil
+	}
+	if since != "" {
+		start, _, err := ParseDateSpan(since, now)
+		if err != nil {
+			return period, err
+		}
+		period.Since = start
+	}
+	if until != "" {
+		_, end, err := ParseDateSpan(until, now)
+		if err != nil {
+			return period, err
+		}
+		period.Until = end
+	}
+	if !period.Since.IsZero() && !period.Until.IsZero() && !period.Since.Before(period.Until) {
+		return period, fmt.Errorf("--since %s is not before --until %s", since, until)
+	}
+	return period, nil
+}
+
+// ParseRetention returns the cutoff before which trashed notes are purged
+// for a trash_retention value such as 30d, 2w or 6mo. An empty value, 0,
+// off or never keeps trashed notes forever and returns the zero time.
+func ParseRetention(value string, now time.Time) (time.Time, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch value {
+	case "", "0", "off", "never":
+		return time.Time{}, nil
+	}
+	cutoff, ok := parseRelative(value, now)
+	if !ok || !cutoff.Before(now) {
+		return time.Time{}, fmt.Errorf("invalid trash retention %q: use a period like 30d, 2w or 6mo, or never", value)
+	}
+	return cutoff, nil
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iamunni/hugnin/model"
+)
+
+// testNow is a Wednesday.
+var testNow = time.Date(2024, 3, 13, 15, 30, 0, 0, time.UTC)
+
+func day(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestParseDateSpan(t *testing.T) {
+	tests := []struct {
+		value     string
+		wantStart time.Time
+		wantEnd   time.Time
+		wantErr   bool
+	}{
+		{value: "2024-01-31", wantStart: day(2024, 1, 31), wantEnd: day(2024, 2, 1)},
+		{value: "2024-01-31 10:15", wantStart: time.Date(2024, 1, 31, 10, 15, 0, 0, time.UTC), wantEnd: time.Date(2024, 1, 31, 10, 15, 0, 0, time.UTC)},
+		{value: "today", wantStart: day(2024, 3, 13), wantEnd: day(2024, 3, 14)},
+		{value: "Yesterday", wantStart: day(2024, 3, 12), wantEnd: day(2024, 3, 13)},
+		{value: "this-week", wantStart: day(2024, 3, 11), wantEnd: day(2024, 3, 18)},
+		{value: "last-week", wantStart: day(2024, 3, 4), wantEnd: day(2024, 3, 11)},
+		{value: "last-month", wantStart: day(2024, 2, 1), wantEnd: day(2024, 3, 1)},
+		{value: "3d", wantStart: testNow.AddDate(0, 0, -3), wantEnd: testNow.AddDate(0, 0, -3)},
+		{value: "2w", wantStart: testNow.AddDate(0, 0, -14), wantEnd: testNow.AddDate(0, 0, -14)},
+		{value: "90m", wantStart: testNow.Add(-90 * time.Minute), wantEnd: testNow.Add(-90 * time.Minute)},
+		{value: "6mo", wantStart: testNow.AddDate(0, -6, 0), wantEnd: testNow.AddDate(0, -6, 0)},
+		{value: "d", wantErr: true},
+		{value: "31/01/2024", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			start, end, err := ParseDateSpan(tt.value, testNow)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDateSpan() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) {
+				t.Errorf("ParseDateSpan() = [%v, %v), want [%v, %v)", start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestParseDateRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		since   string
+		until   string
+		on      string
+		want    model.DateRange
+		wantErr bool
+	}{
+		{name: "no flags", want: model.DateRange{}},
+		{name: "since a day", since: "2024-01-31", want: model.DateRange{Since: day(2024, 1, 31)}},
+		{name: "until includes the day", until: "2024-01-31", want: model.DateRange{Until: day(2024, 2, 1)}},
+		{name: "since and until", since: "last-week", until: "yesterday", want: model.DateRange{Since: day(2024, 3, 4), Until: day(2024, 3, 13)}},
+		{name: "on a day", on: "2024-01-31", want: model.DateRange{Since: day(2024, 1, 31), Until: day(2024, 2, 1)}},
+		{name: "on an instant", on: "3d", wantErr: true},
+		{name: "on with since", on: "today", since: "3d", wantErr: true},
+		{name: "since after until", since: "today", until: "last-week", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDateRange(tt.since, tt.until, tt.on, testNow)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDateRange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (!got.Since.Equal(tt.want.Since) || !got.Until.Equal(tt.want.Until)) {
+				t.Errorf("ParseDateRange() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRetention(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "30d", want: testNow.AddDate(0, 0, -30)},
+		{value: "2W", want: testNow.AddDate(0, 0, -14)},
+		{value: "6mo", want: testNow.AddDate(0, -6, 0)},
+		{value: "", want: time.Time{}},
+		{value: "never", want: time.Time{}},
+		{value: "0", want: time.Time{}},
+		{value: "0d", wantErr: true},
+		{value: "today", wantErr: true},
+		{value: "2024-01-01", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseRetention(tt.value, testNow)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRetention() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseRetention() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// diffLine is one line of an edit script. kind is ' ' for a line kept from
+// a, '-' for a line removed from a and '+' for a line added from b. aPos
+// and bPos count the lines of a and b before it.
+type diffLine struct {
+	kind       byte
+	text       string
+	aPos, bPos int
+}
+
+// UnifiedDiff returns the line diff turning a into b in unified format,
+// with fromName and toName in the file headers. It returns "" when a and
+// b are equal.
+func UnifiedDiff(fromName, toName, a, b string) string {
+	if a == b {
+		return ""
+	}
+	script := editScript(splitLines(a), splitLines(b))
+
+	var sb strings.Builder
+	sb.WriteString("--- " + fromName + "\n")
+	sb.WriteString("+++ " + toName + "\n")
+	for i := 0; i < len(script); {
+		if script[i].kind == ' ' {
+			i++
+			continue
+		}
+		start := max(i-diffContext, 0)
+		end := i
+		for end < len(script) {
+			if script[end].kind != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(script) && script[run].kind == ' ' {
+				run++
+			}
+			if run == len(script) || run-end > 2*diffContext {
+				end = min(end+diffContext, len(script))
+				break
+			}
+			end = run
+		}
+		writeHunk(&sb, script[start:end])
+		i = end
+	}
+	return sb.String()
+}
+
+func writeHunk(sb *strings.Builder, hunk []diffLine) {
+	var aLen, bLen int
+	for _, line := range hunk {
+		if line.kind != '+' {
+			aLen++
+		}
+		if line.kind != '-' {
+			bLen++
+		}
+	}
+	fmt.Fprintf(sb, "@@ -%s +%s @@\n", hunkRange(hunk[0].aPos, aLen), hunkRange(hunk[0].bPos, bLen))
+	for _, line := range hunk {
+		sb.WriteByte(line.kind)
+		sb.WriteString(line.text)
+		sb.WriteByte('\n')
+	}
+}
+
+// hunkRange formats the "start,length" of a hunk that begins after pos
+// lines. An empty range names the line it follows, as diff(1) does.
+func hunkRange(pos, length int) string {
+	if length == 0 {
+		return fmt.Sprintf("%d,0", pos)
+	}
+	if length == 1 {
+		return fmt.Sprintf("%d", pos+1)
+	}
+	return fmt.Sprintf("%d,%d", pos+1, length)
+}
+
+// editScript returns a shortest edit script from a to b, built from their
+// longest common subsequence. Notes are short, so the quadratic table is
+// fine.
+func editScript(a, b []string) []diffLine {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var script []diffLine
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			script = append(script, diffLine{kind: ' ', text: a[i], aPos: i, bPos: j})
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			script = append(script, diffLine{kind: '-', text: a[i], aPos: i, bPos: j})
+			i++
+		default:
+			script = append(script, diffLine{kind: '+', text: b[j], aPos: i, bPos: j})
+			j++
+		}
+	}
+	return script
+}
+
+// splitLines splits text into lines, ignoring a final newline.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
@@ -0,0 +1,45 @@
+package service
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{
+			name: "equal",
+			a:    "one\ntwo\n",
+			b:    "one\ntwo\n",
+			want: "",
+		},
+		{
+			name: "from empty",
+			a:    "",
+			b:    "one\ntwo\n",
+			want: "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+one\n+two\n",
+		},
+		{
+			name: "separate hunks",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			b:    "1\nTWO\n3\n4\n5\n6\n7\n8\n9\n10\n12\n",
+			want: "--- a\n+++ b\n" +
+				"@@ -1,5 +1,5 @@\n 1\n-2\n+TWO\n 3\n 4\n 5\n" +
+				"@@ -8,5 +8,4 @@\n 8\n 9\n 10\n-11\n 12\n",
+		},
+		{
+			name: "close changes share a hunk",
+			a:    "1\n2\n3\n4\n5\n6\n",
+			b:    "1\nx\n3\n4\n5\ny\n",
+			want: "--- a\n+++ b\n@@ -1,6 +1,6 @@\n 1\n-2\n+x\n 3\n 4\n 5\n-6\n+y\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UnifiedDiff("a", "b", tt.a, tt.b); got != tt.want {
+				t.Errorf("UnifiedDiff() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
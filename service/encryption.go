@@ -0,0 +1,162 @@
+package service
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/iamunni/hugnin/model"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Encrypted notes are stored as encryptedPrefix followed by the base64 of
+// a random scrypt salt, an AES-GCM nonce and the sealed text. The prefix
+// is authenticated too, so a note cannot be moved to another format.
+const encryptedPrefix = "hugnin:enc:v1:"
+
+// EncryptedPlaceholder is shown in place of notes that are not decrypted.
+const EncryptedPlaceholder = "[encrypted]"
+
+// scrypt parameters, as recommended for interactive logins in 2017.
+const (
+	scryptN    = 1 << 15
+	scryptR    = 8
+	scryptP    = 1
+	saltLength = 16
+	keyLength  = 32
+)
+
+// ErrWrongPassphrase is returned when a note does not decrypt with the
+// given passphrase.
+var ErrWrongPassphrase = errors.New("wrong passphrase or damaged note")
+
+// ErrEncrypted is returned when an update would replace the text of an
+// encrypted note with plain text.
+var ErrEncrypted = errors.New("the text of an encrypted note cannot be changed, only its tags")
+
+// IsEncrypted reports whether a note text was written by Crypter.Encrypt.
+func IsEncrypted(text string) bool {
+	return strings.HasPrefix(text, encryptedPrefix)
+}
+
+// Crypter encrypts and decrypts note texts with a passphrase. Deriving a
+// key is slow on purpose, so a Crypter keeps the keys it derived and
+// encrypts every text with the same salt.
+type Crypter struct {
+	passphrase []byte
+	salt       []byte
+	keys       map[string]cipher.AEAD
+}
+
+// NewCrypter returns a Crypter for passphrase, which must not be empty.
+func NewCrypter(passphrase string) (*Crypter, error) {
+	if passphrase == "" {
+		return nil, errors.New("passphrase must not be empty")
+	}
+	return &Crypter{
+		passphrase: []byte(passphrase),
+		keys:       map[string]cipher.AEAD{},
+	}, nil
+}
+
+// Encrypt seals text into the stored form of an encrypted note.
+func (c *Crypter) Encrypt(text string) (string, error) {
+	if c.salt == nil {
+		c.salt = make([]byte, saltLength)
+		_, err := rand.Read(c.salt)
+		if err != nil {
+			return "", err
+		}
+	}
+	aead, err := c.key(c.salt)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+	sealed := append(append([]byte{}, c.salt...), nonce...)
+	sealed = aead.Seal(sealed, nonce, []byte(text), []byte(encryptedPrefix))
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt returns the text of an encrypted note, or ErrWrongPassphrase.
+func (c *Crypter) Decrypt(value string) (string, error) {
+	encoded, ok := strings.CutPrefix(value, encryptedPrefix)
+	if !ok {
+		return "", errors.New("note is not encrypted")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < saltLength {
+		return "", ErrWrongPassphrase
+	}
+	aead, err := c.key(sealed[:saltLength])
+	if err != nil {
+		return "", err
+	}
+	sealed = sealed[saltLength:]
+	if len(sealed) < aead.NonceSize() {
+		return "", ErrWrongPassphrase
+	}
+	text, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(encryptedPrefix))
+	if err != nil {
+		return "", ErrWrongPassphrase
+	}
+	return string(text), nil
+}
+
+// DecryptNotes returns notes with their encrypted texts decrypted.
+func (c *Crypter) DecryptNotes(notes []model.Note) ([]model.Note, error) {
+	result := make([]model.Note, len(notes))
+	for i, note := range notes {
+		if IsEncrypted(note.Value) {
+			text, err := c.Decrypt(note.Value)
+			if err != nil {
+				return nil, fmt.Errorf("note %d: %w", note.Id, err)
+			}
+			note.Value = text
+		}
+		result[i] = note
+	}
+	return result, nil
+}
+
+func (c *Crypter) key(salt []byte) (cipher.AEAD, error) {
+	if aead, ok := c.keys[string(salt)]; ok {
+		return aead, nil
+	}
+	key, err := scrypt.Key(c.passphrase, salt, scryptN, scryptR, scryptP, keyLength)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	c.keys[string(salt)] = aead
+	return aead, nil
+}
+
+// ConcealEncrypted returns notes with the texts that are still encrypted
+// replaced by EncryptedPlaceholder.
+func ConcealEncrypted(notes []model.Note) []model.Note {
+	result := make([]model.Note, len(notes))
+	for i, note := range notes {
+		if IsEncrypted(note.Value) {
+			note.Value = EncryptedPlaceholder
+			note.Snippet = ""
+		}
+		result[i] = note
+	}
+	return result
+}
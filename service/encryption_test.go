@@ -0,0 +1,63 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/iamunni/hugnin/model"
+)
+
+func newTestCrypter(t *testing.T, passphrase string) *Crypter {
+	c, err := NewCrypter(passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestCrypter(t *testing.T) {
+	c := newTestCrypter(t, "correct horse")
+	sealed, err := c.Encrypt("db password: hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(sealed) || strings.Contains(sealed, "hunter2") {
+		t.Fatalf("Crypter.Encrypt() = %q", sealed)
+	}
+	if again, _ := c.Encrypt("db password: hunter2"); again == sealed {
+		t.Errorf("Crypter.Encrypt() reused a nonce")
+	}
+
+	if got, err := newTestCrypter(t, "correct horse").Decrypt(sealed); err != nil || got != "db password: hunter2" {
+		t.Errorf("Crypter.Decrypt() = %q, %v", got, err)
+	}
+	if _, err := newTestCrypter(t, "wrong horse").Decrypt(sealed); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Crypter.Decrypt() with the wrong passphrase error = %v, want ErrWrongPassphrase", err)
+	}
+	tampered := sealed[:len(sealed)-4] + "AAA="
+	if _, err := c.Decrypt(tampered); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Crypter.Decrypt() of a damaged note error = %v, want ErrWrongPassphrase", err)
+	}
+	if _, err := c.Decrypt(encryptedPrefix + "!!"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Crypter.Decrypt() of invalid base64 error = %v, want ErrWrongPassphrase", err)
+	}
+	if _, err := NewCrypter(""); err == nil {
+		t.Errorf("NewCrypter() accepted an empty passphrase")
+	}
+}
+
+func TestConcealEncrypted(t *testing.T) {
+	sealed, err := newTestCrypter(t, "pass").Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	notes := []model.Note{{Id: 1, Value: sealed, Snippet: sealed}, {Id: 2, Value: "plain"}}
+	got := ConcealEncrypted(notes)
+	if got[0].Value != EncryptedPlaceholder || got[0].Snippet != "" || got[1].Value != "plain" {
+		t.Errorf("ConcealEncrypted() = %v", got)
+	}
+	if notes[0].Value != sealed {
+		t.Errorf("ConcealEncrypted() changed its argument")
+	}
+}
@@ -0,0 +1,584 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/iamunni/hugnin/model"
+	"github.com/iamunni/hugnin/store"
+	"gopkg.in/yaml.v3"
+)
+
+// SyncGit keeps the notes in a git working tree, one file per note named
+// after its UUID, so that the same note has the same path in every clone:
+//
+//	notes/<uuid>.md    a note, with its tags, timestamps and trash state in a YAML front matter
+//	tombstones/<uuid>  when a purged note was removed
+//
+// The notes of the store are written to the tree and committed, then the
+// branch of the remote is fetched and merged, and the merged tree is read
+// back into the store before being pushed. A note changed on both sides
+// since the last sync is settled as Sync does: when only the trash state
+// or timestamps differ the later change wins, otherwise both versions are
+// kept and tagged ConflictTag.
+
+const (
+	gitNotesDir      = "notes"
+	gitTombstonesDir = "tombstones"
+	// defaultGitBranch is the branch SyncGit uses when GitRepo.Branch is
+	// empty.
+	defaultGitBranch = "main"
+)
+
+// GitRepo is the git working tree SyncGit keeps the notes in.
+type GitRepo struct {
+	// Dir is the working tree. It is created with git init when missing.
+	Dir string
+	// Remote is the URL or path of the repository merged from and pushed
+	// to, such as a bare repository on a USB stick. Without one the notes
+	// are only committed to Dir.
+	Remote string
+	// Branch is the branch synced, "main" when empty.
+	Branch string
+}
+
+// gitNoteHeader is the front matter of a note file in a GitRepo. The UUID
+// is the file name and the text the body.
+type gitNoteHeader struct {
+	Tags      []string   `yaml:"tags,flow"`
+	CreatedAt time.Time  `yaml:"created_at"`
+	UpdatedAt time.Time  `yaml:"updated_at"`
+	DeletedAt *time.Time `yaml:"deleted_at,omitempty"`
+}
+
+func (n *noteService) SyncGit(ctx context.Context, repo GitRepo) (SyncReport, error) {
+	var report SyncReport
+	err := repo.open(ctx)
+	if err != nil {
+		return SyncReport{}, err
+	}
+	local, err := loadSyncSide(ctx, n.store, &report.Pulled)
+	if err != nil {
+		return SyncReport{}, err
+	}
+	notes, tombstones, err := readGitTree(repo.Dir)
+	if err != nil {
+		return SyncReport{}, err
+	}
+
+	// The tree holds the notes as of the last sync, so what differs is what
+	// changed here since.
+	tree := &syncSide{notes: notes, tombstones: tombstones, counts: &report.Pushed}
+	for _, uuid := range sortedKeys(local.notes) {
+		tree.put(local.notes[uuid])
+	}
+	for _, uuid := range sortedKeys(local.tombstones) {
+		tombstone := local.tombstones[uuid]
+		if note, ok := tree.notes[uuid]; ok && !note.UpdatedAt.After(tombstone.DeletedAt) {
+			tree.forget(tombstone)
+			delete(tree.notes, uuid)
+		}
+		if tombstone.DeletedAt.After(tree.tombstones[uuid].DeletedAt) {
+			tree.tombstones[uuid] = tombstone
+		}
+	}
+	for uuid := range tree.notes {
+		delete(tree.tombstones, uuid)
+	}
+	err = writeGitTree(repo.Dir, tree.notes, tree.tombstones)
+	if err != nil {
+		return SyncReport{}, err
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown host"
+	}
+	err = repo.commit(ctx, fmt.Sprintf("Sync notes from %s\n\n%s\n", host, report.Pushed))
+	if err != nil {
+		return SyncReport{}, err
+	}
+
+	if repo.Remote != "" {
+		report.Conflicts, err = repo.pull(ctx)
+		if err != nil {
+			return SyncReport{}, err
+		}
+	}
+	notes, tombstones, err = readGitTree(repo.Dir)
+	if err != nil {
+		return SyncReport{}, err
+	}
+	for _, uuid := range sortedKeys(notes) {
+		local.put(notes[uuid])
+	}
+	for _, uuid := range sortedKeys(local.notes) {
+		if _, ok := notes[uuid]; ok {
+			continue
+		}
+		// A note file removed without a tombstone was deleted by hand.
+		tombstone, ok := tombstones[uuid]
+		if !ok {
+			tombstone = model.Tombstone{UUID: uuid, DeletedAt: time.Now().UTC()}
+		}
+		local.forget(tombstone)
+	}
+	for _, uuid := range sortedKeys(tombstones) {
+		_, known := local.tombstones[uuid]
+		if _, ok := local.notes[uuid]; !ok && !known {
+			local.forgets = append(local.forgets, tombstones[uuid])
+		}
+	}
+	err = local.apply(ctx)
+	if err != nil {
+		return SyncReport{}, err
+	}
+
+	if repo.Remote != "" {
+		err = repo.push(ctx)
+		if err != nil {
+			return SyncReport{}, err
+		}
+	}
+	return report, nil
+}
+
+func (r GitRepo) branch() string {
+	if r.Branch == "" {
+		return defaultGitBranch
+	}
+	return r.Branch
+}
+
+// git runs a git command in the working tree and returns its output.
+func (r GitRepo) git(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", r.Dir}, args...)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = strings.TrimSpace(stdout.String())
+		}
+		return stdout.String(), fmt.Errorf("git %s: %w: %s", args[0], err, msg)
+	}
+	return stdout.String(), nil
+}
+
+// open creates the working tree when it is missing, and points origin at
+// the remote.
+func (r GitRepo) open(ctx context.Context) error {
+	_, err := exec.LookPath("git")
+	if err != nil {
+		return fmt.Errorf("syncing with git needs the git command: %w", err)
+	}
+	err = os.MkdirAll(r.Dir, 0o755)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stat(filepath.Join(r.Dir, ".git"))
+	if errors.Is(err, fs.ErrNotExist) {
+		_, err = r.git(ctx, "init", "-q")
+		if err != nil {
+			return err
+		}
+		_, err = r.git(ctx, "symbolic-ref", "HEAD", "refs/heads/"+r.branch())
+	}
+	if err != nil {
+		return err
+	}
+	// Commits need an author; hugnin signs them when git has none set up.
+	if _, err := r.git(ctx, "config", "user.email"); err != nil {
+		for key, value := range map[string]string{"user.name": "hugnin", "user.email": "hugnin@localhost"} {
+			_, err = r.git(ctx, "config", key, value)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if r.Remote == "" {
+		return nil
+	}
+	if _, err := r.git(ctx, "remote", "get-url", "origin"); err != nil {
+		_, err = r.git(ctx, "remote", "add", "origin", r.Remote)
+		return err
+	}
+	_, err = r.git(ctx, "remote", "set-url", "origin", r.Remote)
+	return err
+}
+
+// commit commits every change of the working tree, if there are any.
+func (r GitRepo) commit(ctx context.Context, message string) error {
+	_, err := r.git(ctx, "add", "-A")
+	if err != nil {
+		return err
+	}
+	status, err := r.git(ctx, "status", "--porcelain")
+	if err != nil || status == "" {
+		return err
+	}
+	_, err = r.git(ctx, "commit", "-q", "-m", message)
+	return err
+}
+
+// pull merges the branch of the remote, settling the notes changed on
+// both sides, and returns how many were kept in both versions.
+func (r GitRepo) pull(ctx context.Context) (int, error) {
+	_, err := r.git(ctx, "fetch", "-q", "origin")
+	if err != nil {
+		return 0, err
+	}
+	ref := "refs/remotes/origin/" + r.branch()
+	if _, err := r.git(ctx, "rev-parse", "-q", "--verify", ref); err != nil {
+		// Nothing was pushed to the remote yet.
+		return 0, nil
+	}
+	_, mergeErr := r.git(ctx, "merge", "-q", "--no-edit", "--allow-unrelated-histories", ref)
+	if mergeErr == nil {
+		return 0, nil
+	}
+	out, err := r.git(ctx, "diff", "--name-only", "-z", "--diff-filter=U")
+	if err != nil {
+		return 0, err
+	}
+	if out == "" {
+		return 0, mergeErr
+	}
+	paths := strings.Split(strings.TrimSuffix(out, "\x00"), "\x00")
+
+	conflicts := 0
+	for _, path := range paths {
+		conflict, err := r.resolve(ctx, path)
+		if err != nil {
+			// Leave the tree as it was before the merge; the error says
+			// what to fix.
+			_, _ = r.git(ctx, "merge", "--abort")
+			return 0, err
+		}
+		if conflict {
+			conflicts++
+		}
+	}
+	_, err = r.git(ctx, "add", "-A")
+	if err != nil {
+		return 0, err
+	}
+	_, err = r.git(ctx, "commit", "-q", "--no-edit")
+	return conflicts, err
+}
+
+// push pushes the branch to the remote, unless nothing was committed yet.
+func (r GitRepo) push(ctx context.Context) error {
+	if _, err := r.git(ctx, "rev-parse", "-q", "--verify", "HEAD"); err != nil {
+		return nil
+	}
+	_, err := r.git(ctx, "push", "-q", "origin", "HEAD:refs/heads/"+r.branch())
+	return err
+}
+
+// resolve settles a file the merge left in conflict and reports whether
+// it was a note kept in both versions.
+func (r GitRepo) resolve(ctx context.Context, path string) (bool, error) {
+	stages, err := r.stages(ctx, path)
+	if err != nil {
+		return false, err
+	}
+	dir, name, _ := strings.Cut(path, "/")
+	switch {
+	case dir == gitNotesDir && strings.HasSuffix(name, ".md") && !strings.Contains(name, "/"):
+		return r.resolveNote(strings.TrimSuffix(name, ".md"), stages[2], stages[3])
+	case dir == gitTombstonesDir && stages[2] != nil && stages[3] != nil:
+		ours, err := parseGitTombstone(name, stages[2])
+		if err != nil {
+			return false, err
+		}
+		theirs, err := parseGitTombstone(name, stages[3])
+		if err != nil {
+			return false, err
+		}
+		if theirs.DeletedAt.After(ours.DeletedAt) {
+			ours = theirs
+		}
+		return false, writeGitTombstone(r.Dir, ours)
+	}
+	return false, fmt.Errorf("%s changed on both sides, merge it by hand in %s", path, r.Dir)
+}
+
+// stages returns the versions of a file in conflict by stage: 2 for ours
+// and 3 for theirs. A version deleted on one side is missing.
+func (r GitRepo) stages(ctx context.Context, path string) (map[int][]byte, error) {
+	out, err := r.git(ctx, "ls-files", "-u", "-z", "--", path)
+	if err != nil {
+		return nil, err
+	}
+	stages := map[int][]byte{}
+	for _, entry := range strings.FieldsFunc(out, func(c rune) bool { return c == 0 }) {
+		var mode, object string
+		var stage int
+		_, err = fmt.Sscanf(entry, "%s %s %d", &mode, &object, &stage)
+		if err != nil {
+			return nil, fmt.Errorf("reading the git index entry %q: %w", entry, err)
+		}
+		content, err := r.git(ctx, "cat-file", "blob", object)
+		if err != nil {
+			return nil, err
+		}
+		stages[stage] = []byte(content)
+	}
+	return stages, nil
+}
+
+// resolveNote settles a note file changed on both sides, or changed on one
+// side and deleted on the other.
+func (r GitRepo) resolveNote(uuid string, ours, theirs []byte) (bool, error) {
+	var versions []model.Note
+	for _, data := range [][]byte{ours, theirs} {
+		if data == nil {
+			continue
+		}
+		note, err := parseGitNote(uuid, data)
+		if err != nil {
+			return false, err
+		}
+		versions = append(versions, note)
+	}
+
+	switch {
+	case len(versions) == 2 && store.ContentHash(versions[0]) == store.ContentHash(versions[1]):
+		winner := versions[0]
+		if versions[1].UpdatedAt.After(winner.UpdatedAt) {
+			winner = versions[1]
+		}
+		winner.DeletedAt = trashState(versions[0], versions[1])
+		return false, writeGitNote(r.Dir, winner)
+	case len(versions) == 2:
+		winner, copied := conflictVersions(versions[0], versions[1])
+		err := writeGitNote(r.Dir, winner)
+		if err != nil {
+			return false, err
+		}
+		return true, writeGitNote(r.Dir, copied)
+	case len(versions) == 1:
+		// The note was purged on one side: as in Sync, it stays when it
+		// was edited after the purge.
+		tombstones, err := readGitTombstones(r.Dir)
+		if err != nil {
+			return false, err
+		}
+		tombstone, ok := tombstones[uuid]
+		if ok && !versions[0].UpdatedAt.After(tombstone.DeletedAt) {
+			return false, removeIfExists(filepath.Join(r.Dir, gitNotesDir, uuid+".md"))
+		}
+		err = removeIfExists(filepath.Join(r.Dir, gitTombstonesDir, uuid))
+		if err != nil {
+			return false, err
+		}
+		return false, writeGitNote(r.Dir, versions[0])
+	}
+	return false, fmt.Errorf("%s/%s.md: no version to merge", gitNotesDir, uuid)
+}
+
+// readGitTree reads the notes and tombstones of a working tree by UUID.
+func readGitTree(dir string) (map[string]model.Note, map[string]model.Tombstone, error) {
+	notes := map[string]model.Note{}
+	entries, err := os.ReadDir(filepath.Join(dir, gitNotesDir))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, err
+	}
+	for _, entry := range entries {
+		uuid, ok := strings.CutSuffix(entry.Name(), ".md")
+		if !ok || entry.IsDir() || strings.HasPrefix(uuid, ".") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, gitNotesDir, entry.Name()))
+		if err != nil {
+			return nil, nil, err
+		}
+		note, err := parseGitNote(uuid, data)
+		if err != nil {
+			return nil, nil, err
+		}
+		notes[uuid] = note
+	}
+	tombstones, err := readGitTombstones(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	return notes, tombstones, nil
+}
+
+func readGitTombstones(dir string) (map[string]model.Tombstone, error) {
+	tombstones := map[string]model.Tombstone{}
+	entries, err := os.ReadDir(filepath.Join(dir, gitTombstonesDir))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, gitTombstonesDir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		tombstone, err := parseGitTombstone(entry.Name(), data)
+		if err != nil {
+			return nil, err
+		}
+		tombstones[tombstone.UUID] = tombstone
+	}
+	return tombstones, nil
+}
+
+// writeGitTree makes the working tree hold exactly notes and tombstones.
+func writeGitTree(dir string, notes map[string]model.Note, tombstones map[string]model.Tombstone) error {
+	for _, sub := range []string{gitNotesDir, gitTombstonesDir} {
+		err := os.MkdirAll(filepath.Join(dir, sub), 0o755)
+		if err != nil {
+			return err
+		}
+	}
+	for _, uuid := range sortedKeys(notes) {
+		err := writeGitNote(dir, notes[uuid])
+		if err != nil {
+			return err
+		}
+	}
+	for _, uuid := range sortedKeys(tombstones) {
+		err := writeGitTombstone(dir, tombstones[uuid])
+		if err != nil {
+			return err
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, gitNotesDir))
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		uuid, ok := strings.CutSuffix(entry.Name(), ".md")
+		if _, keep := notes[uuid]; ok && !keep && !entry.IsDir() {
+			err = os.Remove(filepath.Join(dir, gitNotesDir, entry.Name()))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	entries, err = os.ReadDir(filepath.Join(dir, gitTombstonesDir))
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if _, keep := tombstones[entry.Name()]; !keep && !entry.IsDir() {
+			err = os.Remove(filepath.Join(dir, gitTombstonesDir, entry.Name()))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func writeGitNote(dir string, note model.Note) error {
+	err := checkGitName(note.UUID)
+	if err != nil {
+		return err
+	}
+	header := gitNoteHeader{
+		Tags:      note.Tags,
+		CreatedAt: note.CreatedAt.UTC(),
+		UpdatedAt: note.UpdatedAt.UTC(),
+	}
+	if header.Tags == nil {
+		header.Tags = []string{}
+	}
+	if !note.DeletedAt.IsZero() {
+		deletedAt := note.DeletedAt.UTC()
+		header.DeletedAt = &deletedAt
+	}
+	front, err := yaml.Marshal(header)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	buf.WriteString(frontMatterDelimiter + "\n")
+	buf.Write(front)
+	buf.WriteString(frontMatterDelimiter + "\n")
+	buf.WriteString(note.Value)
+	buf.WriteString("\n")
+	return os.WriteFile(filepath.Join(dir, gitNotesDir, note.UUID+".md"), buf.Bytes(), 0o644)
+}
+
+// parseGitNote reads a note file written by writeGitNote.
+func parseGitNote(uuid string, data []byte) (model.Note, error) {
+	name := gitNotesDir + "/" + uuid + ".md"
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	rest, ok := strings.CutPrefix(text, frontMatterDelimiter+"\n")
+	if !ok {
+		return model.Note{}, fmt.Errorf("%s: missing front matter", name)
+	}
+	front, body, ok := strings.Cut(rest, "\n"+frontMatterDelimiter+"\n")
+	if !ok {
+		return model.Note{}, fmt.Errorf("%s: unterminated front matter", name)
+	}
+	var header gitNoteHeader
+	err := yaml.Unmarshal([]byte(front), &header)
+	if err != nil {
+		return model.Note{}, fmt.Errorf("%s: front matter: %w", name, err)
+	}
+	note := model.Note{
+		UUID:      uuid,
+		Value:     strings.TrimSuffix(body, "\n"),
+		Tags:      cleanTags(header.Tags),
+		CreatedAt: header.CreatedAt.UTC(),
+		UpdatedAt: header.UpdatedAt.UTC(),
+	}
+	if header.DeletedAt != nil {
+		note.DeletedAt = header.DeletedAt.UTC()
+	}
+	return note, nil
+}
+
+func writeGitTombstone(dir string, tombstone model.Tombstone) error {
+	err := checkGitName(tombstone.UUID)
+	if err != nil {
+		return err
+	}
+	data := tombstone.DeletedAt.UTC().Format(time.RFC3339Nano) + "\n"
+	return os.WriteFile(filepath.Join(dir, gitTombstonesDir, tombstone.UUID), []byte(data), 0o644)
+}
+
+// parseGitTombstone reads a tombstone file, which holds the time the note
+// was purged.
+func parseGitTombstone(uuid string, data []byte) (model.Tombstone, error) {
+	deletedAt, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(string(data)))
+	if err != nil {
+		return model.Tombstone{}, fmt.Errorf("%s/%s: %w", gitTombstonesDir, uuid, err)
+	}
+	return model.Tombstone{UUID: uuid, DeletedAt: deletedAt.UTC()}, nil
+}
+
+// checkGitName rejects UUIDs that would not make a plain file name, such
+// as ones edited by hand in the files backend.
+func checkGitName(uuid string) error {
+	if uuid == "" || strings.HasPrefix(uuid, ".") || strings.ContainsAny(uuid, `/\`) {
+		return fmt.Errorf("note UUID %q cannot be used as a file name", uuid)
+	}
+	return nil
+}
+
+func removeIfExists(path string) error {
+	err := os.Remove(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iamunni/hugnin/model"
+	"github.com/iamunni/hugnin/store"
+)
+
+// newGitRemote returns a bare repository in a temporary directory, and
+// points git at an empty configuration so the tests do not depend on the
+// one of the machine.
+func newGitRemote(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	t.Setenv("GIT_CONFIG_GLOBAL", filepath.Join(dir, "gitconfig"))
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	remote := filepath.Join(dir, "notes.git")
+	out, err := exec.Command("git", "init", "-q", "--bare", remote).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git init --bare: %v: %s", err, out)
+	}
+	return remote
+}
+
+func Test_noteService_SyncGit(t *testing.T) {
+	ctx := context.Background()
+	remote := newGitRemote(t)
+	laptop, workstation := newSyncStore(t), newSyncStore(t)
+	laptopRepo := GitRepo{Dir: filepath.Join(t.TempDir(), "laptop"), Remote: remote}
+	workstationRepo := GitRepo{Dir: filepath.Join(t.TempDir(), "workstation"), Remote: remote}
+	sync := func(s NoteService, repo GitRepo, want SyncReport) {
+		t.Helper()
+		got, err := s.SyncGit(ctx, repo)
+		if err != nil {
+			t.Fatalf("SyncGit() error = %v", err)
+		}
+		if got != want {
+			t.Errorf("SyncGit() = %+v, want %+v", got, want)
+		}
+	}
+	l := NewNoteService(laptop)
+	w := NewNoteService(workstation)
+
+	laptop.Write(ctx, "restart postgres", []string{"db"})
+	workstation.Write(ctx, "drain node\n\nthen cordon it", []string{"k8s"})
+	sync(l, laptopRepo, SyncReport{Pushed: SyncCounts{Added: 1}})
+	sync(w, workstationRepo, SyncReport{Pulled: SyncCounts{Added: 1}, Pushed: SyncCounts{Added: 1}})
+	sync(l, laptopRepo, SyncReport{Pulled: SyncCounts{Added: 1}})
+	if lv, wv := syncedValues(t, laptop), syncedValues(t, workstation); !reflect.DeepEqual(lv, wv) || len(lv) != 2 {
+		t.Errorf("after the first syncs laptop has %v, workstation has %v", lv, wv)
+	}
+	sync(l, laptopRepo, SyncReport{})
+
+	tick()
+	laptop.Update(ctx, model.Note{Id: 1, Value: "restart postgres on the laptop", Tags: []string{"db"}})
+	tick()
+	workstation.Update(ctx, model.Note{Id: 2, Value: "restart postgres on the workstation", Tags: []string{"db"}})
+	sync(l, laptopRepo, SyncReport{Pushed: SyncCounts{Updated: 1}})
+	sync(w, workstationRepo, SyncReport{Pulled: SyncCounts{Added: 1, Updated: 1}, Pushed: SyncCounts{Updated: 1}, Conflicts: 1})
+	sync(l, laptopRepo, SyncReport{Pulled: SyncCounts{Added: 1, Updated: 1}})
+	want := []string{
+		"drain node\n\nthen cordon it k8s",
+		"restart postgres on the laptop db,conflict",
+		"restart postgres on the workstation db,conflict",
+	}
+	for name, s := range map[string]store.Store{"laptop": laptop, "workstation": workstation} {
+		if got := syncedValues(t, s); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s after a conflict = %q, want %q", name, got, want)
+		}
+	}
+
+	tick()
+	laptop.Delete(ctx, model.Note{Tags: []string{"k8s"}})
+	laptop.Purge(ctx, time.Time{})
+	sync(l, laptopRepo, SyncReport{Pushed: SyncCounts{Deleted: 1}})
+	sync(w, workstationRepo, SyncReport{Pulled: SyncCounts{Deleted: 1}})
+	if tombstones, _ := workstation.Tombstones(ctx); len(tombstones) != 1 {
+		t.Errorf("workstation tombstones = %+v, want the purged note", tombstones)
+	}
+
+	log, err := exec.Command("git", "-C", laptopRepo.Dir, "log", "--format=%s").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(log), "Sync notes from ") {
+		t.Errorf("git log = %q, want commits made by SyncGit", log)
+	}
+}
+
+func Test_noteService_SyncGitEditWinsOverOlderPurge(t *testing.T) {
+	ctx := context.Background()
+	remote := newGitRemote(t)
+	laptop, workstation := newSyncStore(t), newSyncStore(t)
+	laptopRepo := GitRepo{Dir: filepath.Join(t.TempDir(), "laptop"), Remote: remote}
+	workstationRepo := GitRepo{Dir: filepath.Join(t.TempDir(), "workstation"), Remote: remote}
+	l := NewNoteService(laptop)
+	w := NewNoteService(workstation)
+
+	laptop.Write(ctx, "rotate keys", nil)
+	if _, err := l.SyncGit(ctx, laptopRepo); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.SyncGit(ctx, workstationRepo); err != nil {
+		t.Fatal(err)
+	}
+
+	laptop.Delete(ctx, model.Note{Id: 1})
+	laptop.Purge(ctx, time.Time{})
+	tick()
+	workstation.Update(ctx, model.Note{Id: 1, Value: "rotate keys monthly"})
+	if _, err := l.SyncGit(ctx, laptopRepo); err != nil {
+		t.Fatal(err)
+	}
+	got, err := w.SyncGit(ctx, workstationRepo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (SyncReport{Pushed: SyncCounts{Updated: 1}}); got != want {
+		t.Errorf("SyncGit() = %+v, want %+v", got, want)
+	}
+	if _, err := l.SyncGit(ctx, laptopRepo); err != nil {
+		t.Fatal(err)
+	}
+	if values := syncedValues(t, laptop); !reflect.DeepEqual(values, []string{"rotate keys monthly "}) {
+		t.Errorf("laptop after SyncGit() = %v, want the edited note back", values)
+	}
+	if tombstones, _ := laptop.Tombstones(ctx); len(tombstones) != 0 {
+		t.Errorf("laptop tombstones = %+v, want the tombstone dropped", tombstones)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(laptopRepo.Dir, "tombstones")); len(entries) != 0 {
+		t.Errorf("tombstones left in the working tree: %v", entries)
+	}
+}
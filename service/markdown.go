@@ -0,0 +1,306 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/iamunni/hugnin/model"
+	"gopkg.in/yaml.v3"
+)
+
+// maxSlugLength caps the length of the file names written by
+// WriteMarkdownDir, in runes.
+const maxSlugLength = 60
+
+// markdownFrontMatter is the YAML header of the files written by
+// WriteMarkdownDir.
+type markdownFrontMatter struct {
+	Id        int64     `yaml:"id"`
+	Tags      []string  `yaml:"tags,flow"`
+	CreatedAt time.Time `yaml:"created_at"`
+	UpdatedAt time.Time `yaml:"updated_at"`
+}
+
+// WriteMarkdownDir writes each note to dir as <slug>.md with a YAML front
+// matter holding its Id, tags and timestamps, creating dir when needed.
+// The slug comes from the first line of the note; notes whose slugs
+// collide get their Id appended.
+func WriteMarkdownDir(dir string, notes []model.Note) error {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return err
+	}
+	used := map[string]bool{}
+	for _, note := range notes {
+		name := markdownName(used, note)
+		used[name] = true
+
+		header, err := yaml.Marshal(markdownFrontMatter{
+			Id:        note.Id,
+			Tags:      note.Tags,
+			CreatedAt: note.CreatedAt,
+			UpdatedAt: note.UpdatedAt,
+		})
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		buf.WriteString(frontMatterDelimiter + "\n")
+		buf.Write(header)
+		buf.WriteString(frontMatterDelimiter + "\n")
+		buf.WriteString(note.Value)
+		buf.WriteString("\n")
+		err = os.WriteFile(filepath.Join(dir, name+".md"), buf.Bytes(), 0o644)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markdownName returns the file name of note, without its extension: its
+// slug, or note-<id> when it has none, with its Id and then a counter
+// appended for as long as the name is in used.
+func markdownName(used map[string]bool, note model.Note) string {
+	name := Slug(note.Value)
+	if name == "" {
+		name = fmt.Sprintf("note-%d", note.Id)
+	} else if used[name] {
+		name = fmt.Sprintf("%s-%d", name, note.Id)
+	}
+	for base, n := name, 2; used[name]; n++ {
+		name = fmt.Sprintf("%s-%d", base, n)
+	}
+	return name
+}
+
+// Slug turns the first line of text into a lower case file name made of
+// letters, digits and dashes. Leading Markdown heading marks are dropped.
+func Slug(text string) string {
+	first, _, _ := strings.Cut(text, "\n")
+	first = strings.TrimLeft(strings.TrimSpace(first), "# ")
+
+	var sb strings.Builder
+	runes, dash := 0, false
+	for _, r := range strings.ToLower(first) {
+		if runes == maxSlugLength {
+			break
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && sb.Len() > 0 {
+				sb.WriteByte('-')
+				runes++
+			}
+			sb.WriteRune(r)
+			runes++
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	return strings.TrimSuffix(sb.String(), "-")
+}
+
+// ReadMarkdownDir reads every .md file below dir, such as a directory
+// written by WriteMarkdownDir or an Obsidian vault, into notes ordered by
+// path. Hidden files and directories (.obsidian, .git, ...) are skipped.
+//
+// The YAML front matter is optional. Its id, tags and created_at or
+// updated_at keys are used when present, created, date, updated and
+// modified are understood too, and missing timestamps default to the
+// modification time of the file. Inline #hashtags outside code are added
+// to the tags, and a file without a body becomes a note holding its name.
+func ReadMarkdownDir(dir string) ([]model.Note, error) {
+	var notes []model.Note
+	paths := map[int64]string{}
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(path), ".md") {
+			return nil
+		}
+		note, err := readMarkdownFile(path)
+		if err != nil {
+			return err
+		}
+		if other, ok := paths[note.Id]; ok && note.Id != 0 {
+			return fmt.Errorf("%s and %s both have id %d", other, path, note.Id)
+		}
+		paths[note.Id] = path
+		notes = append(notes, note)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+// markdownFileHeader is the front matter read by ReadMarkdownDir. Keys
+// other tools use for the same data are accepted as aliases.
+type markdownFileHeader struct {
+	Id        int64           `yaml:"id"`
+	Tags      frontMatterTags `yaml:"tags"`
+	CreatedAt frontMatterTime `yaml:"created_at"`
+	Created   frontMatterTime `yaml:"created"`
+	Date      frontMatterTime `yaml:"date"`
+	UpdatedAt frontMatterTime `yaml:"updated_at"`
+	Updated   frontMatterTime `yaml:"updated"`
+	Modified  frontMatterTime `yaml:"modified"`
+}
+
+func readMarkdownFile(path string) (model.Note, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return model.Note{}, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return model.Note{}, err
+	}
+	text := strings.ReplaceAll(strings.TrimPrefix(string(data), "\ufeff"), "\r\n", "\n")
+
+	var header markdownFileHeader
+	body := text
+	if rest, ok := strings.CutPrefix(text, frontMatterDelimiter+"\n"); ok {
+		front, after, found := strings.Cut(rest, "\n"+frontMatterDelimiter+"\n")
+		if !found {
+			front, found = strings.CutSuffix(rest, "\n"+frontMatterDelimiter)
+		}
+		if found {
+			err = yaml.Unmarshal([]byte(front), &header)
+			if err != nil {
+				return model.Note{}, fmt.Errorf("%s: front matter: %w", path, err)
+			}
+			body = after
+		}
+	}
+
+	note := model.Note{
+		Id:        header.Id,
+		Value:     strings.Trim(body, "\n"),
+		CreatedAt: firstTime(header.CreatedAt, header.Created, header.Date, frontMatterTime(info.ModTime())),
+		UpdatedAt: firstTime(header.UpdatedAt, header.Updated, header.Modified, frontMatterTime(info.ModTime())),
+	}
+	if strings.TrimSpace(note.Value) == "" {
+		note.Value = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	note.Tags = mergeTags(header.Tags, Hashtags(note.Value))
+	return note, nil
+}
+
+// hashtagPattern matches #tag after the start of a line or a space. Tags
+// may nest with "/" and must not be all digits, as in Obsidian.
+var hashtagPattern = regexp.MustCompile(`(?:^|\s)#([\p{L}\p{N}_/-]*[\p{L}_/-][\p{L}\p{N}_/-]*)`)
+
+// Hashtags returns the inline #hashtags of a Markdown text, ignoring
+// fenced code blocks and inline code.
+func Hashtags(text string) []string {
+	var tags []string
+	fenced := false
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			fenced = !fenced
+			continue
+		}
+		if fenced {
+			continue
+		}
+		parts := strings.Split(line, "`")
+		for i := 0; i < len(parts); i += 2 {
+			for _, match := range hashtagPattern.FindAllStringSubmatch(parts[i], -1) {
+				tags = append(tags, strings.Trim(match[1], "/"))
+			}
+		}
+	}
+	return tags
+}
+
+// mergeTags joins tag lists, dropping empty and repeated tags while
+// keeping the first spelling and order.
+func mergeTags(lists ...[]string) []string {
+	var result []string
+	seen := map[string]bool{}
+	for _, list := range lists {
+		for _, tag := range cleanTags(list) {
+			if !seen[tag] {
+				seen[tag] = true
+				result = append(result, tag)
+			}
+		}
+	}
+	return result
+}
+
+// frontMatterTags reads tags written as a YAML list or as one comma or
+// space separated string, with or without a leading "#".
+type frontMatterTags []string
+
+func (t *frontMatterTags) UnmarshalYAML(node *yaml.Node) error {
+	var values []string
+	switch node.Kind {
+	case yaml.SequenceNode:
+		err := node.Decode(&values)
+		if err != nil {
+			return err
+		}
+	case yaml.ScalarNode:
+		values = strings.FieldsFunc(node.Value, func(r rune) bool { return r == ',' || unicode.IsSpace(r) })
+	default:
+		return fmt.Errorf("line %d: tags must be a list or a string", node.Line)
+	}
+	for i := range values {
+		values[i] = strings.TrimPrefix(strings.TrimSpace(values[i]), "#")
+	}
+	*t = values
+	return nil
+}
+
+// frontMatterLayouts are the timestamp forms accepted in front matter.
+var frontMatterLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// frontMatterTime reads a front matter timestamp. Values in no known
+// layout, such as unexpanded template placeholders, are left zero.
+type frontMatterTime time.Time
+
+func (t *frontMatterTime) UnmarshalYAML(node *yaml.Node) error {
+	for _, layout := range frontMatterLayouts {
+		parsed, err := time.ParseInLocation(layout, strings.TrimSpace(node.Value), time.Local)
+		if err == nil {
+			*t = frontMatterTime(parsed)
+			return nil
+		}
+	}
+	return nil
+}
+
+// firstTime returns the first non zero time, in UTC.
+func firstTime(times ...frontMatterTime) time.Time {
+	for _, t := range times {
+		if !time.Time(t).IsZero() {
+			return time.Time(t).UTC()
+		}
+	}
+	return time.Time{}
+}
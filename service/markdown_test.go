@@ -0,0 +1,164 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iamunni/hugnin/model"
+)
+
+func TestMarkdownDir_RoundTrip(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "notes")
+	stamp := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	want := []model.Note{
+		{Id: 1, Value: "# Restart postgres\n\nsudo systemctl restart postgresql", Tags: []string{"db", "oncall"}, CreatedAt: stamp, UpdatedAt: stamp.Add(time.Hour)},
+		{Id: 2, Value: "Restart postgres", CreatedAt: stamp, UpdatedAt: stamp},
+		{Id: 3, Value: "---", Tags: []string{"odd"}, CreatedAt: stamp, UpdatedAt: stamp},
+	}
+	if err := WriteMarkdownDir(dir, want); err != nil {
+		t.Fatalf("WriteMarkdownDir() error = %v", err)
+	}
+	for _, name := range []string{"restart-postgres.md", "restart-postgres-2.md", "note-3.md"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("WriteMarkdownDir() did not write %s: %v", name, err)
+		}
+	}
+
+	got, err := ReadMarkdownDir(dir)
+	if err != nil {
+		t.Fatalf("ReadMarkdownDir() error = %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("ReadMarkdownDir() = %v, want %v", got, want)
+	}
+	byId := map[int64]model.Note{}
+	for _, note := range got {
+		byId[note.Id] = note
+	}
+	for _, note := range want {
+		if !reflect.DeepEqual(byId[note.Id], note) {
+			t.Errorf("note %d = %+v, want %+v", note.Id, byId[note.Id], note)
+		}
+	}
+}
+
+func TestWriteMarkdownDir_CollidingNames(t *testing.T) {
+	dir := t.TempDir()
+	notes := []model.Note{
+		{Id: 1, Value: "foo"},
+		{Id: 2, Value: "foo 3"},
+		{Id: 3, Value: "foo"},
+		{Id: 4, Value: "note 5"},
+		{Id: 5, Value: "!"},
+	}
+	if err := WriteMarkdownDir(dir, notes); err != nil {
+		t.Fatalf("WriteMarkdownDir() error = %v", err)
+	}
+	want := map[string]int64{"foo.md": 1, "foo-3.md": 2, "foo-3-2.md": 3, "note-5.md": 4, "note-5-2.md": 5}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(want) {
+		t.Errorf("WriteMarkdownDir() wrote %d files, want %d", len(entries), len(want))
+	}
+	got, err := ReadMarkdownDir(dir)
+	if err != nil {
+		t.Fatalf("ReadMarkdownDir() error = %v", err)
+	}
+	byId := map[int64]model.Note{}
+	for _, note := range got {
+		byId[note.Id] = note
+	}
+	for name, id := range want {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("WriteMarkdownDir() did not write %s: %v", name, err)
+			continue
+		}
+		if value := notes[id-1].Value + "\n"; !strings.HasSuffix(string(content), "---\n"+value) {
+			t.Errorf("%s = %q, want note %d", name, content, id)
+		}
+		if byId[id].Value != notes[id-1].Value {
+			t.Errorf("note %d read back as %q, want %q", id, byId[id].Value, notes[id-1].Value)
+		}
+	}
+}
+
+func TestReadMarkdownDir_Obsidian(t *testing.T) {
+	vault := t.TempDir()
+	files := map[string]string{
+		"Inbox/Kafka rebalancing.md": "---\ntags: [infra, \"#kafka\"]\ncreated: 2023-05-04 10:15\naliases: [kafka]\n---\nConsumers stall during #incident/2023 rebalances.\nSee issue #42 and `#not-a-tag`.\n\n```sh\n# comment, not a tag\necho #nope\n```\n",
+		"Zettel/202301011200.md":     "tags: not front matter\n#zettel #zettel\n",
+		"Empty idea.md":              "---\ntags: idea, later\ndate: \"{{date}}\"\n---\n",
+		".obsidian/workspace.md":     "# ignored",
+		"attachments/diagram.png":    "png",
+	}
+	for name, content := range files {
+		path := filepath.Join(vault, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(vault, "Empty idea.md"), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadMarkdownDir(vault)
+	if err != nil {
+		t.Fatalf("ReadMarkdownDir() error = %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("ReadMarkdownDir() read %d notes, want 3: %v", len(got), got)
+	}
+
+	idea := got[0]
+	if idea.Value != "Empty idea" || !reflect.DeepEqual(idea.Tags, []string{"idea", "later"}) || !idea.CreatedAt.Equal(mtime) {
+		t.Errorf("empty note = %+v", idea)
+	}
+	kafka := got[1]
+	if !reflect.DeepEqual(kafka.Tags, []string{"infra", "kafka", "incident/2023"}) {
+		t.Errorf("kafka tags = %v", kafka.Tags)
+	}
+	if want := time.Date(2023, 5, 4, 10, 15, 0, 0, time.Local).UTC(); !kafka.CreatedAt.Equal(want) {
+		t.Errorf("kafka created = %v, want %v", kafka.CreatedAt, want)
+	}
+	zettel := got[2]
+	if zettel.Value != "tags: not front matter\n#zettel #zettel" || !reflect.DeepEqual(zettel.Tags, []string{"zettel"}) {
+		t.Errorf("zettel = %+v", zettel)
+	}
+}
+
+func TestReadMarkdownDir_RepeatedId(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.md", "b.md"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("---\nid: 4\n---\ntext\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := ReadMarkdownDir(dir); err == nil {
+		t.Error("ReadMarkdownDir() expected an error for a repeated id")
+	}
+}
+
+func TestSlug(t *testing.T) {
+	tests := map[string]string{
+		"Restart postgres":                 "restart-postgres",
+		"## Ünïcode — notes!\nsecond line": "ünïcode-notes",
+		"  ...  ":                          "",
+		"a/b\\c:d":                         "a-b-c-d",
+	}
+	for text, want := range tests {
+		if got := Slug(text); got != want {
+			t.Errorf("Slug(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
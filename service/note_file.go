@@ -0,0 +1,63 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/iamunni/hugnin/model"
+)
+
+const frontMatterDelimiter = "---"
+
+// FormatNoteFile renders note as the text opened by `hugnin edit`: a small
+// front matter header holding the Id and tags, followed by the note body.
+func FormatNoteFile(note model.Note) string {
+	var sb strings.Builder
+	sb.WriteString(frontMatterDelimiter + "\n")
+	sb.WriteString(fmt.Sprintf("id: %d\n", note.Id))
+	sb.WriteString(fmt.Sprintf("tags: %s\n", strings.Join(note.Tags, ", ")))
+	sb.WriteString(frontMatterDelimiter + "\n")
+	sb.WriteString(note.Value)
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// ParseNoteFile reads text in the FormatNoteFile layout back into a note.
+// Trailing newlines of the body are dropped.
+func ParseNoteFile(text string) (model.Note, error) {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != frontMatterDelimiter {
+		return model.Note{}, fmt.Errorf("line 1: expected %q to open the front matter", frontMatterDelimiter)
+	}
+
+	var note model.Note
+	for i := 1; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == frontMatterDelimiter {
+			note.Value = strings.TrimRight(strings.Join(lines[i+1:], "\n"), "\n")
+			return note, nil
+		}
+		if line == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return model.Note{}, fmt.Errorf("line %d: expected \"key: value\", got %q", i+1, line)
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "id":
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return model.Note{}, fmt.Errorf("line %d: invalid id %q", i+1, value)
+			}
+			note.Id = id
+		case "tags":
+			note.Tags = cleanTags(strings.Split(value, ","))
+		default:
+			return model.Note{}, fmt.Errorf("line %d: unknown front matter key %q", i+1, key)
+		}
+	}
+	return model.Note{}, fmt.Errorf("front matter is not closed with %q", frontMatterDelimiter)
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/iamunni/hugnin/model"
+)
+
+func TestNoteFile_RoundTrip(t *testing.T) {
+	notes := []model.Note{
+		{Id: 1, Value: "single line", Tags: []string{"a", "b"}},
+		{Id: 2, Value: "first line\n\nthird line"},
+		{Id: 3, Value: "--- not a delimiter inside the body", Tags: []string{"x"}},
+	}
+	for _, note := range notes {
+		got, err := ParseNoteFile(FormatNoteFile(note))
+		if err != nil {
+			t.Fatalf("ParseNoteFile() error = %v", err)
+		}
+		if !reflect.DeepEqual(got, note) {
+			t.Errorf("ParseNoteFile(FormatNoteFile()) = %#v, want %#v", got, note)
+		}
+	}
+}
+
+func TestParseNoteFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    model.Note
+		wantErr bool
+	}{
+		{
+			name: "edited tags and body",
+			text: "---\nid: 4\ntags: db , , oncall\n---\nnew body\n\n",
+			want: model.Note{Id: 4, Value: "new body", Tags: []string{"db", "oncall"}},
+		},
+		{
+			name: "windows line endings",
+			text: "---\r\nid: 4\r\ntags:\r\n---\r\nbody\r\n",
+			want: model.Note{Id: 4, Value: "body"},
+		},
+		{
+			name:    "missing front matter",
+			text:    "just a body\n",
+			wantErr: true,
+		},
+		{
+			name:    "unclosed front matter",
+			text:    "---\nid: 4\nbody\n",
+			wantErr: true,
+		},
+		{
+			name:    "unknown key",
+			text:    "---\ntitle: x\n---\nbody\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid id",
+			text:    "---\nid: four\n---\nbody\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseNoteFile(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseNoteFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseNoteFile() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
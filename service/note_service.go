@@ -1,21 +1,107 @@
 package service
 
 import (
+	"context"
 	"fmt"
-	"os"
-	"strconv"
+	"sort"
 	"strings"
+	"time"
+	"unicode"
 
+	"github.com/iamunni/hugnin/filter"
 	"github.com/iamunni/hugnin/model"
 	"github.com/iamunni/hugnin/store"
-	"github.com/olekukonko/tablewriter"
 )
 
+// NoteService holds the note use cases. It returns data and leaves
+// presentation to the caller.
 type NoteService interface {
-	Add(note model.Note) error
-	View(note model.Note) error
-	Delete(note model.Note) error
-	Search(keyword string) error
+	// Add stores a new note and returns it with its new Id.
+	Add(ctx context.Context, note model.Note) (model.Note, error)
+	// View returns the live notes selected by note and period. Tags select
+	// the notes carrying them or a tag below them, as "work/oncall" is
+	// below "work".
+	View(ctx context.Context, note model.Note, period model.DateRange) ([]model.Note, error)
+	// ViewExact is View with tags selecting only the notes carrying them.
+	ViewExact(ctx context.Context, note model.Note, period model.DateRange) ([]model.Note, error)
+	Get(ctx context.Context, id int64) (model.Note, error)
+	// Update replaces the text and tags of note.Id and returns the note as
+	// stored.
+	Update(ctx context.Context, note model.Note) (model.Note, error)
+	// Delete moves the selected notes to the trash and returns how many
+	// were moved.
+	Delete(ctx context.Context, note model.Note) (int64, error)
+	Search(ctx context.Context, keyword string, period model.DateRange) ([]model.Note, error)
+	// Find returns the live notes matching query, written in the language
+	// of package filter, and created inside period. The dates of query
+	// take the forms of ParseDateSpan. A malformed query returns a
+	// *filter.Error.
+	Find(ctx context.Context, query string, period model.DateRange) ([]model.Note, error)
+	// FindParams is Find for a query with parameters, see
+	// filter.ParseParams. It refuses params the query does not take.
+	FindParams(ctx context.Context, query string, params map[string]string, period model.DateRange) ([]model.Note, error)
+	// SaveSearch saves query under name for FindParams, replacing the
+	// search saved under that name if any. The query is checked first.
+	SaveSearch(ctx context.Context, name, query string) error
+	// SavedSearch returns the search saved under name.
+	SavedSearch(ctx context.Context, name string) (model.SavedSearch, error)
+	SavedSearches(ctx context.Context) ([]model.SavedSearch, error)
+	DeleteSavedSearch(ctx context.Context, name string) error
+	// SearchWithin is Search limited to the notes matching query, as
+	// Find selects them.
+	SearchWithin(ctx context.Context, keyword, query string, period model.DateRange) ([]model.Note, error)
+	// Tags returns every tag with the number of live notes carrying it
+	// and when one of them was last updated.
+	Tags(ctx context.Context) ([]model.Tag, error)
+	// TagTree returns the tags as a hierarchy split on "/", counting for
+	// each tag the live notes carrying it or a tag below it.
+	TagTree(ctx context.Context) ([]model.TagNode, error)
+	// RenameTag renames a tag and the tags below it, so that work/oncall
+	// follows work, on every note carrying them, trashed ones included, and
+	// returns how many notes changed. It refuses a new name that is already
+	// a tag; MergeTags joins those.
+	RenameTag(ctx context.Context, from, to string) (int64, error)
+	// MergeTags replaces the tags from with the tag into on every note
+	// carrying one of them, in a single transaction, and returns how many
+	// notes changed. into may be new or one of from.
+	MergeTags(ctx context.Context, from []string, into string) (int64, error)
+	// PruneTags removes the tags no note carries and returns how many were
+	// removed.
+	PruneTags(ctx context.Context) (int64, error)
+	// History returns every version of a note, oldest first. The last one
+	// is the note as it is now.
+	History(ctx context.Context, id int64) ([]model.Revision, error)
+	// Diff returns the unified diff between versions from and to of a note.
+	// A zero to selects the current version and a zero from the version
+	// before to.
+	Diff(ctx context.Context, id int64, from, to int) (string, error)
+	// Revert makes an earlier version of a note current again, keeping the
+	// replaced version in the history, and returns the note.
+	Revert(ctx context.Context, id int64, number int) (model.Note, error)
+	Trash(ctx context.Context) ([]model.Note, error)
+	// Restore moves a note out of the trash and returns it.
+	Restore(ctx context.Context, id int64) (model.Note, error)
+	// EmptyTrash permanently removes every trashed note.
+	EmptyTrash(ctx context.Context) (int64, error)
+	// PurgeTrash permanently removes the notes trashed before cutoff. A
+	// zero cutoff purges nothing.
+	PurgeTrash(ctx context.Context, cutoff time.Time) (int64, error)
+	// Export returns every note, trashed ones included, ordered by Id.
+	Export(ctx context.Context) ([]model.Note, error)
+	// Import writes notes in a single transaction and returns how many
+	// were added.
+	Import(ctx context.Context, notes []model.Note, mode store.ImportMode) (int64, error)
+	// Rekey re-encrypts every encrypted note and revision from one
+	// passphrase to another in a single transaction and returns how many
+	// were changed. Nothing changes when any of them does not decrypt.
+	Rekey(ctx context.Context, from, to *Crypter) (int64, error)
+	// Sync reconciles the notes with another store in both directions and
+	// reports what moved each way.
+	Sync(ctx context.Context, remote store.Store) (SyncReport, error)
+	// SyncGit commits the notes to a git working tree, merges the remote
+	// of the tree into it and reads the result back, pushing it when there
+	// is a remote.
+	SyncGit(ctx context.Context, repo GitRepo) (SyncReport, error)
 }
 
 type noteService struct {
@@ -28,59 +114,425 @@ func NewNoteService(store store.Store) NoteService {
 	}
 }
 
-func (n *noteService) Add(note model.Note) error {
+func (n *noteService) Add(ctx context.Context, note model.Note) (model.Note, error) {
 	if len(note.Value) == 0 {
-		return fmt.Errorf("%s", "note value not passed error")
+		return model.Note{}, fmt.Errorf("%s", "note value not passed error")
 	}
-	var tags []string
-	for _, tag := range strings.Split(note.Tag, ",") {
-		tags = append(tags, strings.TrimSpace(tag))
+	tags := cleanTags(note.Tags)
+	id, err := n.store.Write(ctx, note.Value, tags)
+	if err != nil {
+		return model.Note{}, err
 	}
-	err := n.store.Write(note.Value, tags)
+	return model.Note{Id: id, Value: note.Value, Tags: tags}, nil
+}
+
+// Get returns the note with the given Id or store.ErrNotFound.
+func (n *noteService) Get(ctx context.Context, id int64) (model.Note, error) {
+	if id <= 0 {
+		return model.Note{}, fmt.Errorf("invalid note id %d", id)
+	}
+	result, err := n.store.Read(ctx, model.Note{Id: id}, model.DateRange{})
 	if err != nil {
-		return err
+		return model.Note{}, err
 	}
-	return nil
+	if len(result) == 0 {
+		return model.Note{}, fmt.Errorf("note %d: %w", id, store.ErrNotFound)
+	}
+	return result[0], nil
 }
 
-func (n *noteService) View(note model.Note) error {
-	result, err := n.store.Read(note)
+func (n *noteService) Update(ctx context.Context, note model.Note) (model.Note, error) {
+	if note.Id <= 0 {
+		return model.Note{}, fmt.Errorf("invalid note id %d", note.Id)
+	}
+	if len(note.Value) == 0 {
+		return model.Note{}, fmt.Errorf("%s", "note value not passed error")
+	}
+	current, err := n.Get(ctx, note.Id)
 	if err != nil {
-		return err
+		return model.Note{}, err
+	}
+	// Readers see encrypted notes as EncryptedPlaceholder, so sending that
+	// back, or the ciphertext itself, keeps the stored text.
+	if IsEncrypted(current.Value) && !IsEncrypted(note.Value) {
+		if note.Value != EncryptedPlaceholder {
+			return model.Note{}, fmt.Errorf("note %d: %w", note.Id, ErrEncrypted)
+		}
+		note.Value = current.Value
+	}
+	note.Tags = cleanTags(note.Tags)
+	err = n.store.Update(ctx, note)
+	if err != nil {
+		return model.Note{}, err
 	}
-	print(result)
-	return nil
+	return note, nil
 }
 
-func (n *noteService) Search(keyword string) error {
-	result, err := n.store.Search(keyword)
+func (n *noteService) View(ctx context.Context, note model.Note, period model.DateRange) ([]model.Note, error) {
+	note.Tags = cleanTags(note.Tags)
+	result, err := n.store.Read(ctx, note, period)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	return result, nil
+}
+
+func (n *noteService) ViewExact(ctx context.Context, note model.Note, period model.DateRange) ([]model.Note, error) {
+	result, err := n.View(ctx, note, period)
+	if err != nil || len(note.Tags) == 0 {
+		return result, err
 	}
-	print(result)
-	return nil
+	tags := cleanTags(note.Tags)
+	var exact []model.Note
+	for _, found := range result {
+		for _, tag := range found.Tags {
+			if containsTag(tags, tag) {
+				exact = append(exact, found)
+				break
+			}
+		}
+	}
+	return exact, nil
+}
+
+func (n *noteService) Search(ctx context.Context, keyword string, period model.DateRange) ([]model.Note, error) {
+	result, err := n.store.Search(ctx, keyword, period)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
-func (n *noteService) Delete(note model.Note) error {
-	err := n.store.Delete(note)
+func (n *noteService) Find(ctx context.Context, query string, period model.DateRange) ([]model.Note, error) {
+	return n.FindParams(ctx, query, nil, period)
+}
+
+func (n *noteService) FindParams(ctx context.Context, query string, params map[string]string, period model.DateRange) ([]model.Note, error) {
+	if len(params) > 0 {
+		names, err := filter.Params(query)
+		if err != nil {
+			return nil, err
+		}
+		for _, param := range sortedKeys(params) {
+			if !containsTag(names, param) {
+				return nil, fmt.Errorf("unknown parameter %q, the query takes %s", param, describeParams(names))
+			}
+		}
+	}
+	expr, err := parseQuery(query, params)
+	if err != nil {
+		return nil, err
+	}
+	return n.store.Find(ctx, expr, period)
+}
+
+// describeParams lists parameter names for error messages.
+func describeParams(names []string) string {
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
+
+func (n *noteService) SaveSearch(ctx context.Context, name, query string) error {
+	if !validSearchName(name) {
+		return fmt.Errorf("invalid saved search name %q, use letters, digits, - and _", name)
+	}
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return fmt.Errorf("%s", "query not passed error")
+	}
+	_, err := filter.Params(query)
 	if err != nil {
 		return err
 	}
-	return nil
+	return n.store.SaveSearch(ctx, model.SavedSearch{Name: name, Query: query})
 }
 
-func print(notes []model.Note) {
-	var data = [][]string{}
+func (n *noteService) SavedSearch(ctx context.Context, name string) (model.SavedSearch, error) {
+	searches, err := n.store.SavedSearches(ctx)
+	if err != nil {
+		return model.SavedSearch{}, err
+	}
+	for _, search := range searches {
+		if search.Name == name {
+			return search, nil
+		}
+	}
+	return model.SavedSearch{}, fmt.Errorf("saved search %q: %w", name, store.ErrNotFound)
+}
 
-	for _, note := range notes {
-		data = append(data, []string{strconv.Itoa(int(note.Id)), note.Value, note.Tag})
+func (n *noteService) SavedSearches(ctx context.Context) ([]model.SavedSearch, error) {
+	return n.store.SavedSearches(ctx)
+}
+
+func (n *noteService) DeleteSavedSearch(ctx context.Context, name string) error {
+	return n.store.DeleteSavedSearch(ctx, name)
+}
+
+// validSearchName reports whether name can be typed as @name: it is made
+// of letters, digits, "-" and "_".
+func validSearchName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' {
+			return false
+		}
 	}
+	return true
+}
 
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"Id", "Note", "Tag"})
+func (n *noteService) SearchWithin(ctx context.Context, keyword, query string, period model.DateRange) ([]model.Note, error) {
+	expr, err := parseQuery(query, nil)
+	if err != nil {
+		return nil, err
+	}
+	found, err := n.store.Search(ctx, keyword, period)
+	if err != nil || expr == nil {
+		return found, err
+	}
+	matching, err := n.store.Find(ctx, expr, period)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[int64]bool, len(matching))
+	for _, note := range matching {
+		ids[note.Id] = true
+	}
+	var result []model.Note
+	for _, note := range found {
+		if ids[note.Id] {
+			result = append(result, note)
+		}
+	}
+	return result, nil
+}
+
+// parseQuery parses a query of Find with params, reading its dates
+// relative to the current time.
+func parseQuery(query string, params map[string]string) (filter.Expr, error) {
+	return filter.ParseParams(query, func(value string) (time.Time, time.Time, error) {
+		return ParseDateSpan(value, time.Now())
+	}, params)
+}
+
+func (n *noteService) Delete(ctx context.Context, note model.Note) (int64, error) {
+	deleted, err := n.store.Delete(ctx, note)
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+func (n *noteService) Tags(ctx context.Context) ([]model.Tag, error) {
+	result, err := n.store.Tags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
 
-	for _, v := range data {
-		table.Append(v)
+func (n *noteService) RenameTag(ctx context.Context, from, to string) (int64, error) {
+	from, to = cleanTag(from), cleanTag(to)
+	if from == "" || to == "" {
+		return 0, fmt.Errorf("%s", "tag name not passed error")
+	}
+	if from == to {
+		return 0, fmt.Errorf("tag %q already has that name", from)
+	}
+	tags, err := n.store.Tags(ctx)
+	if err != nil {
+		return 0, err
+	}
+	existing := make(map[string]bool, len(tags))
+	renames := map[string]string{}
+	for _, tag := range tags {
+		existing[tag.Name] = true
+		if tag.Name == from || strings.HasPrefix(tag.Name, from+"/") {
+			renames[tag.Name] = to + strings.TrimPrefix(tag.Name, from)
+		}
+	}
+	if len(renames) == 0 {
+		return 0, fmt.Errorf("tag %q: %w", from, store.ErrNotFound)
 	}
-	table.Render()
+	for _, tag := range tags {
+		if into, ok := renames[tag.Name]; ok && existing[into] {
+			return 0, fmt.Errorf("tag %q already exists, merge %q into it instead", into, tag.Name)
+		}
+	}
+	return n.store.RenameTags(ctx, renames)
+}
+
+func (n *noteService) MergeTags(ctx context.Context, from []string, into string) (int64, error) {
+	from, into = cleanTags(from), cleanTag(into)
+	if len(from) == 0 || into == "" {
+		return 0, fmt.Errorf("%s", "tag name not passed error")
+	}
+	return n.store.MergeTags(ctx, from, into)
+}
+
+func (n *noteService) PruneTags(ctx context.Context) (int64, error) {
+	return n.store.PruneTags(ctx)
+}
+
+func (n *noteService) History(ctx context.Context, id int64) ([]model.Revision, error) {
+	current, err := n.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	history, err := n.store.Revisions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return append(history, model.Revision{
+		NoteId:    current.Id,
+		Number:    len(history) + 1,
+		Value:     current.Value,
+		Tags:      current.Tags,
+		CreatedAt: current.UpdatedAt,
+	}), nil
+}
+
+func (n *noteService) Diff(ctx context.Context, id int64, from, to int) (string, error) {
+	history, err := n.History(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if to == 0 {
+		to = len(history)
+	}
+	if from == 0 {
+		from = max(to-1, 1)
+	}
+	older, err := findRevision(history, from)
+	if err != nil {
+		return "", err
+	}
+	newer, err := findRevision(history, to)
+	if err != nil {
+		return "", err
+	}
+	return UnifiedDiff(
+		fmt.Sprintf("note %d revision %d", id, from),
+		fmt.Sprintf("note %d revision %d", id, to),
+		FormatNoteFile(revisionNote(older)),
+		FormatNoteFile(revisionNote(newer)),
+	), nil
+}
+
+func (n *noteService) Revert(ctx context.Context, id int64, number int) (model.Note, error) {
+	history, err := n.History(ctx, id)
+	if err != nil {
+		return model.Note{}, err
+	}
+	revision, err := findRevision(history, number)
+	if err != nil {
+		return model.Note{}, err
+	}
+	if number == len(history) {
+		return model.Note{}, fmt.Errorf("note %d: revision %d is the current version", id, number)
+	}
+	return n.Update(ctx, revisionNote(revision))
+}
+
+func (n *noteService) Trash(ctx context.Context) ([]model.Note, error) {
+	result, err := n.store.Trashed(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (n *noteService) Restore(ctx context.Context, id int64) (model.Note, error) {
+	if id <= 0 {
+		return model.Note{}, fmt.Errorf("invalid note id %d", id)
+	}
+	err := n.store.Restore(ctx, id)
+	if err != nil {
+		return model.Note{}, err
+	}
+	return n.Get(ctx, id)
+}
+
+func (n *noteService) EmptyTrash(ctx context.Context) (int64, error) {
+	return n.store.Purge(ctx, time.Time{})
+}
+
+func (n *noteService) PurgeTrash(ctx context.Context, cutoff time.Time) (int64, error) {
+	if cutoff.IsZero() {
+		return 0, nil
+	}
+	return n.store.Purge(ctx, cutoff)
+}
+
+func (n *noteService) Export(ctx context.Context) ([]model.Note, error) {
+	live, err := n.store.Read(ctx, model.Note{}, model.DateRange{})
+	if err != nil {
+		return nil, err
+	}
+	trashed, err := n.store.Trashed(ctx)
+	if err != nil {
+		return nil, err
+	}
+	notes := append(live, trashed...)
+	sort.Slice(notes, func(i, j int) bool { return notes[i].Id < notes[j].Id })
+	return notes, nil
+}
+
+func (n *noteService) Import(ctx context.Context, notes []model.Note, mode store.ImportMode) (int64, error) {
+	return n.store.Import(ctx, notes, mode)
+}
+
+func (n *noteService) Rekey(ctx context.Context, from, to *Crypter) (int64, error) {
+	return n.store.Rewrite(ctx, func(text string) (string, error) {
+		if !IsEncrypted(text) {
+			return text, nil
+		}
+		plain, err := from.Decrypt(text)
+		if err != nil {
+			return "", err
+		}
+		return to.Encrypt(plain)
+	})
+}
+
+// findRevision returns version number of a note history.
+func findRevision(history []model.Revision, number int) (model.Revision, error) {
+	if number < 1 || number > len(history) {
+		return model.Revision{}, fmt.Errorf("note %d has revisions 1 to %d, not %d", history[0].NoteId, len(history), number)
+	}
+	return history[number-1], nil
+}
+
+func revisionNote(revision model.Revision) model.Note {
+	return model.Note{Id: revision.NoteId, Value: revision.Value, Tags: revision.Tags}
+}
+
+// cleanTags cleans the tags with cleanTag and drops the empty ones.
+func cleanTags(tags []string) []string {
+	var result []string
+	for _, tag := range tags {
+		tag = cleanTag(tag)
+		if tag != "" {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+func containsTag(tags []string, tag string) bool {
+	for _, have := range tags {
+		if have == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanTag trims spaces and the slashes around a hierarchical tag, so
+// "work/" names the same tag as "work".
+func cleanTag(tag string) string {
+	return strings.TrimSpace(strings.Trim(strings.TrimSpace(tag), "/"))
 }
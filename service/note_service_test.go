@@ -1,87 +1,104 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/iamunni/hugnin/filter"
 	"github.com/iamunni/hugnin/model"
 	"github.com/iamunni/hugnin/store"
 )
 
-func (m *mockStore) Write(value string, tags []string) error {
-	if len(value) == 0 {
-		return fmt.Errorf("%s", "note value not passed error")
+// newTestStore returns an opened memory store holding notes 1 and 2, the
+// second with one earlier revision, and notes 3 and 4 in the trash.
+func newTestStore(t *testing.T) store.Store {
+	t.Helper()
+	ctx := context.Background()
+	s := store.NewMemoryStore()
+	if err := s.Open(ctx); err != nil {
+		t.Fatal(err)
 	}
-	return nil
-}
-
-func (m *mockStore) Init(dbFile string) error {
-	return nil
-}
-
-func (m *mockStore) Read(note model.Note) ([]model.Note, error) {
-	return nil, nil
-}
-
-func (m *mockStore) Search(keyword string) ([]model.Note, error) {
-	return nil, nil
-}
-
-func (m *mockStore) Delete(note model.Note) error {
-	return nil
+	_, err := s.Import(ctx, []model.Note{
+		{Id: 1, Value: "note1", Tags: []string{"tag1"}},
+		{Id: 2, Value: "note one", Tags: []string{"tag1"}},
+		{Id: 3, Value: "note3", DeletedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{Id: 4, Value: "note4", DeletedAt: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+	}, store.ImportReplace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Update(ctx, model.Note{Id: 2, Value: "note2", Tags: []string{"tag1", "tag2"}}); err != nil {
+		t.Fatal(err)
+	}
+	return s
 }
 
-func newMockStore() store.Store {
-	return &mockStore{}
+// bare drops the fields the store fills in itself, so that notes can be
+// compared with literals.
+func bare(notes ...model.Note) []model.Note {
+	for i := range notes {
+		notes[i].UUID = ""
+		notes[i].CreatedAt = time.Time{}
+		notes[i].UpdatedAt = time.Time{}
+		notes[i].Snippet = ""
+	}
+	return notes
 }
 
-type mockStore struct{}
-
-var mockStoreInstance = newMockStore()
-
 func Test_noteService_Add(t *testing.T) {
 	tests := []struct {
 		name    string
 		note    model.Note
-		store   store.Store
+		want    model.Note
 		wantErr bool
 	}{
 		{
 			name: "Empty value and tag",
 			note: model.Note{
 				Value: "",
-				Tag:   "",
+				Tags:  nil,
 			},
-			store:   mockStoreInstance,
 			wantErr: true,
 		},
 		{
 			name: "Empty value and non empty tag",
 			note: model.Note{
 				Value: "",
-				Tag:   "sample tag",
+				Tags:  []string{"sample tag"},
 			},
-			store:   mockStoreInstance,
 			wantErr: true,
 		},
 		{
 			name: "non empty value and non empty tag",
 			note: model.Note{
 				Value: "sample value",
-				Tag:   "sample tag",
+				Tags:  []string{" sample tag ", ""},
+			},
+			want: model.Note{
+				Id:    5,
+				Value: "sample value",
+				Tags:  []string{"sample tag"},
 			},
-			store:   mockStoreInstance,
 			wantErr: false,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			n := &noteService{
-				store: tt.store,
+				store: newTestStore(t),
 			}
-			if err := n.Add(tt.note); (err != nil) != tt.wantErr {
+			got, err := n.Add(context.Background(), tt.note)
+			if (err != nil) != tt.wantErr {
 				t.Errorf("noteService.Add() error = %v, wantErr %v", err, tt.wantErr)
 			}
+			if !reflect.DeepEqual(bare(got)[0], tt.want) {
+				t.Errorf("noteService.Add() = %v, want %v", got, tt.want)
+			}
 		})
 	}
 }
@@ -90,24 +107,114 @@ func Test_noteService_View(t *testing.T) {
 	tests := []struct {
 		name    string
 		note    model.Note
-		store   store.Store
+		want    []model.Note
 		wantErr bool
 	}{
 		{
-			name:    "View All Notes",
-			note:    model.Note{},
-			store:   mockStoreInstance,
+			name: "View All Notes",
+			note: model.Note{},
+			want: []model.Note{
+				{Id: 1, Value: "note1", Tags: []string{"tag1"}},
+				{Id: 2, Value: "note2", Tags: []string{"tag1", "tag2"}},
+			},
 			wantErr: false,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			n := &noteService{
-				store: tt.store,
+				store: newTestStore(t),
 			}
-			if err := n.View(tt.note); (err != nil) != tt.wantErr {
+			got, err := n.View(context.Background(), tt.note, model.DateRange{})
+			if (err != nil) != tt.wantErr {
 				t.Errorf("noteService.View() error = %v, wantErr %v", err, tt.wantErr)
 			}
+			if got = bare(got...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("noteService.View() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_noteService_Get(t *testing.T) {
+	tests := []struct {
+		name         string
+		id           int64
+		want         model.Note
+		wantNotFound bool
+		wantErr      bool
+	}{
+		{
+			name: "existing note",
+			id:   2,
+			want: model.Note{Id: 2, Value: "note2", Tags: []string{"tag1", "tag2"}},
+		},
+		{
+			name:         "unknown note",
+			id:           9,
+			wantNotFound: true,
+			wantErr:      true,
+		},
+		{
+			name:    "invalid id",
+			id:      0,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &noteService{
+				store: newTestStore(t),
+			}
+			got, err := n.Get(context.Background(), tt.id)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("noteService.Get() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if errors.Is(err, store.ErrNotFound) != tt.wantNotFound {
+				t.Errorf("noteService.Get() error = %v, want ErrNotFound %v", err, tt.wantNotFound)
+			}
+			if !reflect.DeepEqual(bare(got)[0], tt.want) {
+				t.Errorf("noteService.Get() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_noteService_Update(t *testing.T) {
+	tests := []struct {
+		name    string
+		note    model.Note
+		want    model.Note
+		wantErr bool
+	}{
+		{
+			name: "trims tags",
+			note: model.Note{Id: 1, Value: "changed", Tags: []string{" a ", " "}},
+			want: model.Note{Id: 1, Value: "changed", Tags: []string{"a"}},
+		},
+		{
+			name:    "empty value",
+			note:    model.Note{Id: 1},
+			wantErr: true,
+		},
+		{
+			name:    "unknown note",
+			note:    model.Note{Id: 9, Value: "changed"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &noteService{
+				store: newTestStore(t),
+			}
+			got, err := n.Update(context.Background(), tt.note)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("noteService.Update() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(bare(got)[0], tt.want) {
+				t.Errorf("noteService.Update() = %v, want %v", got, tt.want)
+			}
 		})
 	}
 }
@@ -116,24 +223,34 @@ func Test_noteService_Delete(t *testing.T) {
 	tests := []struct {
 		name    string
 		note    model.Note
-		store   store.Store
+		want    int64
 		wantErr bool
 	}{
 		{
 			name:    "Delete All Notes",
+			note:    model.Note{Id: -1},
+			want:    2,
+			wantErr: false,
+		},
+		{
+			name:    "Nothing selected",
 			note:    model.Note{},
-			store:   mockStoreInstance,
+			want:    0,
 			wantErr: false,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			n := &noteService{
-				store: tt.store,
+				store: newTestStore(t),
 			}
-			if err := n.Delete(tt.note); (err != nil) != tt.wantErr {
+			got, err := n.Delete(context.Background(), tt.note)
+			if (err != nil) != tt.wantErr {
 				t.Errorf("noteService.Delete() error = %v, wantErr %v", err, tt.wantErr)
 			}
+			if got != tt.want {
+				t.Errorf("noteService.Delete() = %v, want %v", got, tt.want)
+			}
 		})
 	}
 }
@@ -142,24 +259,479 @@ func Test_noteService_Search(t *testing.T) {
 	tests := []struct {
 		name    string
 		keyword string
-		store   store.Store
+		want    []model.Note
 		wantErr bool
 	}{
 		{
 			name:    "non empy keyword",
-			keyword: "test",
-			store:   mockStoreInstance,
+			keyword: "NOTE",
+			want: []model.Note{
+				{Id: 1, Value: "note1", Tags: []string{"tag1"}},
+				{Id: 2, Value: "note2", Tags: []string{"tag1", "tag2"}},
+			},
 			wantErr: false,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			n := &noteService{
-				store: tt.store,
+				store: newTestStore(t),
 			}
-			if err := n.Search(tt.keyword); (err != nil) != tt.wantErr {
+			got, err := n.Search(context.Background(), tt.keyword, model.DateRange{})
+			if (err != nil) != tt.wantErr {
 				t.Errorf("noteService.Search() error = %v, wantErr %v", err, tt.wantErr)
 			}
+			if got = bare(got...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("noteService.Search() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_noteService_Restore(t *testing.T) {
+	tests := []struct {
+		name         string
+		id           int64
+		want         model.Note
+		wantErr      bool
+		wantNotFound bool
+	}{
+		{name: "trashed note", id: 3, want: model.Note{Id: 3, Value: "note3"}},
+		{name: "live note", id: 1, wantErr: true, wantNotFound: true},
+		{name: "invalid id", id: 0, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &noteService{
+				store: newTestStore(t),
+			}
+			got, err := n.Restore(context.Background(), tt.id)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("noteService.Restore() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if errors.Is(err, store.ErrNotFound) != tt.wantNotFound {
+				t.Errorf("noteService.Restore() error = %v, want ErrNotFound %v", err, tt.wantNotFound)
+			}
+			if !reflect.DeepEqual(bare(got)[0], tt.want) {
+				t.Errorf("noteService.Restore() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_noteService_PurgeTrash(t *testing.T) {
+	tests := []struct {
+		name   string
+		cutoff time.Time
+		want   int64
+	}{
+		{name: "retention disabled", cutoff: time.Time{}, want: 0},
+		{name: "older notes", cutoff: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), want: 1},
+		{name: "every note", cutoff: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &noteService{
+				store: newTestStore(t),
+			}
+			got, err := n.PurgeTrash(context.Background(), tt.cutoff)
+			if err != nil {
+				t.Fatalf("noteService.PurgeTrash() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("noteService.PurgeTrash() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_noteService_EmptyTrash(t *testing.T) {
+	n := &noteService{
+		store: newTestStore(t),
+	}
+	got, err := n.EmptyTrash(context.Background())
+	if err != nil || got != 2 {
+		t.Errorf("noteService.EmptyTrash() = %d, %v, want 2", got, err)
+	}
+}
+
+func Test_noteService_History(t *testing.T) {
+	n := &noteService{
+		store: newTestStore(t),
+	}
+	got, err := n.History(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("noteService.History() error = %v", err)
+	}
+	for i := range got {
+		got[i].CreatedAt = time.Time{}
+	}
+	want := []model.Revision{
+		{NoteId: 2, Number: 1, Value: "note one", Tags: []string{"tag1"}},
+		{NoteId: 2, Number: 2, Value: "note2", Tags: []string{"tag1", "tag2"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("noteService.History() = %v, want %v", got, want)
+	}
+	if _, err := n.History(context.Background(), 9); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("noteService.History() error = %v, want ErrNotFound", err)
+	}
+}
+
+func Test_noteService_Diff(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to int
+		want     string
+		wantErr  bool
+	}{
+		{
+			name: "previous against current",
+			want: `--- note 2 revision 1
++++ note 2 revision 2
+@@ -1,5 +1,5 @@
+ ---
+ id: 2
+-tags: tag1
++tags: tag1, tag2
+ ---
+-note one
++note2
+`,
+		},
+		{name: "same revision", from: 2, to: 2, want: ""},
+		{name: "unknown revision", from: 3, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &noteService{
+				store: newTestStore(t),
+			}
+			got, err := n.Diff(context.Background(), 2, tt.from, tt.to)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("noteService.Diff() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("noteService.Diff() =\n%s\nwant\n%s", got, tt.want)
+			}
 		})
 	}
 }
+
+func Test_noteService_Revert(t *testing.T) {
+	tests := []struct {
+		name    string
+		number  int
+		want    model.Note
+		wantErr bool
+	}{
+		{name: "earlier revision", number: 1, want: model.Note{Id: 2, Value: "note one", Tags: []string{"tag1"}}},
+		{name: "current revision", number: 2, wantErr: true},
+		{name: "unknown revision", number: 5, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &noteService{
+				store: newTestStore(t),
+			}
+			got, err := n.Revert(context.Background(), 2, tt.number)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("noteService.Revert() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(bare(got)[0], tt.want) {
+				t.Errorf("noteService.Revert() = %v, want %v", got, tt.want)
+			}
+			if stored, _ := n.Get(context.Background(), 2); !tt.wantErr && !reflect.DeepEqual(bare(stored)[0], tt.want) {
+				t.Errorf("stored note = %v, want %v", stored, tt.want)
+			}
+		})
+	}
+}
+
+func Test_noteService_Tags(t *testing.T) {
+	n := &noteService{
+		store: newTestStore(t),
+	}
+	got, err := n.Tags(context.Background())
+	if err != nil {
+		t.Fatalf("noteService.Tags() error = %v", err)
+	}
+	for i := range got {
+		got[i].LastUsed = time.Time{}
+	}
+	want := []model.Tag{{Name: "tag1", Notes: 2}, {Name: "tag2", Notes: 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("noteService.Tags() = %v, want %v", got, want)
+	}
+}
+
+func Test_noteService_ViewExact(t *testing.T) {
+	n := &noteService{
+		store: newTestStore(t),
+	}
+	got, err := n.ViewExact(context.Background(), model.Note{Tags: []string{" tag2/"}}, model.DateRange{})
+	if err != nil {
+		t.Fatalf("noteService.ViewExact() error = %v", err)
+	}
+	if want := []model.Note{{Id: 2, Value: "note2", Tags: []string{"tag1", "tag2"}}}; !reflect.DeepEqual(bare(got...), want) {
+		t.Errorf("noteService.ViewExact() = %v, want %v", got, want)
+	}
+}
+
+func Test_noteService_Find(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	s.Write(ctx, "restart postgres", []string{"work"})
+	s.Write(ctx, "drain node", []string{"work"})
+	n := &noteService{
+		store: s,
+	}
+	got, err := n.Find(ctx, "tag:work -postgres", model.DateRange{})
+	if err != nil {
+		t.Fatalf("noteService.Find() error = %v", err)
+	}
+	if want := []model.Note{{Id: 6, Value: "drain node", Tags: []string{"work"}}}; !reflect.DeepEqual(bare(got...), want) {
+		t.Errorf("noteService.Find() = %v, want %v", got, want)
+	}
+
+	_, err = n.Find(ctx, "tag:work AND", model.DateRange{})
+	var perr *filter.Error
+	if !errors.As(err, &perr) || perr.Column != 13 {
+		t.Errorf("noteService.Find() error = %v, want a filter.Error at column 13", err)
+	}
+	if _, err := n.Find(ctx, "created:>never", model.DateRange{}); !errors.As(err, &perr) || perr.Column != 1 {
+		t.Errorf("noteService.Find() error = %v, want a filter.Error at column 1", err)
+	}
+}
+
+func Test_noteService_SaveSearch(t *testing.T) {
+	ctx := context.Background()
+	n := &noteService{
+		store: newTestStore(t),
+	}
+	if err := n.SaveSearch(ctx, "on call", "tag:oncall"); err == nil {
+		t.Errorf("noteService.SaveSearch() with a space in the name error = nil")
+	}
+	var perr *filter.Error
+	if err := n.SaveSearch(ctx, "oncall", "tag:oncall OR"); !errors.As(err, &perr) {
+		t.Errorf("noteService.SaveSearch() of a malformed query error = %v, want a filter.Error", err)
+	}
+	if err := n.SaveSearch(ctx, "by-tag_2", " tag:$tag created:>$since "); err != nil {
+		t.Fatalf("noteService.SaveSearch() error = %v", err)
+	}
+	saved, err := n.SavedSearches(ctx)
+	want := []model.SavedSearch{{Name: "by-tag_2", Query: "tag:$tag created:>$since"}}
+	if err != nil || !reflect.DeepEqual(saved, want) {
+		t.Errorf("noteService.SaveSearch() saved %v, %v, want %v", saved, err, want)
+	}
+	if got, err := n.SavedSearch(ctx, "by-tag_2"); err != nil || got != want[0] {
+		t.Errorf("noteService.SavedSearch() = %v, %v", got, err)
+	}
+	if _, err := n.SavedSearch(ctx, "oncall"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("noteService.SavedSearch() of an unknown name error = %v, want ErrNotFound", err)
+	}
+}
+
+func Test_noteService_FindParams(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemoryStore()
+	if err := s.Open(ctx); err != nil {
+		t.Fatal(err)
+	}
+	s.Write(ctx, "restart postgres", []string{"work/db"})
+	s.Write(ctx, "drain node", []string{"work/k8s"})
+
+	n := NewNoteService(s)
+	got, err := n.FindParams(ctx, "tag:$tag", map[string]string{"tag": "work/k8s"}, model.DateRange{})
+	if err != nil || len(got) != 1 || got[0].Id != 2 {
+		t.Errorf("noteService.FindParams() = %v, %v, want note 2", got, err)
+	}
+	_, err = n.FindParams(ctx, "tag:$tag", map[string]string{"tag": "db", "since": "2w"}, model.DateRange{})
+	if want := `unknown parameter "since", the query takes tag`; err == nil || err.Error() != want {
+		t.Errorf("noteService.FindParams() error = %v, want %q", err, want)
+	}
+	var perr *filter.Error
+	if _, err := n.FindParams(ctx, "tag:$tag", nil, model.DateRange{}); !errors.As(err, &perr) {
+		t.Errorf("noteService.FindParams() without the parameter error = %v, want a filter.Error", err)
+	}
+}
+
+func Test_noteService_SearchWithin(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemoryStore()
+	if err := s.Open(ctx); err != nil {
+		t.Fatal(err)
+	}
+	s.Write(ctx, "restart postgres", []string{"work/db"})
+	s.Write(ctx, "postgres at home", []string{"home"})
+	s.Write(ctx, "drain node", []string{"work/k8s"})
+
+	n := NewNoteService(s)
+	got, err := n.SearchWithin(ctx, "postgres", "tag:work", model.DateRange{})
+	if err != nil {
+		t.Fatalf("noteService.SearchWithin() error = %v", err)
+	}
+	if len(got) != 1 || got[0].Id != 1 {
+		t.Errorf("noteService.SearchWithin() = %v, want note 1", got)
+	}
+	got, err = n.SearchWithin(ctx, "postgres", "", model.DateRange{})
+	if err != nil || len(got) != 2 {
+		t.Errorf("noteService.SearchWithin() with no query = %v, %v, want notes 1 and 2", got, err)
+	}
+}
+
+func Test_noteService_TagTree(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemoryStore()
+	if err := s.Open(ctx); err != nil {
+		t.Fatal(err)
+	}
+	s.Write(ctx, "failover", []string{"work/oncall/db", "work/oncall"})
+	s.Write(ctx, "standup", []string{"work"})
+	s.Write(ctx, "dune", []string{"personal/reading"})
+	s.Write(ctx, "old", []string{"work/archive"})
+	s.Delete(ctx, model.Note{Id: 4})
+
+	got, err := NewNoteService(s).TagTree(ctx)
+	if err != nil {
+		t.Fatalf("noteService.TagTree() error = %v", err)
+	}
+	var flatten func(nodes []model.TagNode, depth int) []string
+	flatten = func(nodes []model.TagNode, depth int) []string {
+		var lines []string
+		for _, node := range nodes {
+			lines = append(lines, fmt.Sprintf("%s%s %d", strings.Repeat("  ", depth), node.Name, node.Notes))
+			lines = append(lines, flatten(node.Children, depth+1)...)
+		}
+		return lines
+	}
+	want := []string{
+		"personal 1",
+		"  personal/reading 1",
+		"work 2",
+		"  work/archive 0",
+		"  work/oncall 1",
+		"    work/oncall/db 1",
+	}
+	if lines := flatten(got, 0); !reflect.DeepEqual(lines, want) {
+		t.Errorf("noteService.TagTree() = %q, want %q", lines, want)
+	}
+	if got[1].LastUsed.IsZero() || !got[1].Children[0].LastUsed.IsZero() {
+		t.Errorf("noteService.TagTree() last used = %v and %v", got[1].LastUsed, got[1].Children[0].LastUsed)
+	}
+}
+
+func Test_noteService_RenameTag(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		want     int64
+		wantTags []string
+		wantErr  bool
+	}{
+		{name: "rename", from: "tag1", to: " k8s ", want: 2, wantTags: []string{"tag2", "k8s"}},
+		{name: "same name", from: "tag1", to: "tag1", wantErr: true},
+		{name: "existing tag", from: "tag1", to: "tag2", wantErr: true},
+		{name: "empty name", from: "tag1", to: " ", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &noteService{
+				store: newTestStore(t),
+			}
+			got, err := n.RenameTag(context.Background(), tt.from, tt.to)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("noteService.RenameTag() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("noteService.RenameTag() = %d, want %d", got, tt.want)
+			}
+			if stored, _ := n.Get(context.Background(), 2); tt.wantTags != nil && !reflect.DeepEqual(stored.Tags, tt.wantTags) {
+				t.Errorf("tags after RenameTag() = %v, want %v", stored.Tags, tt.wantTags)
+			}
+		})
+	}
+}
+
+func Test_noteService_RenameTagBelow(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	s.Write(ctx, "standup", []string{"work"})
+	s.Write(ctx, "failover", []string{"work/oncall"})
+	s.Write(ctx, "saw", []string{"workshop"})
+	n := &noteService{
+		store: s,
+	}
+
+	got, err := n.RenameTag(ctx, "work", "job")
+	if err != nil || got != 2 {
+		t.Fatalf("noteService.RenameTag() = %d, %v, want 2", got, err)
+	}
+	notes, err := n.View(ctx, model.Note{Tags: []string{"job"}}, model.DateRange{})
+	if want := []model.Note{{Id: 5, Value: "standup", Tags: []string{"job"}}, {Id: 6, Value: "failover", Tags: []string{"job/oncall"}}}; err != nil || !reflect.DeepEqual(bare(notes...), want) {
+		t.Errorf("notes under job = %v, %v, want %v", notes, err, want)
+	}
+	if notes, err := n.View(ctx, model.Note{Tags: []string{"workshop"}}, model.DateRange{}); err != nil || len(notes) != 1 {
+		t.Errorf("notes under workshop = %v, %v, want note 7", notes, err)
+	}
+
+	s.Write(ctx, "review", []string{"job/oncall/db", "team/oncall"})
+	if _, err := n.RenameTag(ctx, "job", "team"); err == nil || err.Error() != `tag "team/oncall" already exists, merge "job/oncall" into it instead` {
+		t.Errorf("noteService.RenameTag() onto a tag below error = %v", err)
+	}
+	if _, err := n.RenameTag(ctx, "missing", "gone"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("noteService.RenameTag() of a missing tag error = %v, want ErrNotFound", err)
+	}
+}
+
+func Test_noteService_Rekey(t *testing.T) {
+	ctx := context.Background()
+	old, replacement := newTestCrypter(t, "old"), newTestCrypter(t, "new")
+	sealed, err := old.Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resealed, err := old.Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestStore(t)
+	id, err := s.Write(ctx, sealed, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Update(ctx, model.Note{Id: id, Value: resealed}); err != nil {
+		t.Fatal(err)
+	}
+	n := &noteService{
+		store: s,
+	}
+
+	if _, err := n.Rekey(ctx, newTestCrypter(t, "wrong"), replacement); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("noteService.Rekey() with the wrong passphrase error = %v, want ErrWrongPassphrase", err)
+	}
+	if note, _ := n.Get(ctx, id); note.Value != resealed {
+		t.Errorf("noteService.Rekey() changed notes after failing")
+	}
+
+	rekeyed, err := n.Rekey(ctx, old, replacement)
+	if err != nil || rekeyed != 2 {
+		t.Fatalf("noteService.Rekey() = %d, %v, want 2", rekeyed, err)
+	}
+	if note, _ := n.Get(ctx, 1); note.Value != "note1" {
+		t.Errorf("noteService.Rekey() changed a plain note to %q", note.Value)
+	}
+	note, _ := n.Get(ctx, id)
+	revisions, _ := s.Revisions(ctx, id)
+	if len(revisions) != 1 {
+		t.Fatalf("Revisions() after Rekey = %v, want one", revisions)
+	}
+	for _, text := range []string{note.Value, revisions[0].Value} {
+		if got, err := replacement.Decrypt(text); err != nil || got != "secret" {
+			t.Errorf("Decrypt() after Rekey = %q, %v", got, err)
+		}
+		if _, err := old.Decrypt(text); !errors.Is(err, ErrWrongPassphrase) {
+			t.Errorf("the old passphrase still decrypts after Rekey")
+		}
+	}
+}
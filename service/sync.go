@@ -0,0 +1,315 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/iamunni/hugnin/model"
+	"github.com/iamunni/hugnin/store"
+)
+
+// Sync matches the notes of two stores by UUID. A note found in one store
+// only is copied to the other, unless the other has a tombstone for it
+// that is newer than its last update, in which case it is removed. A note
+// found in both with different texts or tags is settled with the revision
+// history: when one version is an earlier revision of the other, the newer
+// one wins; otherwise both were edited since they were last synced, and
+// both are kept, tagged ConflictTag. Whether a note ends up in the trash
+// is decided by whichever is later, its trashing or its last update.
+
+// ConflictTag marks both versions of a note that was edited in both stores
+// since they last synced.
+const ConflictTag = "conflict"
+
+// SyncCounts counts the changes a sync made to one store.
+type SyncCounts struct {
+	Added    int
+	Updated  int
+	Trashed  int
+	Restored int
+	Deleted  int
+}
+
+func (c SyncCounts) String() string {
+	return fmt.Sprintf("%d added, %d updated, %d trashed, %d restored, %d deleted", c.Added, c.Updated, c.Trashed, c.Restored, c.Deleted)
+}
+
+// SyncReport tells what a sync changed. Pulled counts the changes made to
+// the local store and Pushed the ones made to the other store.
+type SyncReport struct {
+	Pulled    SyncCounts
+	Pushed    SyncCounts
+	Conflicts int
+}
+
+// syncSide is one store of a sync with the changes planned for it.
+type syncSide struct {
+	store      store.Store
+	notes      map[string]model.Note
+	tombstones map[string]model.Tombstone
+	puts       []model.Note
+	forgets    []model.Tombstone
+	counts     *SyncCounts
+}
+
+func (n *noteService) Sync(ctx context.Context, remote store.Store) (SyncReport, error) {
+	var report SyncReport
+	local, err := loadSyncSide(ctx, n.store, &report.Pulled)
+	if err != nil {
+		return SyncReport{}, err
+	}
+	other, err := loadSyncSide(ctx, remote, &report.Pushed)
+	if err != nil {
+		return SyncReport{}, err
+	}
+
+	uuids := map[string]bool{}
+	for uuid := range local.notes {
+		uuids[uuid] = true
+	}
+	for uuid := range other.notes {
+		uuids[uuid] = true
+	}
+	for _, uuid := range sortedKeys(uuids) {
+		l, inLocal := local.notes[uuid]
+		r, inOther := other.notes[uuid]
+		switch {
+		case inLocal && inOther:
+			conflict, err := reconcile(ctx, local, other, l, r)
+			if err != nil {
+				return SyncReport{}, err
+			}
+			if conflict {
+				report.Conflicts++
+			}
+		case inLocal:
+			copyOrForget(local, other, l)
+		default:
+			copyOrForget(other, local, r)
+		}
+	}
+	// Tombstones of notes neither store has are passed on, so a third
+	// store synced later still learns about them.
+	for _, sides := range [][2]*syncSide{{local, other}, {other, local}} {
+		from, to := sides[0], sides[1]
+		for _, uuid := range sortedKeys(from.tombstones) {
+			if _, ok := to.tombstones[uuid]; !ok && !uuids[uuid] {
+				to.forgets = append(to.forgets, from.tombstones[uuid])
+			}
+		}
+	}
+
+	// The other store goes first: when writing the local one fails, the
+	// next sync finds the other store ahead and completes the job.
+	for _, side := range []*syncSide{other, local} {
+		err = side.apply(ctx)
+		if err != nil {
+			return SyncReport{}, err
+		}
+	}
+	return report, nil
+}
+
+func loadSyncSide(ctx context.Context, s store.Store, counts *SyncCounts) (*syncSide, error) {
+	notes, err := NewNoteService(s).Export(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tombstones, err := s.Tombstones(ctx)
+	if err != nil {
+		return nil, err
+	}
+	side := &syncSide{
+		store:      s,
+		notes:      make(map[string]model.Note, len(notes)),
+		tombstones: make(map[string]model.Tombstone, len(tombstones)),
+		counts:     counts,
+	}
+	for _, note := range notes {
+		side.notes[note.UUID] = note
+	}
+	for _, tombstone := range tombstones {
+		side.tombstones[tombstone.UUID] = tombstone
+	}
+	return side, nil
+}
+
+// copyOrForget settles a note that only from has: to gets a copy, unless
+// to removed the note after its last update, in which case from loses it.
+func copyOrForget(from, to *syncSide, note model.Note) {
+	tombstone, ok := to.tombstones[note.UUID]
+	if ok && !note.UpdatedAt.After(tombstone.DeletedAt) {
+		from.forget(tombstone)
+		return
+	}
+	to.put(note)
+}
+
+// reconcile settles a note both stores have and reports whether it was a
+// conflict.
+func reconcile(ctx context.Context, local, other *syncSide, l, r model.Note) (bool, error) {
+	var winner model.Note
+	switch {
+	case store.ContentHash(l) == store.ContentHash(r):
+		winner = l
+		if r.UpdatedAt.After(l.UpdatedAt) {
+			winner = r
+		}
+	default:
+		ahead, err := local.isAhead(ctx, l, r)
+		if err != nil {
+			return false, err
+		}
+		if ahead {
+			winner = l
+			break
+		}
+		ahead, err = other.isAhead(ctx, r, l)
+		if err != nil {
+			return false, err
+		}
+		if ahead {
+			winner = r
+			break
+		}
+		keepBoth(local, other, l, r)
+		return true, nil
+	}
+
+	winner.DeletedAt = trashState(l, r)
+	local.put(winner)
+	other.put(winner)
+	return false, nil
+}
+
+// isAhead reports whether note, as side has it, was edited from other:
+// other is one of its earlier revisions.
+func (side *syncSide) isAhead(ctx context.Context, note, other model.Note) (bool, error) {
+	revisions, err := side.store.Revisions(ctx, note.Id)
+	if err != nil {
+		return false, err
+	}
+	hash := store.ContentHash(other)
+	for _, revision := range revisions {
+		if store.ContentHash(revisionNote(revision)) == hash {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// trashState returns when the synced note was trashed, or zero when it
+// is live: a note is in the trash when it was trashed in either store
+// after its last update in the stores where it is live.
+func trashState(a, b model.Note) time.Time {
+	var trashedAt, updatedAt time.Time
+	for _, note := range []model.Note{a, b} {
+		if note.DeletedAt.IsZero() {
+			if note.UpdatedAt.After(updatedAt) {
+				updatedAt = note.UpdatedAt
+			}
+		} else if note.DeletedAt.After(trashedAt) {
+			trashedAt = note.DeletedAt
+		}
+	}
+	if trashedAt.IsZero() || updatedAt.After(trashedAt) {
+		return time.Time{}
+	}
+	return trashedAt
+}
+
+// keepBoth settles conflicting edits: the version updated last keeps the
+// UUID, the other one becomes a new note, and both are tagged ConflictTag
+// and restored from the trash in both stores.
+func keepBoth(local, other *syncSide, l, r model.Note) {
+	winner, copied := conflictVersions(l, r)
+	for _, side := range []*syncSide{local, other} {
+		side.put(winner)
+		side.put(copied)
+	}
+}
+
+// conflictVersions returns the two versions kept for conflicting edits a
+// and b: the one updated last under its UUID and the other one as a new
+// note, both live and tagged ConflictTag.
+func conflictVersions(a, b model.Note) (model.Note, model.Note) {
+	winner, loser := a, b
+	if b.UpdatedAt.After(a.UpdatedAt) {
+		winner, loser = b, a
+	}
+	winner.Tags = withTag(winner.Tags, ConflictTag)
+	winner.DeletedAt = time.Time{}
+	copied := model.Note{
+		UUID:      store.NewUUID(),
+		Value:     loser.Value,
+		Tags:      withTag(loser.Tags, ConflictTag),
+		CreatedAt: loser.CreatedAt,
+		UpdatedAt: loser.UpdatedAt,
+	}
+	return winner, copied
+}
+
+// put plans storing note, counting what it changes.
+func (side *syncSide) put(note model.Note) {
+	old, ok := side.notes[note.UUID]
+	switch {
+	case !ok:
+		side.counts.Added++
+	case store.ContentHash(old) == store.ContentHash(note) && old.DeletedAt.Equal(note.DeletedAt):
+		return
+	default:
+		if store.ContentHash(old) != store.ContentHash(note) {
+			side.counts.Updated++
+		}
+		if old.DeletedAt.IsZero() && !note.DeletedAt.IsZero() {
+			side.counts.Trashed++
+		}
+		if !old.DeletedAt.IsZero() && note.DeletedAt.IsZero() {
+			side.counts.Restored++
+		}
+	}
+	side.notes[note.UUID] = note
+	side.puts = append(side.puts, note)
+}
+
+// forget plans removing the note with the UUID of tombstone.
+func (side *syncSide) forget(tombstone model.Tombstone) {
+	side.counts.Deleted++
+	side.forgets = append(side.forgets, tombstone)
+}
+
+func (side *syncSide) apply(ctx context.Context) error {
+	if len(side.puts) > 0 {
+		err := side.store.Put(ctx, side.puts)
+		if err != nil {
+			return err
+		}
+	}
+	if len(side.forgets) > 0 {
+		_, err := side.store.Forget(ctx, side.forgets)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withTag returns tags with tag added at the end, unless it is there
+// already.
+func withTag(tags []string, tag string) []string {
+	if containsTag(tags, tag) {
+		return tags
+	}
+	return append(append([]string(nil), tags...), tag)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
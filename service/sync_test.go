@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iamunni/hugnin/model"
+	"github.com/iamunni/hugnin/store"
+)
+
+func newSyncStore(t *testing.T) store.Store {
+	s := store.NewMemoryStore()
+	if err := s.Open(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// tick lets the millisecond clock of the stores move on, so the next
+// change is strictly newer than the last one.
+func tick() {
+	time.Sleep(2 * time.Millisecond)
+}
+
+// syncedValues returns the texts and tags of the live notes of s, sorted.
+func syncedValues(t *testing.T, s store.Store) []string {
+	t.Helper()
+	notes, err := s.Read(context.Background(), model.Note{}, model.DateRange{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := []string{}
+	for _, note := range notes {
+		values = append(values, note.Value+" "+strings.Join(note.Tags, ","))
+	}
+	sort.Strings(values)
+	return values
+}
+
+func Test_noteService_Sync(t *testing.T) {
+	ctx := context.Background()
+	laptop, workstation := newSyncStore(t), newSyncStore(t)
+	n := NewNoteService(laptop)
+	sync := func(want SyncReport) {
+		t.Helper()
+		got, err := n.Sync(ctx, workstation)
+		if err != nil {
+			t.Fatalf("Sync() error = %v", err)
+		}
+		if got != want {
+			t.Errorf("Sync() = %+v, want %+v", got, want)
+		}
+		if l, w := syncedValues(t, laptop), syncedValues(t, workstation); !reflect.DeepEqual(l, w) {
+			t.Errorf("after Sync() laptop has %v, workstation has %v", l, w)
+		}
+	}
+
+	laptop.Write(ctx, "restart postgres", []string{"db"})
+	workstation.Write(ctx, "drain node", []string{"k8s"})
+	sync(SyncReport{Pulled: SyncCounts{Added: 1}, Pushed: SyncCounts{Added: 1}})
+	sync(SyncReport{})
+	if got := syncedValues(t, workstation); !reflect.DeepEqual(got, []string{"drain node k8s", "restart postgres db"}) {
+		t.Errorf("workstation after the first sync = %v", got)
+	}
+
+	tick()
+	laptop.Update(ctx, model.Note{Id: 1, Value: "restart postgres 16", Tags: []string{"db"}})
+	sync(SyncReport{Pushed: SyncCounts{Updated: 1}})
+	tick()
+	workstation.Update(ctx, model.Note{Id: 2, Value: "restart postgres 17", Tags: []string{"db"}})
+	sync(SyncReport{Pulled: SyncCounts{Updated: 1}})
+
+	tick()
+	laptop.Update(ctx, model.Note{Id: 1, Value: "restart postgres on the laptop", Tags: []string{"db"}})
+	tick()
+	workstation.Update(ctx, model.Note{Id: 2, Value: "restart postgres on the workstation", Tags: []string{"db"}})
+	sync(SyncReport{Pulled: SyncCounts{Added: 1, Updated: 1}, Pushed: SyncCounts{Added: 1, Updated: 1}, Conflicts: 1})
+	if got := syncedValues(t, laptop); !reflect.DeepEqual(got, []string{
+		"drain node k8s",
+		"restart postgres on the laptop db,conflict",
+		"restart postgres on the workstation db,conflict",
+	}) {
+		t.Errorf("laptop after a conflict = %v", got)
+	}
+	sync(SyncReport{})
+
+	tick()
+	laptop.Delete(ctx, model.Note{Tags: []string{"k8s"}})
+	sync(SyncReport{Pushed: SyncCounts{Trashed: 1}})
+	tick()
+	trashed, _ := workstation.Trashed(ctx)
+	workstation.Restore(ctx, trashed[0].Id)
+	sync(SyncReport{Pulled: SyncCounts{Restored: 1}})
+
+	tick()
+	laptop.Delete(ctx, model.Note{Tags: []string{"k8s"}})
+	laptop.Purge(ctx, time.Time{})
+	sync(SyncReport{Pushed: SyncCounts{Deleted: 1}})
+	if tombstones, _ := workstation.Tombstones(ctx); len(tombstones) != 1 {
+		t.Errorf("workstation tombstones = %+v, want the purged note", tombstones)
+	}
+
+	third := newSyncStore(t)
+	if _, err := NewNoteService(third).Sync(ctx, workstation); err != nil {
+		t.Fatal(err)
+	}
+	if tombstones, _ := third.Tombstones(ctx); len(tombstones) != 1 {
+		t.Errorf("tombstones passed on = %+v, want the purged note", tombstones)
+	}
+}
+
+func Test_noteService_SyncEditWinsOverOlderPurge(t *testing.T) {
+	ctx := context.Background()
+	laptop, workstation := newSyncStore(t), newSyncStore(t)
+	n := NewNoteService(laptop)
+	laptop.Write(ctx, "rotate keys", nil)
+	if _, err := n.Sync(ctx, workstation); err != nil {
+		t.Fatal(err)
+	}
+
+	laptop.Delete(ctx, model.Note{Id: 1})
+	laptop.Purge(ctx, time.Time{})
+	tick()
+	workstation.Update(ctx, model.Note{Id: 1, Value: "rotate keys monthly"})
+
+	got, err := n.Sync(ctx, workstation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (SyncReport{Pulled: SyncCounts{Added: 1}}); got != want {
+		t.Errorf("Sync() = %+v, want %+v", got, want)
+	}
+	if values := syncedValues(t, laptop); !reflect.DeepEqual(values, []string{"rotate keys monthly "}) {
+		t.Errorf("laptop after Sync() = %v, want the edited note back", values)
+	}
+	if tombstones, _ := laptop.Tombstones(ctx); len(tombstones) != 0 {
+		t.Errorf("laptop tombstones = %+v, want the tombstone dropped", tombstones)
+	}
+}
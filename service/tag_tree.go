@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"github.com/iamunni/hugnin/model"
+)
+
+func (n *noteService) TagTree(ctx context.Context) ([]model.TagNode, error) {
+	tags, err := n.store.Tags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	notes, err := n.store.Read(ctx, model.Note{}, model.DateRange{})
+	if err != nil {
+		return nil, err
+	}
+
+	// Every tag and every tag above one is a node, even when no note
+	// carries it itself: "work/oncall" alone makes a "work" node.
+	rolled := map[string]*model.Tag{}
+	for _, tag := range tags {
+		for _, path := range tagPaths(tag.Name) {
+			if rolled[path] == nil {
+				rolled[path] = &model.Tag{Name: path}
+			}
+		}
+	}
+	for _, note := range notes {
+		paths := map[string]bool{}
+		for _, tag := range note.Tags {
+			for _, path := range tagPaths(tag) {
+				paths[path] = true
+			}
+		}
+		for path := range paths {
+			tag := rolled[path]
+			if tag == nil {
+				tag = &model.Tag{Name: path}
+				rolled[path] = tag
+			}
+			tag.Notes++
+			if note.UpdatedAt.After(tag.LastUsed) {
+				tag.LastUsed = note.UpdatedAt
+			}
+		}
+	}
+	return tagChildren(rolled, sortedKeys(rolled), ""), nil
+}
+
+// tagPaths returns tag and every tag above it, "work", "work/oncall" and
+// "work/oncall/db" for "work/oncall/db".
+func tagPaths(tag string) []string {
+	var paths []string
+	for i := range tag {
+		if tag[i] == '/' {
+			paths = append(paths, tag[:i])
+		}
+	}
+	return append(paths, tag)
+}
+
+// tagChildren returns the nodes right below parent, or the top level ones
+// when parent is empty, with their own children. paths is sorted.
+func tagChildren(rolled map[string]*model.Tag, paths []string, parent string) []model.TagNode {
+	var nodes []model.TagNode
+	for _, path := range paths {
+		if path != parent && parentTag(path) == parent {
+			nodes = append(nodes, model.TagNode{Tag: *rolled[path], Children: tagChildren(rolled, paths, path)})
+		}
+	}
+	return nodes
+}
+
+// parentTag returns the tag right above tag, or "" for a top level tag.
+func parentTag(tag string) string {
+	i := strings.LastIndex(tag, "/")
+	if i < 0 {
+		return ""
+	}
+	return tag[:i]
+}
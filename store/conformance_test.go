@@ -0,0 +1,872 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iamunni/hugnin/filter"
+	"github.com/iamunni/hugnin/model"
+)
+
+// The conformance suite states the behaviour every Store must have. Each
+// implementation runs it through testStoreConformance with a constructor
+// for fresh, opened stores.
+
+func TestMemoryStore_Conformance(t *testing.T) {
+	testStoreConformance(t, func(t *testing.T) Store {
+		return openTestStore(t, NewMemoryStore())
+	})
+}
+
+func TestSQLiteStore_Conformance(t *testing.T) {
+	testStoreConformance(t, func(t *testing.T) Store {
+		return openTestStore(t, NewSQLiteStore(filepath.Join(t.TempDir(), "notes.db")))
+	})
+}
+
+func TestFileStore_Conformance(t *testing.T) {
+	testStoreConformance(t, func(t *testing.T) Store {
+		return openTestStore(t, NewFileStore(filepath.Join(t.TempDir(), "notes")))
+	})
+}
+
+func openTestStore(t *testing.T, s Store) Store {
+	if err := s.Open(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func testStoreConformance(t *testing.T, newStore func(t *testing.T) Store) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, s Store)
+	}{
+		{"ClosedStore", conformClosedStore},
+		{"WriteAndRead", conformWriteAndRead},
+		{"TagsWithCommas", conformTagsWithCommas},
+		{"ReadFilters", conformReadFilters},
+		{"ReadPeriod", conformReadPeriod},
+		{"Find", conformFind},
+		{"Update", conformUpdate},
+		{"Delete", conformDelete},
+		{"Search", conformSearch},
+		{"Tags", conformTags},
+		{"MergeTags", conformMergeTags},
+		{"RenameTags", conformRenameTags},
+		{"PruneTags", conformPruneTags},
+		{"SavedSearches", conformSavedSearches},
+		{"Trash", conformTrash},
+		{"IdsAreNotReused", conformIdsAreNotReused},
+		{"ImportMerge", conformImportMerge},
+		{"ImportReplace", conformImportReplace},
+		{"Rewrite", conformRewrite},
+		{"UUIDs", conformUUIDs},
+		{"Put", conformPut},
+		{"Forget", conformForget},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() { now = time.Now }()
+			tt.run(t, newStore(t))
+		})
+	}
+}
+
+// writeNotes writes notes with the given texts, all tagged with tags, and
+// fails the test on error.
+func writeNotes(t *testing.T, s Store, tags []string, values ...string) {
+	t.Helper()
+	for _, value := range values {
+		if _, err := s.Write(context.Background(), value, tags); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func noteIds(notes []model.Note) []int64 {
+	ids := []int64{}
+	for _, note := range notes {
+		ids = append(ids, note.Id)
+	}
+	return ids
+}
+
+func sortedIds(notes []model.Note) []int64 {
+	ids := noteIds(notes)
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func conformClosedStore(t *testing.T, s Store) {
+	ctx := context.Background()
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("second Close() error = %v", err)
+	}
+	if _, err := s.Write(ctx, "note", nil); err == nil {
+		t.Errorf("Write() on a closed store succeeded")
+	}
+	if _, err := s.Read(ctx, model.Note{}, model.DateRange{}); err == nil {
+		t.Errorf("Read() on a closed store succeeded")
+	}
+}
+
+func conformWriteAndRead(t *testing.T, s Store) {
+	ctx := context.Background()
+	now = func() time.Time { return testTime.Add(123456 * time.Microsecond) }
+	id, err := s.Write(ctx, "restart postgres", []string{"db", "oncall", "db"})
+	if err != nil || id != 1 {
+		t.Fatalf("Write() = %d, %v, want 1", id, err)
+	}
+	got, err := s.Read(ctx, model.Note{}, model.DateRange{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stamp := testTime.Add(123 * time.Millisecond)
+	want := []model.Note{{Id: 1, Value: "restart postgres", Tags: []string{"db", "oncall"}, CreatedAt: stamp, UpdatedAt: stamp}}
+	if len(got) != 1 || got[0].Id != want[0].Id || got[0].Value != want[0].Value || !reflect.DeepEqual(got[0].Tags, want[0].Tags) ||
+		!got[0].CreatedAt.Equal(stamp) || !got[0].UpdatedAt.Equal(stamp) || !got[0].DeletedAt.IsZero() {
+		t.Errorf("Read() = %+v, want %+v", got, want)
+	}
+	if got[0].CreatedAt.Location() != time.UTC {
+		t.Errorf("Read() CreatedAt location = %v, want UTC", got[0].CreatedAt.Location())
+	}
+
+	body := "# failover\n\n  1. promote the replica\r\n  2. repoint the app\n\n---\nlast line"
+	id, err = s.Write(ctx, body, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = s.Read(ctx, model.Note{Id: id}, model.DateRange{})
+	if err != nil || len(got) != 1 || got[0].Value != body {
+		t.Errorf("Read() of a multi-line note = %+v, %v, want %q", got, err, body)
+	}
+}
+
+// conformTagsWithCommas checks that tags may contain commas and come back
+// oldest tag first, whatever order a note gives them in.
+func conformTagsWithCommas(t *testing.T, s Store) {
+	ctx := context.Background()
+	writeNotes(t, s, []string{"zeta", "oncall, db"}, "first")
+	writeNotes(t, s, []string{"alpha", "oncall, db"}, "second")
+	if err := s.Update(ctx, model.Note{Id: 1, Value: "first", Tags: []string{"alpha"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.Read(ctx, model.Note{}, model.DateRange{})
+	if err != nil || len(got) != 2 {
+		t.Fatalf("Read() = %+v, %v", got, err)
+	}
+	if !reflect.DeepEqual(got[0].Tags, []string{"alpha"}) || !reflect.DeepEqual(got[1].Tags, []string{"oncall, db", "alpha"}) {
+		t.Errorf("Read() tags = %q and %q", got[0].Tags, got[1].Tags)
+	}
+	revisions, err := s.Revisions(ctx, 1)
+	if err != nil || len(revisions) != 1 || !reflect.DeepEqual(revisions[0].Tags, []string{"zeta", "oncall, db"}) {
+		t.Errorf("Revisions() = %+v, %v", revisions, err)
+	}
+	got, err = s.Read(ctx, model.Note{Tags: []string{"oncall, db"}}, model.DateRange{})
+	if err != nil || !reflect.DeepEqual(noteIds(got), []int64{2}) {
+		t.Errorf("Read() of the tag with a comma = %+v, %v", got, err)
+	}
+}
+
+func conformReadFilters(t *testing.T, s Store) {
+	ctx := context.Background()
+	writeNotes(t, s, []string{"db"}, "Restart Postgres", "vacuum postgres")
+	writeNotes(t, s, []string{"k8s", "oncall"}, "drain node", "100% done_ok")
+	writeNotes(t, s, nil, "untagged")
+	writeNotes(t, s, []string{"work/oncall/db"}, "failover")
+	writeNotes(t, s, []string{"work/oncall", "workshop"}, "pager rota")
+	writeNotes(t, s, []string{"work0", "Work/x"}, "not below work")
+
+	tests := []struct {
+		name string
+		note model.Note
+		want []int64
+	}{
+		{name: "everything", note: model.Note{}, want: []int64{1, 2, 3, 4, 5, 6, 7, 8}},
+		{name: "by id", note: model.Note{Id: 3}, want: []int64{3}},
+		{name: "unknown id", note: model.Note{Id: 42}, want: []int64{}},
+		{name: "exact text", note: model.Note{Value: "drain node"}, want: []int64{3}},
+		{name: "LIKE is case insensitive", note: model.Note{Value: "restart postgres"}, want: []int64{1}},
+		{name: "LIKE percent", note: model.Note{Value: "%postgres"}, want: []int64{1, 2}},
+		{name: "LIKE underscore", note: model.Note{Value: "drain nod_"}, want: []int64{3}},
+		{name: "LIKE keeps wildcards", note: model.Note{Value: "100_ done%"}, want: []int64{4}},
+		{name: "one tag", note: model.Note{Tags: []string{"oncall"}}, want: []int64{3, 4}},
+		{name: "any of the tags", note: model.Note{Tags: []string{"db", "k8s"}}, want: []int64{1, 2, 3, 4}},
+		{name: "tags match exactly", note: model.Note{Tags: []string{"DB"}}, want: []int64{}},
+		{name: "text and tag", note: model.Note{Value: "%node%", Tags: []string{"k8s"}}, want: []int64{3}},
+		{name: "tags below", note: model.Note{Tags: []string{"work"}}, want: []int64{6, 7}},
+		{name: "tags further below", note: model.Note{Tags: []string{"work/oncall"}}, want: []int64{6, 7}},
+		{name: "leaf tag", note: model.Note{Tags: []string{"work/oncall/db"}}, want: []int64{6}},
+	}
+	for _, tt := range tests {
+		got, err := s.Read(ctx, tt.note, model.DateRange{})
+		if err != nil {
+			t.Fatalf("%s: Read() error = %v", tt.name, err)
+		}
+		if ids := noteIds(got); !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("%s: Read(%+v) = %v, want %v", tt.name, tt.note, ids, tt.want)
+		}
+	}
+}
+
+func conformReadPeriod(t *testing.T, s Store) {
+	ctx := context.Background()
+	for day := 0; day < 3; day++ {
+		now = func() time.Time { return testTime.AddDate(0, 0, day) }
+		writeNotes(t, s, nil, "note")
+	}
+	period := model.DateRange{Since: testTime.AddDate(0, 0, 1), Until: testTime.AddDate(0, 0, 2)}
+	got, err := s.Read(ctx, model.Note{}, period)
+	if err != nil || !reflect.DeepEqual(noteIds(got), []int64{2}) {
+		t.Errorf("Read() within %v = %v, %v, want note 2", period, noteIds(got), err)
+	}
+	got, err = s.Search(ctx, "note", model.DateRange{Since: testTime.AddDate(0, 0, 1)})
+	if err != nil || !reflect.DeepEqual(sortedIds(got), []int64{2, 3}) {
+		t.Errorf("Search() since day 1 = %v, %v, want notes 2 and 3", noteIds(got), err)
+	}
+}
+
+func conformFind(t *testing.T, s Store) {
+	ctx := context.Background()
+	now = func() time.Time { return testTime }
+	writeNotes(t, s, []string{"work/db"}, "Restart Postgres")
+	writeNotes(t, s, []string{"work", "archived"}, "old postgres runbook")
+	now = func() time.Time { return testTime.AddDate(0, 0, 1) }
+	writeNotes(t, s, []string{"work/k8s"}, "drain node")
+	writeNotes(t, s, []string{"home"}, "50% off_sale", "trashed postgres")
+	if _, err := s.Delete(ctx, model.Note{Id: 5}); err != nil {
+		t.Fatal(err)
+	}
+	now = func() time.Time { return testTime.AddDate(0, 0, 2) }
+	if err := s.Update(ctx, model.Note{Id: 1, Value: "Restart Postgres 16", Tags: []string{"work/db"}}); err != nil {
+		t.Fatal(err)
+	}
+	utcDay := func(value string) (time.Time, time.Time, error) {
+		day, err := time.Parse("2006-01-02", value)
+		return day, day.AddDate(0, 0, 1), err
+	}
+
+	tests := []struct {
+		query string
+		want  []int64
+	}{
+		{query: "", want: []int64{1, 2, 3, 4}},
+		{query: "POSTGRES", want: []int64{1, 2}},
+		{query: `"50% off_"`, want: []int64{4}},
+		{query: `"50_"`, want: []int64{}},
+		{query: "tag:work", want: []int64{1, 2, 3}},
+		{query: "tag:work -tag:archived", want: []int64{1, 3}},
+		{query: `tag:work AND (tag:db OR "node")`, want: []int64{3}},
+		{query: `tag:work AND (tag:work/db OR "node")`, want: []int64{1, 3}},
+		{query: "NOT tag:work OR tag:archived", want: []int64{2, 4}},
+		{query: "tag:Work", want: []int64{}},
+		{query: "created:2024-01-02", want: []int64{1, 2}},
+		{query: "created:>2024-01-02", want: []int64{3, 4}},
+		{query: "created:<=2024-01-02 updated:>=2024-01-04", want: []int64{1}},
+		{query: "created:<2024-01-02", want: []int64{}},
+	}
+	for _, tt := range tests {
+		expr, err := filter.Parse(tt.query, utcDay)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", tt.query, err)
+		}
+		got, err := s.Find(ctx, expr, model.DateRange{})
+		if err != nil {
+			t.Fatalf("Find(%q) error = %v", tt.query, err)
+		}
+		if ids := noteIds(got); !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("Find(%q) = %v, want %v", tt.query, ids, tt.want)
+		}
+	}
+
+	got, err := s.Find(ctx, filter.Tag{Name: "work"}, model.DateRange{Since: testTime.AddDate(0, 0, 1)})
+	if err != nil || !reflect.DeepEqual(noteIds(got), []int64{3}) {
+		t.Errorf("Find() within a period = %v, %v, want note 3", noteIds(got), err)
+	}
+}
+
+func conformUpdate(t *testing.T, s Store) {
+	ctx := context.Background()
+	now = func() time.Time { return testTime }
+	writeNotes(t, s, []string{"db"}, "first")
+	now = func() time.Time { return testTime.Add(time.Hour) }
+	if err := s.Update(ctx, model.Note{Id: 1, Value: "second", Tags: []string{"ops"}}); err != nil {
+		t.Fatal(err)
+	}
+	now = func() time.Time { return testTime.Add(2 * time.Hour) }
+	if err := s.Update(ctx, model.Note{Id: 1, Value: "third", Tags: []string{"ops", "db"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.Read(ctx, model.Note{Id: 1}, model.DateRange{})
+	if err != nil || len(got) != 1 {
+		t.Fatalf("Read() = %v, %v", got, err)
+	}
+	if got[0].Value != "third" || !reflect.DeepEqual(got[0].Tags, []string{"db", "ops"}) ||
+		!got[0].CreatedAt.Equal(testTime) || !got[0].UpdatedAt.Equal(testTime.Add(2*time.Hour)) {
+		t.Errorf("Read() after Update = %+v", got[0])
+	}
+
+	revisions, err := s.Revisions(ctx, 1)
+	if err != nil || len(revisions) != 2 {
+		t.Fatalf("Revisions() = %v, %v, want 2 revisions", revisions, err)
+	}
+	want := []model.Revision{
+		{NoteId: 1, Number: 1, Value: "first", Tags: []string{"db"}, CreatedAt: testTime},
+		{NoteId: 1, Number: 2, Value: "second", Tags: []string{"ops"}, CreatedAt: testTime.Add(time.Hour)},
+	}
+	for i := range want {
+		if revisions[i].NoteId != want[i].NoteId || revisions[i].Number != want[i].Number || revisions[i].Value != want[i].Value ||
+			!reflect.DeepEqual(revisions[i].Tags, want[i].Tags) || !revisions[i].CreatedAt.Equal(want[i].CreatedAt) {
+			t.Errorf("Revisions()[%d] = %+v, want %+v", i, revisions[i], want[i])
+		}
+	}
+	if revisions, err := s.Revisions(ctx, 42); err != nil || len(revisions) != 0 {
+		t.Errorf("Revisions() of an unknown note = %v, %v, want none", revisions, err)
+	}
+
+	if err := s.Update(ctx, model.Note{Id: 42, Value: "x"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Update() of an unknown note error = %v, want ErrNotFound", err)
+	}
+	if _, err := s.Delete(ctx, model.Note{Id: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Update(ctx, model.Note{Id: 1, Value: "x"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Update() of a trashed note error = %v, want ErrNotFound", err)
+	}
+}
+
+func conformDelete(t *testing.T, s Store) {
+	ctx := context.Background()
+	writeNotes(t, s, []string{"db"}, "one", "two")
+	writeNotes(t, s, []string{"k8s"}, "three")
+	writeNotes(t, s, []string{"ops"}, "four", "five")
+	writeNotes(t, s, []string{"db/replica"}, "six")
+	writeNotes(t, s, []string{"dbx"}, "seven")
+
+	tests := []struct {
+		name    string
+		note    model.Note
+		deleted int64
+		left    []int64
+	}{
+		{name: "nothing selected", note: model.Note{}, deleted: 0, left: []int64{1, 2, 3, 4, 5, 6, 7}},
+		{name: "unknown id", note: model.Note{Id: 42}, deleted: 0, left: []int64{1, 2, 3, 4, 5, 6, 7}},
+		{name: "by id", note: model.Note{Id: 3}, deleted: 1, left: []int64{1, 2, 4, 5, 6, 7}},
+		{name: "trashed id", note: model.Note{Id: 3}, deleted: 0, left: []int64{1, 2, 4, 5, 6, 7}},
+		{name: "by tag and the tags below", note: model.Note{Tags: []string{"db", "k8s"}}, deleted: 3, left: []int64{4, 5, 7}},
+		{name: "everything", note: model.Note{Id: -1}, deleted: 3, left: []int64{}},
+	}
+	for _, tt := range tests {
+		deleted, err := s.Delete(ctx, tt.note)
+		if err != nil || deleted != tt.deleted {
+			t.Errorf("%s: Delete(%+v) = %d, %v, want %d", tt.name, tt.note, deleted, err, tt.deleted)
+		}
+		live, err := s.Read(ctx, model.Note{}, model.DateRange{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ids := noteIds(live); !reflect.DeepEqual(ids, tt.left) {
+			t.Errorf("%s: notes left = %v, want %v", tt.name, ids, tt.left)
+		}
+	}
+}
+
+// conformSearch only uses whole word keywords, which every Store matches
+// alike whether it ranks by full-text index or scans for substrings.
+func conformSearch(t *testing.T, s Store) {
+	ctx := context.Background()
+	writeNotes(t, s, []string{"db"}, "Restart Postgres", "vacuum postgres")
+	writeNotes(t, s, []string{"oncall"}, "drain node")
+	writeNotes(t, s, nil, "trashed postgres")
+	if _, err := s.Delete(ctx, model.Note{Id: 4}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		keyword string
+		want    []int64
+	}{
+		{keyword: "postgres", want: []int64{1, 2}},
+		{keyword: "RESTART", want: []int64{1}},
+		{keyword: "oncall", want: []int64{3}},
+		{keyword: "missing", want: []int64{}},
+	}
+	for _, tt := range tests {
+		got, err := s.Search(ctx, tt.keyword, model.DateRange{})
+		if err != nil {
+			t.Fatalf("Search(%q) error = %v", tt.keyword, err)
+		}
+		if ids := sortedIds(got); !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("Search(%q) = %v, want %v", tt.keyword, ids, tt.want)
+		}
+	}
+}
+
+func conformTags(t *testing.T, s Store) {
+	ctx := context.Background()
+	if tags, err := s.Tags(ctx); err != nil || len(tags) != 0 {
+		t.Errorf("Tags() of an empty store = %v, %v", tags, err)
+	}
+	now = func() time.Time { return testTime }
+	writeNotes(t, s, []string{"ops", "db"}, "one")
+	writeNotes(t, s, []string{"db"}, "two")
+	now = func() time.Time { return testTime.Add(time.Hour) }
+	writeNotes(t, s, []string{"db"}, "three")
+	if _, err := s.Delete(ctx, model.Note{Id: 1}); err != nil {
+		t.Fatal(err)
+	}
+	tags, err := s.Tags(ctx)
+	want := []model.Tag{{Name: "db", Notes: 2, LastUsed: testTime.Add(time.Hour)}, {Name: "ops", Notes: 0}}
+	if err != nil || !reflect.DeepEqual(tags, want) {
+		t.Errorf("Tags() = %v, %v, want %v", tags, err, want)
+	}
+}
+
+func conformMergeTags(t *testing.T, s Store) {
+	ctx := context.Background()
+	now = func() time.Time { return testTime }
+	writeNotes(t, s, []string{"k8s", "ops"}, "drain node")
+	writeNotes(t, s, []string{"K8S"}, "cordon node")
+	writeNotes(t, s, []string{"kubernetes", "k8s"}, "upgrade cluster")
+	writeNotes(t, s, []string{"db"}, "restart postgres")
+	if _, err := s.Delete(ctx, model.Note{Id: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.MergeTags(ctx, []string{"k8s", "missing"}, "kubernetes"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("MergeTags() of a missing tag error = %v, want ErrNotFound", err)
+	}
+	now = func() time.Time { return testTime.Add(time.Hour) }
+	merged, err := s.MergeTags(ctx, []string{"k8s", "K8S", "kubernetes"}, "kubernetes")
+	if err != nil || merged != 3 {
+		t.Fatalf("MergeTags() = %d, %v, want 3", merged, err)
+	}
+	notes, err := s.Read(ctx, model.Note{Tags: []string{"kubernetes"}}, model.DateRange{})
+	if err != nil || len(notes) != 2 || !reflect.DeepEqual(notes[0].Tags, []string{"ops", "kubernetes"}) ||
+		!reflect.DeepEqual(notes[1].Tags, []string{"kubernetes"}) || !notes[0].UpdatedAt.Equal(testTime.Add(time.Hour)) {
+		t.Errorf("Read() after MergeTags() = %+v, %v", notes, err)
+	}
+	trashed, err := s.Trashed(ctx)
+	if err != nil || len(trashed) != 1 || !reflect.DeepEqual(trashed[0].Tags, []string{"kubernetes"}) {
+		t.Errorf("Trashed() after MergeTags() = %+v, %v", trashed, err)
+	}
+	revisions, err := s.Revisions(ctx, 3)
+	if err != nil || len(revisions) != 1 || !reflect.DeepEqual(revisions[0].Tags, []string{"k8s", "kubernetes"}) {
+		t.Errorf("Revisions() after MergeTags() = %+v, %v", revisions, err)
+	}
+	tags, err := s.Tags(ctx)
+	want := []model.Tag{{Name: "db", Notes: 1, LastUsed: testTime}, {Name: "kubernetes", Notes: 2, LastUsed: testTime.Add(time.Hour)}, {Name: "ops", Notes: 1, LastUsed: testTime.Add(time.Hour)}}
+	if err != nil || !reflect.DeepEqual(tags, want) {
+		t.Errorf("Tags() after MergeTags() = %v, %v, want %v", tags, err, want)
+	}
+
+	if merged, err := s.MergeTags(ctx, []string{"db"}, "postgres"); err != nil || merged != 1 {
+		t.Errorf("MergeTags() renaming a tag = %d, %v, want 1", merged, err)
+	}
+	if notes, err := s.Read(ctx, model.Note{Tags: []string{"postgres"}}, model.DateRange{}); err != nil || noteIds(notes)[0] != 4 {
+		t.Errorf("Read() of the renamed tag = %+v, %v", notes, err)
+	}
+}
+
+func conformRenameTags(t *testing.T, s Store) {
+	ctx := context.Background()
+	now = func() time.Time { return testTime }
+	writeNotes(t, s, []string{"work", "work/oncall"}, "standup")
+	writeNotes(t, s, []string{"work/oncall/db"}, "failover")
+	writeNotes(t, s, []string{"workshop"}, "saw")
+	writeNotes(t, s, []string{"job/k8s"}, "drain node")
+	if _, err := s.Delete(ctx, model.Note{Id: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.RenameTags(ctx, map[string]string{"work": "job", "missing": "gone"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RenameTags() of a missing tag error = %v, want ErrNotFound", err)
+	}
+	now = func() time.Time { return testTime.Add(time.Hour) }
+	renamed, err := s.RenameTags(ctx, map[string]string{"work": "job", "work/oncall": "job/oncall", "work/oncall/db": "job/oncall/db"})
+	if err != nil || renamed != 2 {
+		t.Fatalf("RenameTags() = %d, %v, want 2", renamed, err)
+	}
+	notes, err := s.Read(ctx, model.Note{}, model.DateRange{})
+	if err != nil || len(notes) != 3 || !reflect.DeepEqual(notes[0].Tags, []string{"job", "job/oncall"}) ||
+		!reflect.DeepEqual(notes[1].Tags, []string{"workshop"}) || !notes[0].UpdatedAt.Equal(testTime.Add(time.Hour)) {
+		t.Errorf("Read() after RenameTags() = %+v, %v", notes, err)
+	}
+	trashed, err := s.Trashed(ctx)
+	if err != nil || len(trashed) != 1 || !reflect.DeepEqual(trashed[0].Tags, []string{"job/oncall/db"}) {
+		t.Errorf("Trashed() after RenameTags() = %+v, %v", trashed, err)
+	}
+	revisions, err := s.Revisions(ctx, 1)
+	if err != nil || len(revisions) != 1 || !reflect.DeepEqual(revisions[0].Tags, []string{"work", "work/oncall"}) {
+		t.Errorf("Revisions() after RenameTags() = %+v, %v", revisions, err)
+	}
+	tags, err := s.Tags(ctx)
+	var names []string
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	if want := []string{"job", "job/k8s", "job/oncall", "job/oncall/db", "workshop"}; err != nil || !reflect.DeepEqual(names, want) {
+		t.Errorf("Tags() after RenameTags() = %v, %v, want %v", names, err, want)
+	}
+}
+
+func conformPruneTags(t *testing.T, s Store) {
+	ctx := context.Background()
+	writeNotes(t, s, []string{"ops"}, "one")
+	writeNotes(t, s, []string{"db"}, "two")
+	writeNotes(t, s, []string{"old"}, "three")
+	if err := s.Update(ctx, model.Note{Id: 3, Value: "three"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Delete(ctx, model.Note{Id: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if pruned, err := s.PruneTags(ctx); err != nil || pruned != 1 {
+		t.Errorf("PruneTags() = %d, %v, want 1", pruned, err)
+	}
+	tags, err := s.Tags(ctx)
+	if err != nil || len(tags) != 2 || tags[0].Name != "db" || tags[1].Name != "ops" {
+		t.Errorf("Tags() after PruneTags() = %v, %v, want db kept for the trashed note", tags, err)
+	}
+}
+
+func conformSavedSearches(t *testing.T, s Store) {
+	ctx := context.Background()
+	for _, search := range []model.SavedSearch{
+		{Name: "oncall", Query: "tag:oncall created:this-week"},
+		{Name: "bytag", Query: "tag:$tag"},
+		{Name: "oncall", Query: "tag:oncall -tag:done"},
+	} {
+		if err := s.SaveSearch(ctx, search); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []model.SavedSearch{{Name: "bytag", Query: "tag:$tag"}, {Name: "oncall", Query: "tag:oncall -tag:done"}}
+	if got, err := s.SavedSearches(ctx); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("SavedSearches() = %v, %v, want %v", got, err, want)
+	}
+
+	if err := s.DeleteSavedSearch(ctx, "bytag"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteSavedSearch(ctx, "bytag"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeleteSavedSearch() of a deleted search error = %v, want ErrNotFound", err)
+	}
+	if _, err := s.Import(ctx, []model.Note{{Value: "one"}}, ImportReplace); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.SavedSearches(ctx); err != nil || !reflect.DeepEqual(got, want[1:]) {
+		t.Errorf("SavedSearches() after a replacing Import = %v, %v, want %v", got, err, want[1:])
+	}
+}
+
+func conformTrash(t *testing.T, s Store) {
+	ctx := context.Background()
+	writeNotes(t, s, []string{"work"}, "old", "recent", "kept")
+	now = func() time.Time { return testTime }
+	if err := s.Update(ctx, model.Note{Id: 1, Value: "old", Tags: []string{"work"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Delete(ctx, model.Note{Id: 1}); err != nil {
+		t.Fatal(err)
+	}
+	now = func() time.Time { return testTime.Add(48 * time.Hour) }
+	if _, err := s.Delete(ctx, model.Note{Id: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	trashed, err := s.Trashed(ctx)
+	if err != nil || !reflect.DeepEqual(noteIds(trashed), []int64{2, 1}) {
+		t.Fatalf("Trashed() = %v, %v, want notes 2 and 1", noteIds(trashed), err)
+	}
+	if !trashed[1].DeletedAt.Equal(testTime) || trashed[1].Value != "old" || !reflect.DeepEqual(trashed[1].Tags, []string{"work"}) {
+		t.Errorf("Trashed()[1] = %+v", trashed[1])
+	}
+	purgedUUID := trashed[1].UUID
+
+	if err := s.Restore(ctx, 3); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Restore() of a live note error = %v, want ErrNotFound", err)
+	}
+	if err := s.Restore(ctx, 42); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Restore() of an unknown note error = %v, want ErrNotFound", err)
+	}
+
+	purged, err := s.Purge(ctx, testTime.Add(time.Millisecond))
+	if err != nil || purged != 1 {
+		t.Errorf("Purge() = %d, %v, want 1", purged, err)
+	}
+	if revisions, err := s.Revisions(ctx, 1); err != nil || len(revisions) != 0 {
+		t.Errorf("Revisions() of a purged note = %v, %v, want none", revisions, err)
+	}
+	tombstones, err := s.Tombstones(ctx)
+	if want := []model.Tombstone{{UUID: purgedUUID, DeletedAt: testTime}}; err != nil || len(tombstones) != 1 ||
+		tombstones[0].UUID != purgedUUID || !tombstones[0].DeletedAt.Equal(testTime) {
+		t.Errorf("Tombstones() after Purge = %v, %v, want %v", tombstones, err, want)
+	}
+	if err := s.Restore(ctx, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Restore() of a purged note error = %v, want ErrNotFound", err)
+	}
+	if err := s.Restore(ctx, 2); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	restored, err := s.Read(ctx, model.Note{Id: 2}, model.DateRange{})
+	if err != nil || len(restored) != 1 || restored[0].Value != "recent" || !restored[0].DeletedAt.IsZero() ||
+		!restored[0].UpdatedAt.Equal(testTime.Add(48*time.Hour)) {
+		t.Errorf("Read() after Restore = %v, %v", restored, err)
+	}
+
+	if _, err := s.Delete(ctx, model.Note{Id: -1}); err != nil {
+		t.Fatal(err)
+	}
+	if purged, err := s.Purge(ctx, time.Time{}); err != nil || purged != 2 {
+		t.Errorf("Purge() of the whole trash = %d, %v, want 2", purged, err)
+	}
+	if trashed, err := s.Trashed(ctx); err != nil || len(trashed) != 0 {
+		t.Errorf("Trashed() after emptying = %v, %v", trashed, err)
+	}
+}
+
+func conformIdsAreNotReused(t *testing.T, s Store) {
+	ctx := context.Background()
+	writeNotes(t, s, nil, "one", "two")
+	if _, err := s.Delete(ctx, model.Note{Id: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Purge(ctx, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if id, err := s.Write(ctx, "three", nil); err != nil || id != 3 {
+		t.Errorf("Write() after purging note 2 = %d, %v, want 3", id, err)
+	}
+}
+
+func conformImportMerge(t *testing.T, s Store) {
+	ctx := context.Background()
+	now = func() time.Time { return testTime }
+	writeNotes(t, s, []string{"b", "a"}, "existing")
+	imported, err := s.Import(ctx, []model.Note{
+		{Id: 1, Value: "existing", Tags: []string{"a", "b"}},
+		{Id: 7, UUID: "imported", Value: "new", Tags: []string{"c"}, CreatedAt: testTime.Add(-time.Hour), DeletedAt: testTime},
+		{Value: "new", Tags: []string{"c"}},
+	}, ImportMerge)
+	if err != nil || imported != 1 {
+		t.Fatalf("Import() = %d, %v, want 1", imported, err)
+	}
+	trashed, err := s.Trashed(ctx)
+	if err != nil || len(trashed) != 1 {
+		t.Fatalf("Trashed() = %v, %v", trashed, err)
+	}
+	got := trashed[0]
+	if got.Id != 2 || got.UUID != "imported" || got.Value != "new" || !got.CreatedAt.Equal(testTime.Add(-time.Hour)) ||
+		!got.UpdatedAt.Equal(testTime) || !got.DeletedAt.Equal(testTime) {
+		t.Errorf("imported note = %+v", got)
+	}
+
+	if _, err := s.Import(ctx, []model.Note{{UUID: "imported", Value: "edited elsewhere"}}, ImportMerge); err != nil {
+		t.Fatal(err)
+	}
+	notes, err := s.Read(ctx, model.Note{Value: "edited elsewhere"}, model.DateRange{})
+	if err != nil || len(notes) != 1 || notes[0].UUID == "" || notes[0].UUID == "imported" {
+		t.Errorf("Import() of a stored UUID = %+v, %v, want a new UUID", notes, err)
+	}
+}
+
+func conformImportReplace(t *testing.T, s Store) {
+	ctx := context.Background()
+	writeNotes(t, s, []string{"old"}, "one", "two")
+	if err := s.Update(ctx, model.Note{Id: 1, Value: "uno"}); err != nil {
+		t.Fatal(err)
+	}
+	imported, err := s.Import(ctx, []model.Note{
+		{Value: "no id"},
+		{Id: 5, Value: "five", Tags: []string{"new"}},
+		{Id: 1, Value: "one again"},
+	}, ImportReplace)
+	if err != nil || imported != 3 {
+		t.Fatalf("Import() = %d, %v, want 3", imported, err)
+	}
+	notes, err := s.Read(ctx, model.Note{}, model.DateRange{})
+	if err != nil || !reflect.DeepEqual(noteIds(notes), []int64{1, 5, 6}) || notes[2].Value != "no id" {
+		t.Errorf("Read() after Import = %v, %v", notes, err)
+	}
+	if revisions, err := s.Revisions(ctx, 1); err != nil || len(revisions) != 0 {
+		t.Errorf("Revisions() after Import = %v, %v, want none", revisions, err)
+	}
+	if tags, err := s.Tags(ctx); err != nil || len(tags) != 1 || tags[0].Name != "new" || tags[0].Notes != 1 {
+		t.Errorf("Tags() after Import = %v, %v", tags, err)
+	}
+
+	_, err = s.Import(ctx, []model.Note{{Id: 2, Value: "a"}, {Id: 2, Value: "b"}}, ImportReplace)
+	if err == nil {
+		t.Errorf("Import() of a repeated Id succeeded")
+	}
+	if notes, err := s.Read(ctx, model.Note{}, model.DateRange{}); err != nil || len(notes) != 3 {
+		t.Errorf("Read() after a failed Import = %v, %v, want the 3 notes kept", notes, err)
+	}
+}
+
+func conformRewrite(t *testing.T, s Store) {
+	ctx := context.Background()
+	writeNotes(t, s, nil, "secret one", "plain", "secret two")
+	if err := s.Update(ctx, model.Note{Id: 1, Value: "secret three"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Delete(ctx, model.Note{Id: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	failure := errors.New("cannot rewrite")
+	if _, err := s.Rewrite(ctx, func(text string) (string, error) {
+		if text == "plain" {
+			return "", failure
+		}
+		return strings.ToUpper(text), nil
+	}); !errors.Is(err, failure) {
+		t.Errorf("Rewrite() error = %v, want %v", err, failure)
+	}
+	if notes, err := s.Read(ctx, model.Note{Id: 1}, model.DateRange{}); err != nil || notes[0].Value != "secret three" {
+		t.Errorf("Read() after a failed Rewrite = %v, %v", notes, err)
+	}
+
+	rewritten, err := s.Rewrite(ctx, func(text string) (string, error) {
+		if strings.HasPrefix(text, "secret") {
+			return strings.ToUpper(text), nil
+		}
+		return text, nil
+	})
+	if err != nil || rewritten != 3 {
+		t.Errorf("Rewrite() = %d, %v, want 3", rewritten, err)
+	}
+	if revisions, err := s.Revisions(ctx, 1); err != nil || revisions[0].Value != "SECRET ONE" {
+		t.Errorf("Revisions() after Rewrite = %v, %v", revisions, err)
+	}
+	if trashed, err := s.Trashed(ctx); err != nil || trashed[0].Value != "SECRET TWO" {
+		t.Errorf("Trashed() after Rewrite = %v, %v", trashed, err)
+	}
+}
+
+func conformUUIDs(t *testing.T, s Store) {
+	ctx := context.Background()
+	writeNotes(t, s, nil, "one", "two")
+	notes, err := s.Read(ctx, model.Note{}, model.DateRange{})
+	if err != nil || len(notes) != 2 {
+		t.Fatalf("Read() = %v, %v", notes, err)
+	}
+	if notes[0].UUID == "" || notes[0].UUID == notes[1].UUID {
+		t.Errorf("Write() gave UUIDs %q and %q, want two different ones", notes[0].UUID, notes[1].UUID)
+	}
+	if err := s.Update(ctx, model.Note{Id: 1, Value: "uno"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Delete(ctx, model.Note{Id: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if trashed, err := s.Trashed(ctx); err != nil || len(trashed) != 1 || trashed[0].UUID != notes[0].UUID {
+		t.Errorf("Trashed() = %+v, %v, want the UUID kept through Update and Delete", trashed, err)
+	}
+}
+
+func conformPut(t *testing.T, s Store) {
+	ctx := context.Background()
+	now = func() time.Time { return testTime }
+	writeNotes(t, s, []string{"db"}, "restart postgres")
+	written, err := s.Read(ctx, model.Note{}, model.DateRange{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	uuid := written[0].UUID
+	if _, err := s.Forget(ctx, []model.Tombstone{{UUID: "revived", DeletedAt: testTime}}); err != nil {
+		t.Fatal(err)
+	}
+
+	later := testTime.Add(time.Hour)
+	err = s.Put(ctx, []model.Note{
+		{UUID: uuid, Value: "restart postgres 16", Tags: []string{"db", "ops"}, CreatedAt: testTime, UpdatedAt: later, DeletedAt: later},
+		{UUID: "revived", Value: "from elsewhere", CreatedAt: testTime.Add(-time.Hour), UpdatedAt: testTime},
+	})
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	trashed, err := s.Trashed(ctx)
+	if err != nil || len(trashed) != 1 {
+		t.Fatalf("Trashed() = %v, %v", trashed, err)
+	}
+	got := trashed[0]
+	if got.Id != 1 || got.Value != "restart postgres 16" || !reflect.DeepEqual(got.Tags, []string{"db", "ops"}) ||
+		!got.UpdatedAt.Equal(later) || !got.DeletedAt.Equal(later) {
+		t.Errorf("replaced note = %+v", got)
+	}
+	if revisions, err := s.Revisions(ctx, 1); err != nil || len(revisions) != 1 || revisions[0].Value != "restart postgres" {
+		t.Errorf("Revisions() after Put = %v, %v, want the previous version", revisions, err)
+	}
+	live, err := s.Read(ctx, model.Note{}, model.DateRange{})
+	if err != nil || len(live) != 1 || live[0].Id != 2 || live[0].UUID != "revived" || !live[0].CreatedAt.Equal(testTime.Add(-time.Hour)) {
+		t.Errorf("Read() after Put = %+v, %v, want the added note as note 2", live, err)
+	}
+	if tombstones, err := s.Tombstones(ctx); err != nil || len(tombstones) != 0 {
+		t.Errorf("Tombstones() after Put = %v, %v, want the tombstone of the stored note dropped", tombstones, err)
+	}
+
+	got.Tags = []string{"ops", "db"}
+	if err := s.Put(ctx, []model.Note{got}); err != nil {
+		t.Fatal(err)
+	}
+	if revisions, err := s.Revisions(ctx, 1); err != nil || len(revisions) != 1 {
+		t.Errorf("Revisions() after an unchanged Put = %v, %v, want no new revision", revisions, err)
+	}
+}
+
+func conformForget(t *testing.T, s Store) {
+	ctx := context.Background()
+	writeNotes(t, s, nil, "one", "two")
+	if err := s.Update(ctx, model.Note{Id: 1, Value: "uno"}); err != nil {
+		t.Fatal(err)
+	}
+	notes, err := s.Read(ctx, model.Note{}, model.DateRange{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forgotten, err := s.Forget(ctx, []model.Tombstone{
+		{UUID: notes[0].UUID, DeletedAt: testTime},
+		{UUID: "never seen", DeletedAt: testTime},
+	})
+	if err != nil || forgotten != 1 {
+		t.Errorf("Forget() = %d, %v, want 1", forgotten, err)
+	}
+	if live, err := s.Read(ctx, model.Note{}, model.DateRange{}); err != nil || !reflect.DeepEqual(noteIds(live), []int64{2}) {
+		t.Errorf("Read() after Forget = %v, %v, want note 2", noteIds(live), err)
+	}
+	if revisions, err := s.Revisions(ctx, 1); err != nil || len(revisions) != 0 {
+		t.Errorf("Revisions() of a forgotten note = %v, %v, want none", revisions, err)
+	}
+
+	if _, err := s.Forget(ctx, []model.Tombstone{{UUID: "never seen", DeletedAt: testTime.Add(-time.Hour)}}); err != nil {
+		t.Fatal(err)
+	}
+	tombstones, err := s.Tombstones(ctx)
+	if err != nil || len(tombstones) != 2 {
+		t.Fatalf("Tombstones() = %v, %v, want 2", tombstones, err)
+	}
+	for _, tombstone := range tombstones {
+		if (tombstone.UUID != notes[0].UUID && tombstone.UUID != "never seen") || !tombstone.DeletedAt.Equal(testTime) {
+			t.Errorf("tombstone = %+v, want the later time of each UUID", tombstone)
+		}
+	}
+	if id, err := s.Write(ctx, "three", nil); err != nil || id != 3 {
+		t.Errorf("Write() after Forget = %d, %v, want 3", id, err)
+	}
+}
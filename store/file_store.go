@@ -0,0 +1,760 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iamunni/hugnin/filter"
+	"github.com/iamunni/hugnin/model"
+	"gopkg.in/yaml.v3"
+)
+
+// FileStore keeps every note as a Markdown file in a directory, so notes
+// can be read, edited and versioned with ordinary tools:
+//
+//	12.md                      a note, named after its Id
+//	.hugnin/index.yaml         the last Id handed out, the known tags, the
+//	                           tombstones of purged notes and the saved
+//	                           searches
+//	.hugnin/trash/7.md         a note in the trash
+//	.hugnin/revisions/12/1.md  an earlier version of note 12
+//	.hugnin/lock               locked by every call
+//
+// Notes carry their tags, timestamps and UUID in a YAML front matter. Notes
+// written by hand without a UUID get one the next time the store is
+// opened or changed. Every call
+// reads the directory under a lock on .hugnin/lock, shared for reads and
+// exclusive for changes, so several hugnin processes can use the same
+// directory at once. Changes are applied with the semantics of MemoryStore
+// and written back file by file, each file being replaced atomically.
+type FileStore struct {
+	dir  string
+	mu   sync.Mutex
+	lock *os.File
+}
+
+// fileIndex is the content of .hugnin/index.yaml.
+type fileIndex struct {
+	Version int   `yaml:"version"`
+	LastId  int64 `yaml:"last_id"`
+	// Tags lists every tag in creation order, which is the order the tags
+	// of a note are returned in.
+	Tags []string `yaml:"tags,flow"`
+	// Tombstones maps the UUID of every purged note to the time it was
+	// trashed.
+	Tombstones map[string]string `yaml:"tombstones,omitempty"`
+	// Searches maps the name of every saved search to its query.
+	Searches map[string]string `yaml:"searches,omitempty"`
+}
+
+const fileIndexVersion = 1
+
+// fileFrontMatter is the header of note and revision files.
+type fileFrontMatter struct {
+	Tags      []string `yaml:"tags,flow"`
+	CreatedAt string   `yaml:"created_at"`
+	UpdatedAt string   `yaml:"updated_at,omitempty"`
+	DeletedAt string   `yaml:"deleted_at,omitempty"`
+	UUID      string   `yaml:"uuid,omitempty"`
+}
+
+const fileFrontMatterDelimiter = "---"
+
+// NewFileStore returns a store keeping notes in dir. Call Open before
+// using it.
+func NewFileStore(dir string) Store {
+	return &FileStore{
+		dir: dir,
+	}
+}
+
+// Open creates the directory and its index when needed, and gives the
+// notes written by hand a UUID.
+func (s *FileStore) Open(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lock != nil {
+		return nil
+	}
+	for _, dir := range []string{s.dir, s.metaPath(), s.metaPath("trash"), s.metaPath("revisions")} {
+		err := os.MkdirAll(dir, 0o755)
+		if err != nil {
+			return err
+		}
+	}
+	lock, err := os.OpenFile(s.metaPath("lock"), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	s.lock = lock
+
+	err = s.withLock(true, func() error {
+		_, err := os.Stat(s.metaPath("index.yaml"))
+		if errors.Is(err, fs.ErrNotExist) {
+			return s.writeIndex(fileIndex{Version: fileIndexVersion})
+		}
+		if err != nil {
+			return err
+		}
+		before, err := s.load()
+		if err != nil {
+			return err
+		}
+		after := before.clone()
+		after.fillUUIDs()
+		return s.save(before, after)
+	})
+	if err != nil {
+		s.lock.Close()
+		s.lock = nil
+		return err
+	}
+	return nil
+}
+
+// Close releases the lock file. Closing a closed store is a no-op.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lock == nil {
+		return nil
+	}
+	err := s.lock.Close()
+	s.lock = nil
+	return err
+}
+
+// Init does nothing more than Open; the directory is created there.
+func (s *FileStore) Init(ctx context.Context, dbFile string) error {
+	return s.view(func(m *MemoryStore) error { return nil })
+}
+
+func (s *FileStore) Write(ctx context.Context, value string, tags []string) (int64, error) {
+	var id int64
+	err := s.change(func(m *MemoryStore) (err error) {
+		id, err = m.Write(ctx, value, tags)
+		return err
+	})
+	return id, err
+}
+
+func (s *FileStore) Read(ctx context.Context, note model.Note, period model.DateRange) ([]model.Note, error) {
+	var result []model.Note
+	err := s.view(func(m *MemoryStore) (err error) {
+		result, err = m.Read(ctx, note, period)
+		return err
+	})
+	return result, err
+}
+
+// Update replaces the text and tags of the note with note.Id, keeping the
+// previous version as a revision.
+func (s *FileStore) Update(ctx context.Context, note model.Note) error {
+	return s.change(func(m *MemoryStore) error {
+		return m.Update(ctx, note)
+	})
+}
+
+// Revisions returns the earlier versions of the note with the given Id,
+// oldest first.
+func (s *FileStore) Revisions(ctx context.Context, id int64) ([]model.Revision, error) {
+	var result []model.Revision
+	err := s.view(func(m *MemoryStore) (err error) {
+		result, err = m.Revisions(ctx, id)
+		return err
+	})
+	return result, err
+}
+
+// Delete moves the notes selected by note to the trash and returns how many
+// were moved, selecting them as SQLiteStore does.
+func (s *FileStore) Delete(ctx context.Context, note model.Note) (int64, error) {
+	var deleted int64
+	err := s.change(func(m *MemoryStore) (err error) {
+		deleted, err = m.Delete(ctx, note)
+		return err
+	})
+	return deleted, err
+}
+
+// Search matches keyword as a case insensitive substring of the note or of
+// any of its tags.
+func (s *FileStore) Search(ctx context.Context, keyword string, period model.DateRange) ([]model.Note, error) {
+	var result []model.Note
+	err := s.view(func(m *MemoryStore) (err error) {
+		result, err = m.Search(ctx, keyword, period)
+		return err
+	})
+	return result, err
+}
+
+func (s *FileStore) Find(ctx context.Context, expr filter.Expr, period model.DateRange) ([]model.Note, error) {
+	var result []model.Note
+	err := s.view(func(m *MemoryStore) (err error) {
+		result, err = m.Find(ctx, expr, period)
+		return err
+	})
+	return result, err
+}
+
+// Tags returns every tag with the number of live notes carrying it and
+// their latest update time, ordered by name.
+func (s *FileStore) Tags(ctx context.Context) ([]model.Tag, error) {
+	var result []model.Tag
+	err := s.view(func(m *MemoryStore) (err error) {
+		result, err = m.Tags(ctx)
+		return err
+	})
+	return result, err
+}
+
+// MergeTags replaces the tags from with the tag into on every note
+// carrying one of them, keeping the replaced versions as revisions, and
+// removes the tags from. It returns how many notes changed.
+func (s *FileStore) MergeTags(ctx context.Context, from []string, into string) (int64, error) {
+	var merged int64
+	err := s.change(func(m *MemoryStore) (err error) {
+		merged, err = m.MergeTags(ctx, from, into)
+		return err
+	})
+	return merged, err
+}
+
+// RenameTags gives each tag that is a key of renames the name it maps to,
+// keeping the replaced versions as revisions. It returns how many notes
+// changed.
+func (s *FileStore) RenameTags(ctx context.Context, renames map[string]string) (int64, error) {
+	var renamed int64
+	err := s.change(func(m *MemoryStore) (err error) {
+		renamed, err = m.RenameTags(ctx, renames)
+		return err
+	})
+	return renamed, err
+}
+
+// PruneTags removes the tags no note carries, trashed notes included, and
+// returns how many were removed.
+func (s *FileStore) PruneTags(ctx context.Context) (int64, error) {
+	var pruned int64
+	err := s.change(func(m *MemoryStore) (err error) {
+		pruned, err = m.PruneTags(ctx)
+		return err
+	})
+	return pruned, err
+}
+
+func (s *FileStore) SaveSearch(ctx context.Context, search model.SavedSearch) error {
+	return s.change(func(m *MemoryStore) error {
+		return m.SaveSearch(ctx, search)
+	})
+}
+
+// SavedSearches returns the saved searches ordered by name.
+func (s *FileStore) SavedSearches(ctx context.Context) ([]model.SavedSearch, error) {
+	var result []model.SavedSearch
+	err := s.view(func(m *MemoryStore) (err error) {
+		result, err = m.SavedSearches(ctx)
+		return err
+	})
+	return result, err
+}
+
+func (s *FileStore) DeleteSavedSearch(ctx context.Context, name string) error {
+	return s.change(func(m *MemoryStore) error {
+		return m.DeleteSavedSearch(ctx, name)
+	})
+}
+
+// Trashed returns the notes in the trash, most recently deleted first.
+func (s *FileStore) Trashed(ctx context.Context) ([]model.Note, error) {
+	var result []model.Note
+	err := s.view(func(m *MemoryStore) (err error) {
+		result, err = m.Trashed(ctx)
+		return err
+	})
+	return result, err
+}
+
+// Restore moves the note with the given Id out of the trash.
+func (s *FileStore) Restore(ctx context.Context, id int64) error {
+	return s.change(func(m *MemoryStore) error {
+		return m.Restore(ctx, id)
+	})
+}
+
+// Purge permanently removes the notes trashed before cutoff, or every
+// trashed note when cutoff is zero, and returns how many were removed.
+func (s *FileStore) Purge(ctx context.Context, cutoff time.Time) (int64, error) {
+	var purged int64
+	err := s.change(func(m *MemoryStore) (err error) {
+		purged, err = m.Purge(ctx, cutoff)
+		return err
+	})
+	return purged, err
+}
+
+// Import writes notes with their tags, timestamps and trash state and
+// returns how many were added. Nothing is written when any note fails.
+func (s *FileStore) Import(ctx context.Context, notes []model.Note, mode ImportMode) (int64, error) {
+	var imported int64
+	err := s.change(func(m *MemoryStore) (err error) {
+		imported, err = m.Import(ctx, notes, mode)
+		return err
+	})
+	return imported, err
+}
+
+// Rewrite passes the text of every note and revision through rewrite,
+// leaving timestamps and revisions alone. Nothing is written when rewrite
+// fails.
+func (s *FileStore) Rewrite(ctx context.Context, rewrite func(text string) (string, error)) (int64, error) {
+	var rewritten int64
+	err := s.change(func(m *MemoryStore) (err error) {
+		rewritten, err = m.Rewrite(ctx, rewrite)
+		return err
+	})
+	return rewritten, err
+}
+
+// Put stores notes by UUID, keeping their timestamps and trash state. A
+// stored note with the same UUID is replaced, its previous version
+// becoming a revision when the text or tags change.
+func (s *FileStore) Put(ctx context.Context, notes []model.Note) error {
+	return s.change(func(m *MemoryStore) error {
+		return m.Put(ctx, notes)
+	})
+}
+
+// Forget permanently removes the notes with the UUIDs of tombstones and
+// keeps the tombstones in the index. It returns how many notes were
+// removed.
+func (s *FileStore) Forget(ctx context.Context, tombstones []model.Tombstone) (int64, error) {
+	var forgotten int64
+	err := s.change(func(m *MemoryStore) (err error) {
+		forgotten, err = m.Forget(ctx, tombstones)
+		return err
+	})
+	return forgotten, err
+}
+
+// Tombstones returns the tombstones of the removed notes, ordered by UUID.
+func (s *FileStore) Tombstones(ctx context.Context) ([]model.Tombstone, error) {
+	var result []model.Tombstone
+	err := s.view(func(m *MemoryStore) (err error) {
+		result, err = m.Tombstones(ctx)
+		return err
+	})
+	return result, err
+}
+
+// view runs fn on the notes of the directory under a shared lock.
+func (s *FileStore) view(fn func(m *MemoryStore) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lock == nil {
+		return errStoreClosed
+	}
+	return s.withLock(false, func() error {
+		state, err := s.load()
+		if err != nil {
+			return err
+		}
+		state.fillUUIDs()
+		return fn(&MemoryStore{open: true, state: state})
+	})
+}
+
+// change runs fn on the notes of the directory under an exclusive lock
+// and writes back the files of the notes fn changed.
+func (s *FileStore) change(fn func(m *MemoryStore) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lock == nil {
+		return errStoreClosed
+	}
+	return s.withLock(true, func() error {
+		before, err := s.load()
+		if err != nil {
+			return err
+		}
+		m := &MemoryStore{open: true, state: before.clone()}
+		m.state.fillUUIDs()
+		err = fn(m)
+		if err != nil {
+			return err
+		}
+		return s.save(before, m.state)
+	})
+}
+
+// withLock runs fn holding the lock file, exclusively when exclusive is
+// set.
+func (s *FileStore) withLock(exclusive bool, fn func() error) error {
+	err := lockFile(s.lock, exclusive)
+	if err != nil {
+		return fmt.Errorf("locking %s: %w", s.lock.Name(), err)
+	}
+	defer unlockFile(s.lock)
+	return fn()
+}
+
+// metaPath returns the path of name inside the .hugnin directory.
+func (s *FileStore) metaPath(name ...string) string {
+	return filepath.Join(append([]string{s.dir, ".hugnin"}, name...)...)
+}
+
+func (s *FileStore) notePath(id int64, n *memoryNote) string {
+	name := strconv.FormatInt(id, 10) + ".md"
+	if !n.deletedAt.IsZero() {
+		return s.metaPath("trash", name)
+	}
+	return filepath.Join(s.dir, name)
+}
+
+func (s *FileStore) revisionPath(id int64, number int) string {
+	return s.metaPath("revisions", strconv.FormatInt(id, 10), strconv.Itoa(number)+".md")
+}
+
+func (s *FileStore) readIndex() (fileIndex, error) {
+	var index fileIndex
+	data, err := os.ReadFile(s.metaPath("index.yaml"))
+	if err != nil {
+		return index, err
+	}
+	err = yaml.Unmarshal(data, &index)
+	if err != nil {
+		return index, fmt.Errorf("%s: %w", s.metaPath("index.yaml"), err)
+	}
+	if index.Version > fileIndexVersion {
+		return index, fmt.Errorf("%s has version %d, newer than the latest known version %d, upgrade hugnin", s.metaPath("index.yaml"), index.Version, fileIndexVersion)
+	}
+	return index, nil
+}
+
+func (s *FileStore) writeIndex(index fileIndex) error {
+	data, err := yaml.Marshal(index)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.metaPath("index.yaml"), data)
+}
+
+// load reads the whole directory into a memoryState. Note files whose
+// name is not an Id are ignored.
+func (s *FileStore) load() (memoryState, error) {
+	state := newMemoryState()
+	index, err := s.readIndex()
+	if err != nil {
+		return state, err
+	}
+	state.lastId = index.LastId
+	for _, tag := range index.Tags {
+		state.lastTagId++
+		state.tags[tag] = state.lastTagId
+	}
+	for name, query := range index.Searches {
+		state.searches[name] = query
+	}
+	for uuid, deletedAt := range index.Tombstones {
+		state.tombstones[uuid], err = parseTime(deletedAt)
+		if err != nil {
+			return state, fmt.Errorf("%s: tombstone %s: %w", s.metaPath("index.yaml"), uuid, err)
+		}
+	}
+
+	for _, dir := range []string{s.dir, s.metaPath("trash")} {
+		files, err := idFiles(dir)
+		if err != nil {
+			return state, err
+		}
+		for _, file := range files {
+			n, tags, err := readNoteFile(file.path)
+			if err != nil {
+				return state, err
+			}
+			if _, ok := state.notes[file.id]; ok {
+				return state, fmt.Errorf("note %d is both in %s and in the trash", file.id, s.dir)
+			}
+			state.insert(file.id, n, tags)
+		}
+	}
+
+	for id := range state.notes {
+		files, err := idFiles(s.metaPath("revisions", strconv.FormatInt(id, 10)))
+		if err != nil {
+			return state, err
+		}
+		for i, file := range files {
+			if file.id != int64(i+1) {
+				return state, fmt.Errorf("note %d has no revision %d", id, i+1)
+			}
+			n, tags, err := readNoteFile(file.path)
+			if err != nil {
+				return state, err
+			}
+			state.revisions[id] = append(state.revisions[id], model.Revision{
+				NoteId:    id,
+				Number:    int(file.id),
+				Value:     n.value,
+				Tags:      tags,
+				CreatedAt: n.createdAt,
+			})
+		}
+	}
+	return state, nil
+}
+
+// save writes the files of the notes, revisions and index that differ
+// between before and after, and removes the ones after no longer has.
+func (s *FileStore) save(before, after memoryState) error {
+	ids := map[int64]bool{}
+	for id := range before.notes {
+		ids[id] = true
+	}
+	for id := range after.notes {
+		ids[id] = true
+	}
+	for id := range ids {
+		old, n := before.notes[id], after.notes[id]
+		if n == nil {
+			err := removeFiles(s.notePath(id, old), s.metaPath("revisions", strconv.FormatInt(id, 10)))
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if old == nil || !reflect.DeepEqual(*old, *n) {
+			data, err := formatNoteFile(n.value, n.tags, fileFrontMatter{
+				CreatedAt: formatTime(n.createdAt),
+				UpdatedAt: formatTime(n.updatedAt),
+				DeletedAt: formatStoredTime(n.deletedAt),
+				UUID:      n.uuid,
+			})
+			if err != nil {
+				return err
+			}
+			err = writeFileAtomic(s.notePath(id, n), data)
+			if err != nil {
+				return err
+			}
+		}
+		if old != nil && s.notePath(id, old) != s.notePath(id, n) {
+			err := removeFiles(s.notePath(id, old))
+			if err != nil {
+				return err
+			}
+		}
+		err := s.saveRevisions(id, before.revisions[id], after.revisions[id])
+		if err != nil {
+			return err
+		}
+	}
+
+	index := fileIndex{Version: fileIndexVersion, LastId: after.lastId, Tags: make([]string, 0, len(after.tags))}
+	for tag := range after.tags {
+		index.Tags = append(index.Tags, tag)
+	}
+	sort.Slice(index.Tags, func(i, j int) bool { return after.tags[index.Tags[i]] < after.tags[index.Tags[j]] })
+	if len(after.tombstones) > 0 {
+		index.Tombstones = make(map[string]string, len(after.tombstones))
+		for uuid, deletedAt := range after.tombstones {
+			index.Tombstones[uuid] = formatTime(deletedAt)
+		}
+	}
+	if len(after.searches) > 0 {
+		index.Searches = after.searches
+	}
+	if after.lastId != before.lastId || !reflect.DeepEqual(after.tags, before.tags) || !reflect.DeepEqual(after.tombstones, before.tombstones) ||
+		!reflect.DeepEqual(after.searches, before.searches) {
+		return s.writeIndex(index)
+	}
+	return nil
+}
+
+func (s *FileStore) saveRevisions(id int64, before, after []model.Revision) error {
+	if len(after) == 0 && len(before) > 0 {
+		return removeFiles(s.metaPath("revisions", strconv.FormatInt(id, 10)))
+	}
+	for i, r := range after {
+		if i < len(before) && reflect.DeepEqual(before[i], r) {
+			continue
+		}
+		err := os.MkdirAll(filepath.Dir(s.revisionPath(id, r.Number)), 0o755)
+		if err != nil {
+			return err
+		}
+		data, err := formatNoteFile(r.Value, r.Tags, fileFrontMatter{
+			CreatedAt: formatTime(r.CreatedAt),
+		})
+		if err != nil {
+			return err
+		}
+		err = writeFileAtomic(s.revisionPath(id, r.Number), data)
+		if err != nil {
+			return err
+		}
+	}
+	for _, r := range before[min(len(after), len(before)):] {
+		err := removeFiles(s.revisionPath(id, r.Number))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// idFile is a file named <id>.md.
+type idFile struct {
+	id   int64
+	path string
+}
+
+// idFiles returns the files of dir named <id>.md, ordered by Id. A missing
+// dir has none.
+func idFiles(dir string) ([]idFile, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var files []idFile
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".md")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		id, err := strconv.ParseInt(name, 10, 64)
+		if err != nil || id <= 0 || strconv.FormatInt(id, 10) != name {
+			continue
+		}
+		files = append(files, idFile{id: id, path: filepath.Join(dir, entry.Name())})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].id < files[j].id })
+	return files, nil
+}
+
+// formatNoteFile returns text under a front matter holding tags and the
+// timestamps of header.
+func formatNoteFile(text string, tags []string, header fileFrontMatter) ([]byte, error) {
+	header.Tags = tags
+	if header.Tags == nil {
+		header.Tags = []string{}
+	}
+	front, err := yaml.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.WriteString(fileFrontMatterDelimiter + "\n")
+	buf.Write(front)
+	buf.WriteString(fileFrontMatterDelimiter + "\n")
+	buf.WriteString(text)
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+// readNoteFile reads a file written by formatNoteFile, or edited by hand.
+// A file without front matter is all text, and missing timestamps default
+// to the modification time of the file.
+func readNoteFile(path string) (*memoryNote, []string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	text := strings.TrimSuffix(string(data), "\n")
+	var header fileFrontMatter
+	if rest, ok := strings.CutPrefix(text, fileFrontMatterDelimiter+"\n"); ok {
+		front, body, found := strings.Cut(rest, "\n"+fileFrontMatterDelimiter+"\n")
+		if !found {
+			front, found = strings.CutSuffix(rest, "\n"+fileFrontMatterDelimiter)
+		}
+		if found {
+			err = yaml.Unmarshal([]byte(front), &header)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: front matter: %w", path, err)
+			}
+			text = body
+		}
+	}
+
+	n := &memoryNote{uuid: header.UUID, value: text}
+	modified := storedTime(info.ModTime())
+	for _, field := range []struct {
+		value string
+		dest  *time.Time
+	}{
+		{header.CreatedAt, &n.createdAt},
+		{header.UpdatedAt, &n.updatedAt},
+		{header.DeletedAt, &n.deletedAt},
+	} {
+		*field.dest, err = parseTime(field.value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if n.createdAt.IsZero() {
+		n.createdAt = modified
+	}
+	if n.updatedAt.IsZero() {
+		n.updatedAt = modified
+	}
+	return n, header.Tags, nil
+}
+
+// writeFileAtomic replaces path with data through a temporary file, so
+// readers never see a partly written file.
+func writeFileAtomic(path string, data []byte) error {
+	file, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	_, err = file.Write(data)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	err = file.Sync()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	err = file.Close()
+	if err != nil {
+		return err
+	}
+	err = os.Chmod(file.Name(), 0o644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// removeFiles removes paths and everything below them, ignoring the ones
+// that do not exist.
+func removeFiles(paths ...string) error {
+	for _, path := range paths {
+		err := os.RemoveAll(path)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
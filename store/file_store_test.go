@@ -0,0 +1,151 @@
+package store
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iamunni/hugnin/model"
+)
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestFileStore_Files(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s := openTestStore(t, NewFileStore(dir))
+	defer func() { now, newUUID = time.Now, randomUUID }()
+	now = func() time.Time { return testTime }
+	newUUID = func() string { return "7c9e6679-7425-40de-944b-e07fc1f90ae7" }
+
+	if _, err := s.Write(ctx, "# Restart\n\nsystemctl restart postgres\n", []string{"db", "ops"}); err != nil {
+		t.Fatal(err)
+	}
+	want := "---\ntags: [db, ops]\ncreated_at: \"2024-01-02T03:04:05.000Z\"\nupdated_at: \"2024-01-02T03:04:05.000Z\"\nuuid: 7c9e6679-7425-40de-944b-e07fc1f90ae7\n---\n# Restart\n\nsystemctl restart postgres\n\n"
+	if got := readTestFile(t, filepath.Join(dir, "1.md")); got != want {
+		t.Errorf("1.md = %q, want %q", got, want)
+	}
+	if got := readTestFile(t, filepath.Join(dir, ".hugnin", "index.yaml")); got != "version: 1\nlast_id: 1\ntags: [db, ops]\n" {
+		t.Errorf("index.yaml = %q", got)
+	}
+
+	now = func() time.Time { return testTime.Add(time.Hour) }
+	if err := s.Update(ctx, model.Note{Id: 1, Value: "restart", Tags: []string{"db"}}); err != nil {
+		t.Fatal(err)
+	}
+	want = "---\ntags: [db, ops]\ncreated_at: \"2024-01-02T03:04:05.000Z\"\n---\n# Restart\n\nsystemctl restart postgres\n\n"
+	if got := readTestFile(t, filepath.Join(dir, ".hugnin", "revisions", "1", "1.md")); got != want {
+		t.Errorf("revision 1 = %q, want %q", got, want)
+	}
+
+	if _, err := s.Delete(ctx, model.Note{Id: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "1.md")); !os.IsNotExist(err) {
+		t.Errorf("1.md still exists after Delete: %v", err)
+	}
+	want = "---\ntags: [db]\ncreated_at: \"2024-01-02T03:04:05.000Z\"\nupdated_at: \"2024-01-02T04:04:05.000Z\"\ndeleted_at: \"2024-01-02T04:04:05.000Z\"\nuuid: 7c9e6679-7425-40de-944b-e07fc1f90ae7\n---\nrestart\n"
+	if got := readTestFile(t, filepath.Join(dir, ".hugnin", "trash", "1.md")); got != want {
+		t.Errorf("trashed 1.md = %q, want %q", got, want)
+	}
+
+	if _, err := s.Purge(ctx, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{".hugnin/trash/1.md", ".hugnin/revisions/1"} {
+		if _, err := os.Stat(filepath.Join(dir, path)); !os.IsNotExist(err) {
+			t.Errorf("%s still exists after Purge: %v", path, err)
+		}
+	}
+	want = "version: 1\nlast_id: 1\ntags: [db, ops]\ntombstones:\n    7c9e6679-7425-40de-944b-e07fc1f90ae7: \"2024-01-02T04:04:05.000Z\"\n"
+	if got := readTestFile(t, filepath.Join(dir, ".hugnin", "index.yaml")); got != want {
+		t.Errorf("index.yaml after Purge = %q, want %q", got, want)
+	}
+}
+
+func TestFileStore_HandEditedNotes(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s := openTestStore(t, NewFileStore(dir))
+	if _, err := s.Write(ctx, "first", []string{"db"}); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"7.md":      "---\ntags: [ops, db]\ncreated_at: 2024-01-02T03:04:05.000Z\n---\nwritten by hand\n",
+		"8.md":      "no front matter\n",
+		"README.md": "not a note\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	notes, err := s.Read(ctx, model.Note{}, model.DateRange{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != 3 || notes[1].Id != 7 || notes[1].Value != "written by hand" || !reflect.DeepEqual(notes[1].Tags, []string{"db", "ops"}) ||
+		!notes[1].CreatedAt.Equal(testTime) || notes[2].Id != 8 || notes[2].Value != "no front matter" || notes[2].CreatedAt.IsZero() {
+		t.Errorf("Read() = %+v", notes)
+	}
+	if id, err := s.Write(ctx, "next", nil); err != nil || id != 9 {
+		t.Errorf("Write() after hand edits = %d, %v, want 9", id, err)
+	}
+	again, err := s.Read(ctx, model.Note{Id: 7}, model.DateRange{})
+	if err != nil || len(again) != 1 || again[0].UUID == "" {
+		t.Fatalf("Read() = %+v, %v", again, err)
+	}
+	if !strings.Contains(readTestFile(t, filepath.Join(dir, "7.md")), "uuid: "+again[0].UUID+"\n") {
+		t.Errorf("7.md does not keep its UUID %s after a change", again[0].UUID)
+	}
+	if tags, err := s.Tags(ctx); err != nil || len(tags) != 2 || tags[0].Name != "db" || tags[0].Notes != 2 || tags[1].Name != "ops" || tags[1].Notes != 1 {
+		t.Errorf("Tags() = %v, %v", tags, err)
+	}
+}
+
+func TestFileStore_ConcurrentProcesses(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	// Each store holds its own lock file descriptor, as two hugnin
+	// processes would.
+	stores := []Store{openTestStore(t, NewFileStore(dir)), openTestStore(t, NewFileStore(dir))}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 40)
+	for i := 0; i < 40; i++ {
+		wg.Add(1)
+		go func(s Store) {
+			defer wg.Done()
+			_, err := s.Write(ctx, "note", []string{"tag"})
+			errs <- err
+		}(stores[i%2])
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	notes, err := stores[0].Read(ctx, model.Note{}, model.DateRange{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != 40 || notes[0].Id != 1 || notes[39].Id != 40 {
+		t.Errorf("Read() after concurrent writes = %d notes, want Ids 1 to 40", len(notes))
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !unix && !windows
+
+package store
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// errLockUnsupported is returned on systems without file locks: the files
+// backend refuses to run there rather than risk concurrent writers.
+var errLockUnsupported = fmt.Errorf("the files backend needs file locks, which %s does not support", runtime.GOOS)
+
+func lockFile(file *os.File, exclusive bool) error {
+	return errLockUnsupported
+}
+
+func unlockFile(file *os.File) error {
+	return errLockUnsupported
+}
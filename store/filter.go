@@ -0,0 +1,125 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/iamunni/hugnin/filter"
+	"github.com/iamunni/hugnin/model"
+)
+
+// dateColumns maps the fields of filter.Date to their columns.
+var dateColumns = map[string]string{
+	filter.Created: "n.created_at",
+	filter.Updated: "n.updated_at",
+}
+
+// buildFindQuery returns the statement used by Find.
+func buildFindQuery(expr filter.Expr, period model.DateRange) (string, []interface{}, error) {
+	q := newQuery(selectNotesSQL)
+	q.live()
+	q.within(period)
+	if expr != nil {
+		cond, args, err := filterSQL(expr)
+		if err != nil {
+			return "", nil, err
+		}
+		q.where(cond, args...)
+	}
+	stmt, args := q.suffix("ORDER BY n.id").build()
+	return stmt, args, nil
+}
+
+// filterSQL compiles expr to a condition on the notes n, with one "?"
+// placeholder per returned arg.
+func filterSQL(expr filter.Expr) (string, []interface{}, error) {
+	switch e := expr.(type) {
+	case filter.And:
+		return joinFilterSQL(e.Left, e.Right, " AND ")
+	case filter.Or:
+		return joinFilterSQL(e.Left, e.Right, " OR ")
+	case filter.Not:
+		cond, args, err := filterSQL(e.Expr)
+		if err != nil {
+			return "", nil, err
+		}
+		return "NOT (" + cond + ")", args, nil
+	case filter.Text:
+		return `n.note LIKE ? ESCAPE '\'`, []interface{}{"%" + escapeLike(e.Value) + "%"}, nil
+	case filter.Tag:
+		tagged, args := notesUnderTagsSQL([]string{e.Name})
+		return "n.id IN (" + tagged + ")", args, nil
+	case filter.Date:
+		column, ok := dateColumns[e.Field]
+		if !ok {
+			return "", nil, fmt.Errorf("unknown date field %q", e.Field)
+		}
+		var conds []string
+		var args []interface{}
+		if !e.Since.IsZero() {
+			conds = append(conds, column+" >= ?")
+			args = append(args, formatTime(e.Since))
+		}
+		if !e.Until.IsZero() {
+			conds = append(conds, column+" < ?")
+			args = append(args, formatTime(e.Until))
+		}
+		if len(conds) == 0 {
+			return "1", nil, nil
+		}
+		return "(" + strings.Join(conds, " AND ") + ")", args, nil
+	default:
+		return "", nil, fmt.Errorf("unknown query node %T", expr)
+	}
+}
+
+func joinFilterSQL(left, right filter.Expr, op string) (string, []interface{}, error) {
+	l, largs, err := filterSQL(left)
+	if err != nil {
+		return "", nil, err
+	}
+	r, rargs, err := filterSQL(right)
+	if err != nil {
+		return "", nil, err
+	}
+	return "(" + l + op + r + ")", append(largs, rargs...), nil
+}
+
+// matchFilter reports whether n matches expr as the SQL of filterSQL
+// would.
+func matchFilter(expr filter.Expr, n *memoryNote) (bool, error) {
+	switch e := expr.(type) {
+	case filter.And:
+		matched, err := matchFilter(e.Left, n)
+		if err != nil || !matched {
+			return false, err
+		}
+		return matchFilter(e.Right, n)
+	case filter.Or:
+		matched, err := matchFilter(e.Left, n)
+		if err != nil || matched {
+			return matched, err
+		}
+		return matchFilter(e.Right, n)
+	case filter.Not:
+		matched, err := matchFilter(e.Expr, n)
+		return !matched, err
+	case filter.Text:
+		return strings.Contains(foldASCII(n.value), foldASCII(e.Value)), nil
+	case filter.Tag:
+		return hasTagUnder(n, []string{e.Name}), nil
+	case filter.Date:
+		t := n.createdAt
+		switch e.Field {
+		case filter.Created:
+		case filter.Updated:
+			t = n.updatedAt
+		default:
+			return false, fmt.Errorf("unknown date field %q", e.Field)
+		}
+		return (e.Since.IsZero() || !t.Before(storedTime(e.Since))) &&
+			(e.Until.IsZero() || t.Before(storedTime(e.Until))), nil
+	default:
+		return false, fmt.Errorf("unknown query node %T", expr)
+	}
+}
@@ -0,0 +1,215 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/iamunni/hugnin/model"
+)
+
+// Full-text search uses an FTS5 table, notes_fts, whose rowid is the note
+// Id. Triggers on notes and note_tags keep it in sync. go-sqlite3 only
+// compiles FTS5 in with the sqlite_fts5 build tag, so every entry point
+// checks for it and Search falls back to a LIKE scan without it. A binary
+// without FTS5 drops the triggers of a database indexed by one that has
+// it, since they would fail every write; the index is then stale and
+// unused until `hugnin db reindex` rebuilds it and restores them.
+
+const (
+	snippetOpen  = "["
+	snippetClose = "]"
+)
+
+// Reindexer is implemented by stores that keep a search index which can be
+// rebuilt from the notes.
+type Reindexer interface {
+	RebuildSearchIndex(ctx context.Context) error
+}
+
+var errFullTextUnavailable = errors.New("full-text search needs a hugnin binary built with -tags sqlite_fts5")
+
+// fullTextTriggers are the triggers created by fullTextSchema.
+var fullTextTriggers = []string{"notes_fts_insert", "notes_fts_update", "notes_fts_delete", "notes_fts_tag_insert", "notes_fts_tag_delete"}
+
+var fullTextSchema = []string{
+	`CREATE VIRTUAL TABLE IF NOT EXISTS notes_fts USING fts5(note, tags)`,
+	`CREATE TRIGGER IF NOT EXISTS notes_fts_insert AFTER INSERT ON notes BEGIN
+		INSERT INTO notes_fts (rowid, note, tags) VALUES (new.id, new.note, '');
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS notes_fts_update AFTER UPDATE OF note ON notes BEGIN
+		UPDATE notes_fts SET note = new.note WHERE rowid = new.id;
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS notes_fts_delete AFTER DELETE ON notes BEGIN
+		DELETE FROM notes_fts WHERE rowid = old.id;
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS notes_fts_tag_insert AFTER INSERT ON note_tags BEGIN
+		UPDATE notes_fts SET tags = ` + noteTagWordsSQL("new.note_id") + ` WHERE rowid = new.note_id;
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS notes_fts_tag_delete AFTER DELETE ON note_tags BEGIN
+		UPDATE notes_fts SET tags = ` + noteTagWordsSQL("old.note_id") + ` WHERE rowid = old.note_id;
+	END`,
+}
+
+// noteTagsSQL is a correlated subquery for the tags of the note aliased n,
+// oldest tag first, as a JSON array so that tags may contain commas.
+const noteTagsSQL = `(SELECT json_group_array(name) FROM (SELECT t.name FROM note_tags nt JOIN tags t ON t.id = nt.tag_id WHERE nt.note_id = n.id ORDER BY t.id))`
+
+// noteTagWordsSQL returns the space separated tags of the note whose Id is
+// the SQL expression noteId.
+func noteTagWordsSQL(noteId string) string {
+	return `(SELECT IFNULL(GROUP_CONCAT(t.name, ' '), '') FROM note_tags nt JOIN tags t ON t.id = nt.tag_id WHERE nt.note_id = ` + noteId + `)`
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func fullTextCompiled(ctx context.Context, q queryRower) (bool, error) {
+	var compiled bool
+	err := q.QueryRowContext(ctx, "SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&compiled)
+	return compiled, err
+}
+
+// fullTextReady reports whether this binary supports FTS5 and the database
+// has the notes_fts table, kept up to date by its triggers.
+func fullTextReady(ctx context.Context, q queryRower) (bool, error) {
+	var ready bool
+	err := q.QueryRowContext(ctx, `SELECT sqlite_compileoption_used('ENABLE_FTS5')
+		AND EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'notes_fts')
+		AND EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'trigger' AND name = 'notes_fts_insert')`).Scan(&ready)
+	return ready, err
+}
+
+// checkFullTextIndex drops the notes_fts triggers when this binary lacks
+// FTS5, leaving the index stale, and logs a hint when a binary that has
+// FTS5 finds a stale index.
+func checkFullTextIndex(ctx context.Context, db *sql.DB) error {
+	var compiled, indexed, triggered bool
+	err := db.QueryRowContext(ctx, `SELECT sqlite_compileoption_used('ENABLE_FTS5'),
+		EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'notes_fts'),
+		EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'trigger' AND name = 'notes_fts_insert')`).Scan(&compiled, &indexed, &triggered)
+	if err != nil {
+		return err
+	}
+	if !compiled {
+		for _, name := range fullTextTriggers {
+			_, err = db.ExecContext(ctx, "DROP TRIGGER IF EXISTS "+name)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if indexed && !triggered {
+		log.Println("The full-text index is out of date, search falls back to substring matching until `hugnin db reindex` rebuilds it")
+	}
+	return nil
+}
+
+// createFullTextIndex is the schema migration that adds notes_fts. Without
+// FTS5 it leaves the schema alone; `hugnin db reindex` from a binary that
+// has it creates the index later.
+func createFullTextIndex(ctx context.Context, tx *sql.Tx) error {
+	compiled, err := fullTextCompiled(ctx, tx)
+	if err != nil {
+		return err
+	}
+	if !compiled {
+		log.Println("FTS5 is not available, search falls back to substring matching")
+		return nil
+	}
+	return rebuildFullTextIndex(ctx, tx)
+}
+
+// rebuildFullTextIndex creates notes_fts and its triggers when missing and
+// refills it from notes and tags.
+func rebuildFullTextIndex(ctx context.Context, tx *sql.Tx) error {
+	for _, stmt := range fullTextSchema {
+		_, err := tx.ExecContext(ctx, stmt)
+		if err != nil {
+			return err
+		}
+	}
+	statements := []string{
+		`DELETE FROM notes_fts`,
+		`INSERT INTO notes_fts (rowid, note, tags)
+			SELECT n.id, n.note, ` + noteTagWordsSQL("n.id") + ` FROM notes n`,
+	}
+	for _, stmt := range statements {
+		_, err := tx.ExecContext(ctx, stmt)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildFullTextQuery returns the FTS5 statement used by Search. match is
+// an FTS5 query, so prefixes (kube*), phrases ("on call") and AND, OR and
+// NOT work. Results come best match first by BM25 and carry a highlighted
+// snippet of the note.
+func buildFullTextQuery(match string, period model.DateRange) (string, []interface{}) {
+	q := newQuery(`SELECT n.id, n.note, ` + noteTagsSQL + `, n.created_at, n.updated_at, n.deleted_at, n.uuid,
+		snippet(notes_fts, 0, '` + snippetOpen + `', '` + snippetClose + `', '…', 12)
+		FROM notes_fts JOIN notes n ON n.id = notes_fts.rowid`)
+	q.where("notes_fts MATCH ?", match)
+	q.live()
+	q.within(period)
+	return q.suffix("ORDER BY notes_fts.rank, n.id").build()
+}
+
+// quoteFullTextQuery turns free text into an FTS5 query that matches every
+// word literally, for input that is not valid FTS5 syntax.
+func quoteFullTextQuery(keyword string) string {
+	var terms []string
+	for _, word := range strings.Fields(keyword) {
+		terms = append(terms, `"`+strings.ReplaceAll(word, `"`, `""`)+`"`)
+	}
+	return strings.Join(terms, " ")
+}
+
+// isFullTextSyntaxError reports whether err is FTS5 rejecting the MATCH
+// expression itself, e.g. for unbalanced quotes or an unknown column filter.
+func isFullTextSyntaxError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "fts5: syntax error") ||
+		strings.Contains(msg, "unterminated string") ||
+		strings.Contains(msg, "no such column")
+}
+
+// substringSnippet highlights the first case insensitive match of keyword
+// in value, keeping some context around it. It is the fallback for
+// databases without notes_fts.
+func substringSnippet(value, keyword string) string {
+	if keyword == "" {
+		return ""
+	}
+	i := strings.Index(strings.ToLower(value), strings.ToLower(keyword))
+	if i < 0 || len(strings.ToLower(value)) != len(value) {
+		return ""
+	}
+	const context = 30
+	start, end := i, i+len(keyword)
+	from, prefix := start-context, "…"
+	if from <= 0 {
+		from, prefix = 0, ""
+	}
+	for from > 0 && !utf8.RuneStart(value[from]) {
+		from--
+	}
+	to, suffix := end+context, "…"
+	if to >= len(value) {
+		to, suffix = len(value), ""
+	}
+	for to < len(value) && !utf8.RuneStart(value[to]) {
+		to++
+	}
+	return prefix + value[from:start] + snippetOpen + value[start:end] + snippetClose + value[end:to] + suffix
+}
@@ -0,0 +1,168 @@
+//go:build sqlite_fts5
+
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/iamunni/hugnin/model"
+)
+
+// Run with: go test -tags sqlite_fts5 ./store
+
+func newFullTextStore(t *testing.T) *SQLiteStore {
+	db := openTestDB(t)
+	if _, err := migrate(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+	seed := []struct {
+		value string
+		tags  []string
+	}{
+		{"Restart postgres after the kubernetes upgrade", []string{"oncall"}},
+		{"Postgres vacuum notes, postgres tuning and postgres indexes", []string{"db"}},
+		{"Lunch order for friday", nil},
+		{"kubectl cheat sheet", []string{"kubernetes"}},
+	}
+	for _, n := range seed {
+		if _, err := insertNote(context.Background(), db, n.value, n.tags); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &SQLiteStore{dbConn: db}
+}
+
+func searchIds(t *testing.T, s *SQLiteStore, keyword string) []int64 {
+	t.Helper()
+	stmt, args := buildFullTextQuery(keyword, model.DateRange{})
+	notes, err := queryNotes(context.Background(), s.dbConn, stmt, args...)
+	if isFullTextSyntaxError(err) {
+		stmt, args = buildFullTextQuery(quoteFullTextQuery(keyword), model.DateRange{})
+		notes, err = queryNotes(context.Background(), s.dbConn, stmt, args...)
+	}
+	if err != nil {
+		t.Fatalf("search %q: %v", keyword, err)
+	}
+	var ids []int64
+	for _, n := range notes {
+		ids = append(ids, n.Id)
+	}
+	return ids
+}
+
+func TestFullText_Queries(t *testing.T) {
+	s := newFullTextStore(t)
+	tests := []struct {
+		keyword string
+		want    []int64
+	}{
+		{keyword: "POSTGRES", want: []int64{2, 1}},
+		{keyword: "kube*", want: []int64{4, 1}},
+		{keyword: "unknown:column", want: nil},
+		{keyword: `"postgres after"`, want: []int64{1}},
+		{keyword: "postgres NOT upgrade", want: []int64{2}},
+		{keyword: "lunch OR kubectl", want: []int64{3, 4}},
+		{keyword: "oncall", want: []int64{1}},
+		{keyword: `friday"`, want: []int64{3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.keyword, func(t *testing.T) {
+			got := searchIds(t, s, tt.keyword)
+			if len(got) != len(tt.want) {
+				t.Fatalf("search %q = %v, want %v", tt.keyword, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("search %q = %v, want %v", tt.keyword, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestFullText_Snippet(t *testing.T) {
+	s := newFullTextStore(t)
+	stmt, args := buildFullTextQuery("lunch", model.DateRange{})
+	notes, err := queryNotes(context.Background(), s.dbConn, stmt, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != 1 || notes[0].Snippet != "[Lunch] order for friday" {
+		t.Errorf("snippet = %v, want [Lunch] order for friday", notes)
+	}
+}
+
+func TestFullText_TriggersKeepIndexInSync(t *testing.T) {
+	s := newFullTextStore(t)
+
+	if err := updateNote(context.Background(), s.dbConn, model.Note{Id: 3, Value: "Dinner order", Tags: []string{"food"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := searchIds(t, s, "lunch"); len(got) != 0 {
+		t.Errorf("search lunch after update = %v, want none", got)
+	}
+	if got := searchIds(t, s, "food"); len(got) != 1 || got[0] != 3 {
+		t.Errorf("search food after retag = %v, want [3]", got)
+	}
+
+	if _, err := s.dbConn.Exec("DELETE FROM notes WHERE id = 4"); err != nil {
+		t.Fatal(err)
+	}
+	if got := searchIds(t, s, "kubectl"); len(got) != 0 {
+		t.Errorf("search kubectl after delete = %v, want none", got)
+	}
+}
+
+func TestFullText_MergedTagsAreIndexed(t *testing.T) {
+	s := newFullTextStore(t)
+	if _, err := s.MergeTags(context.Background(), []string{"kubernetes"}, "k8s"); err != nil {
+		t.Fatal(err)
+	}
+	if got := searchIds(t, s, "k8s"); len(got) != 1 || got[0] != 4 {
+		t.Errorf("search k8s after merge = %v, want [4]", got)
+	}
+	if got := searchIds(t, s, "kubernetes"); len(got) != 1 || got[0] != 1 {
+		t.Errorf("search kubernetes after merge = %v, want [1]", got)
+	}
+}
+
+func TestFullText_Rebuild(t *testing.T) {
+	s := newFullTextStore(t)
+	if _, err := s.dbConn.Exec("DELETE FROM notes_fts"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RebuildSearchIndex(context.Background()); err != nil {
+		t.Fatalf("RebuildSearchIndex() error = %v", err)
+	}
+}
+
+func TestFullText_StaleIndex(t *testing.T) {
+	ctx := context.Background()
+	s := newFullTextStore(t)
+	// A binary without FTS5 drops the triggers and goes on writing notes.
+	for _, name := range fullTextTriggers {
+		if _, err := s.dbConn.Exec("DROP TRIGGER " + name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := insertNote(ctx, s.dbConn, "zebra crossing", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if ready, err := fullTextReady(ctx, s.dbConn); err != nil || ready {
+		t.Errorf("fullTextReady() with a stale index = %v, %v, want false", ready, err)
+	}
+	if found, err := s.Search(ctx, "zebra", model.DateRange{}); err != nil || len(found) != 1 {
+		t.Errorf("Search() with a stale index = %v, %v, want the new note", found, err)
+	}
+	if err := s.RebuildSearchIndex(ctx); err != nil {
+		t.Fatalf("RebuildSearchIndex() error = %v", err)
+	}
+	if ready, err := fullTextReady(ctx, s.dbConn); err != nil || !ready {
+		t.Errorf("fullTextReady() after RebuildSearchIndex() = %v, %v, want true", ready, err)
+	}
+	if got := searchIds(t, s, "zebra"); len(got) != 1 || got[0] != 5 {
+		t.Errorf("search zebra after rebuild = %v, want [5]", got)
+	}
+}
@@ -0,0 +1,75 @@
+//go:build !sqlite_fts5
+
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/iamunni/hugnin/model"
+)
+
+// newFullTextDB creates a database at path as a binary with FTS5 would
+// leave it. Without FTS5 the notes_fts table cannot be created, so its
+// schema entry is written directly.
+func newFullTextDB(t *testing.T, path string) {
+	t.Helper()
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := migrate(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`PRAGMA writable_schema = ON;
+		INSERT INTO sqlite_master (type, name, tbl_name, rootpage, sql)
+			VALUES ('table', 'notes_fts', 'notes_fts', 0, 'CREATE VIRTUAL TABLE notes_fts USING fts5(note, tags)');
+		PRAGMA writable_schema = OFF;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range fullTextSchema[1:] {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSQLiteStore_FullTextDatabaseWithoutFTS5(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "notes.db")
+	newFullTextDB(t, path)
+
+	s := NewSQLiteStore(path)
+	if err := s.Open(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	id, err := s.Write(ctx, "restart postgres", []string{"db"})
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := s.Update(ctx, model.Note{Id: id, Value: "restart postgres 16", Tags: []string{"db", "oncall"}}); err != nil {
+		t.Errorf("Update() error = %v", err)
+	}
+	if _, err := s.MergeTags(ctx, []string{"db"}, "postgres"); err != nil {
+		t.Errorf("MergeTags() error = %v", err)
+	}
+	if found, err := s.Search(ctx, "postgres", model.DateRange{}); err != nil || len(found) != 1 {
+		t.Errorf("Search() = %v, %v, want the note", found, err)
+	}
+	if _, err := s.Delete(ctx, model.Note{Id: id}); err != nil {
+		t.Errorf("Delete() error = %v", err)
+	}
+	if _, err := s.Purge(ctx, time.Time{}); err != nil {
+		t.Errorf("Purge() error = %v", err)
+	}
+	if err := s.(Reindexer).RebuildSearchIndex(ctx); !errors.Is(err, errFullTextUnavailable) {
+		t.Errorf("RebuildSearchIndex() error = %v, want errFullTextUnavailable", err)
+	}
+}
@@ -0,0 +1,33 @@
+package store
+
+import "testing"
+
+func Test_substringSnippet(t *testing.T) {
+	long := "the quick brown fox jumps over the lazy dog and keeps running far away"
+	tests := []struct {
+		name    string
+		value   string
+		keyword string
+		want    string
+	}{
+		{name: "case insensitive", value: "Restart Postgres", keyword: "postgres", want: "Restart [Postgres]"},
+		{name: "context is trimmed", value: long, keyword: "lazy", want: "…uick brown fox jumps over the [lazy] dog and keeps running far awa…"},
+		{name: "no match", value: "hello", keyword: "bye", want: ""},
+		{name: "empty keyword", value: "hello", keyword: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := substringSnippet(tt.value, tt.keyword); got != tt.want {
+				t.Errorf("substringSnippet() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_quoteFullTextQuery(t *testing.T) {
+	got := quoteFullTextQuery(`it's "100%"  done`)
+	want := `"it's" """100%""" "done"`
+	if got != want {
+		t.Errorf("quoteFullTextQuery() = %q, want %q", got, want)
+	}
+}
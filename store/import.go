@@ -0,0 +1,87 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"github.com/iamunni/hugnin/model"
+)
+
+// ImportMode selects how Import treats the notes already in a store.
+type ImportMode int
+
+const (
+	// ImportMerge adds the imported notes whose content hash matches no
+	// stored note, under new Ids.
+	ImportMerge ImportMode = iota
+	// ImportReplace deletes every stored note, trash and history included,
+	// and keeps the imported Ids.
+	ImportReplace
+)
+
+// ContentHash identifies a note by its text and its set of tags, so the
+// same note exported from two databases hashes the same whatever its Id
+// or timestamps.
+func ContentHash(note model.Note) string {
+	tags := append([]string(nil), note.Tags...)
+	sort.Strings(tags)
+	h := sha256.New()
+	for _, field := range append([]string{note.Value}, tags...) {
+		fmt.Fprintf(h, "%d:%s", len(field), field)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// importNote inserts note as is. A zero Id lets SQLite pick one, zero
+// timestamps default to now and an empty UUID gets a new one.
+func importNote(ctx context.Context, tx *sql.Tx, note model.Note) error {
+	var id interface{}
+	if note.Id > 0 {
+		id = note.Id
+	}
+	stamp := formatTime(now())
+	createdAt, updatedAt := stamp, stamp
+	if !note.CreatedAt.IsZero() {
+		createdAt = formatTime(note.CreatedAt)
+	}
+	if !note.UpdatedAt.IsZero() {
+		updatedAt = formatTime(note.UpdatedAt)
+	}
+	uuid := note.UUID
+	if uuid == "" {
+		uuid = newUUID()
+	}
+	res, err := tx.ExecContext(ctx, "INSERT INTO notes (id, note, uuid, created_at, updated_at, deleted_at) VALUES (?, ?, ?, ?, ?, ?)",
+		id, note.Value, uuid, createdAt, updatedAt, formatStoredTime(note.DeletedAt))
+	if err != nil {
+		return err
+	}
+	noteId, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	return linkTags(ctx, tx, noteId, note.Tags)
+}
+
+// contentHashes returns the ContentHash of every stored note, trashed ones
+// included.
+func contentHashes(ctx context.Context, tx *sql.Tx) (map[string]bool, error) {
+	rows, err := tx.QueryContext(ctx, selectNotesSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	notes, err := scanNotes(rows)
+	if err != nil {
+		return nil, err
+	}
+	hashes := make(map[string]bool, len(notes))
+	for _, note := range notes {
+		hashes[ContentHash(note)] = true
+	}
+	return hashes, nil
+}
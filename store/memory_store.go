@@ -0,0 +1,795 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iamunni/hugnin/filter"
+	"github.com/iamunni/hugnin/model"
+)
+
+// MemoryStore keeps notes in memory with the same semantics as
+// SQLiteStore, for tests and sessions that need no database file. Its
+// notes are gone once it is closed. It is safe for concurrent use.
+type MemoryStore struct {
+	mu    sync.Mutex
+	open  bool
+	state memoryState
+}
+
+// memoryState is everything a MemoryStore holds. Calls that must be
+// atomic work on a copy and swap it in when they succeed.
+type memoryState struct {
+	notes      map[int64]*memoryNote
+	tags       map[string]int64 // tag name to tag Id, which orders the tags of a note
+	revisions  map[int64][]model.Revision
+	tombstones map[string]time.Time // UUID to the time the note was trashed
+	searches   map[string]string    // saved search name to query
+	lastId     int64
+	lastTagId  int64
+}
+
+type memoryNote struct {
+	uuid      string
+	value     string
+	tags      []string
+	createdAt time.Time
+	updatedAt time.Time
+	deletedAt time.Time
+}
+
+// NewMemoryStore returns an empty MemoryStore. Call Open before using it.
+func NewMemoryStore() Store {
+	return &MemoryStore{}
+}
+
+// Open makes the store ready for use with no notes.
+func (s *MemoryStore) Open(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.open {
+		s.state = newMemoryState()
+		s.open = true
+	}
+	return nil
+}
+
+// Close discards every note. Closing a closed store is a no-op.
+func (s *MemoryStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.open = false
+	s.state = memoryState{}
+	return nil
+}
+
+// Init does nothing; a MemoryStore has no file to create.
+func (s *MemoryStore) Init(ctx context.Context, dbFile string) error {
+	return s.locked(func() error { return nil })
+}
+
+func (s *MemoryStore) Write(ctx context.Context, value string, tags []string) (int64, error) {
+	var id int64
+	err := s.locked(func() error {
+		stamp := storedTime(now())
+		id = s.state.insert(0, &memoryNote{uuid: newUUID(), value: value, createdAt: stamp, updatedAt: stamp}, tags)
+		return nil
+	})
+	return id, err
+}
+
+func (s *MemoryStore) Read(ctx context.Context, note model.Note, period model.DateRange) ([]model.Note, error) {
+	var result []model.Note
+	err := s.locked(func() error {
+		result = s.state.find(func(id int64, n *memoryNote) bool {
+			return n.deletedAt.IsZero() && inPeriod(n, period) &&
+				(note.Id <= 0 || id == note.Id) &&
+				(note.Value == "" || likeMatch(note.Value, n.value)) &&
+				(len(note.Tags) == 0 || hasTagUnder(n, note.Tags))
+		})
+		return nil
+	})
+	return result, err
+}
+
+// Update replaces the text and tags of the note with note.Id, keeping the
+// previous version as a revision.
+func (s *MemoryStore) Update(ctx context.Context, note model.Note) error {
+	return s.locked(func() error {
+		n, ok := s.state.notes[note.Id]
+		if !ok || !n.deletedAt.IsZero() {
+			return fmt.Errorf("note %d: %w", note.Id, ErrNotFound)
+		}
+		s.state.recordRevision(note.Id, n)
+		n.value = note.Value
+		n.updatedAt = storedTime(now())
+		n.tags = nil
+		s.state.link(n, note.Tags)
+		return nil
+	})
+}
+
+// Revisions returns the earlier versions of the note with the given Id,
+// oldest first.
+func (s *MemoryStore) Revisions(ctx context.Context, id int64) ([]model.Revision, error) {
+	var result []model.Revision
+	err := s.locked(func() error {
+		for _, r := range s.state.revisions[id] {
+			r.Tags = append([]string(nil), r.Tags...)
+			result = append(result, r)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// Delete moves the notes selected by note to the trash and returns how many
+// were moved, selecting them as SQLiteStore does.
+func (s *MemoryStore) Delete(ctx context.Context, note model.Note) (int64, error) {
+	var deleted int64
+	err := s.locked(func() error {
+		if note.Id == 0 && len(note.Tags) == 0 {
+			return nil
+		}
+		stamp := storedTime(now())
+		for id, n := range s.state.notes {
+			if !n.deletedAt.IsZero() {
+				continue
+			}
+			switch {
+			case note.Id == -1:
+			case note.Id != 0:
+				if id != note.Id {
+					continue
+				}
+			case !hasTagUnder(n, note.Tags):
+				continue
+			}
+			n.deletedAt = stamp
+			deleted++
+		}
+		return nil
+	})
+	return deleted, err
+}
+
+// Search matches keyword as a case insensitive substring of the note or of
+// any of its tags, like SQLiteStore without full-text search.
+func (s *MemoryStore) Search(ctx context.Context, keyword string, period model.DateRange) ([]model.Note, error) {
+	var result []model.Note
+	err := s.locked(func() error {
+		folded := foldASCII(keyword)
+		result = s.state.find(func(id int64, n *memoryNote) bool {
+			if !n.deletedAt.IsZero() || !inPeriod(n, period) {
+				return false
+			}
+			if strings.Contains(foldASCII(n.value), folded) {
+				return true
+			}
+			for _, tag := range n.tags {
+				if strings.Contains(foldASCII(tag), folded) {
+					return true
+				}
+			}
+			return false
+		})
+		for i := range result {
+			result[i].Snippet = substringSnippet(result[i].Value, keyword)
+		}
+		return nil
+	})
+	return result, err
+}
+
+func (s *MemoryStore) Find(ctx context.Context, expr filter.Expr, period model.DateRange) ([]model.Note, error) {
+	var result []model.Note
+	err := s.locked(func() error {
+		var err error
+		result = s.state.find(func(id int64, n *memoryNote) bool {
+			if err != nil || !n.deletedAt.IsZero() || !inPeriod(n, period) {
+				return false
+			}
+			if expr == nil {
+				return true
+			}
+			var matched bool
+			matched, err = matchFilter(expr, n)
+			return matched
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Tags returns every tag with the number of live notes carrying it and
+// their latest update time, ordered by name.
+func (s *MemoryStore) Tags(ctx context.Context) ([]model.Tag, error) {
+	var result []model.Tag
+	err := s.locked(func() error {
+		used := map[string]model.Tag{}
+		for _, n := range s.state.notes {
+			if !n.deletedAt.IsZero() {
+				continue
+			}
+			for _, name := range n.tags {
+				tag := used[name]
+				tag.Notes++
+				if n.updatedAt.After(tag.LastUsed) {
+					tag.LastUsed = n.updatedAt
+				}
+				used[name] = tag
+			}
+		}
+		for name := range s.state.tags {
+			tag := used[name]
+			tag.Name = name
+			result = append(result, tag)
+		}
+		sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+		return nil
+	})
+	return result, err
+}
+
+// MergeTags replaces the tags from with the tag into on every note
+// carrying one of them, keeping the replaced versions as revisions, and
+// removes the tags from. It returns how many notes changed.
+func (s *MemoryStore) MergeTags(ctx context.Context, from []string, into string) (int64, error) {
+	var merged int64
+	err := s.locked(func() error {
+		renames := map[string]string{}
+		for _, name := range from {
+			if name == into {
+				continue
+			}
+			if _, ok := s.state.tags[name]; !ok {
+				return fmt.Errorf("tag %q: %w", name, ErrNotFound)
+			}
+			renames[name] = into
+		}
+		s.state.addTag(into)
+		var err error
+		merged, err = s.state.renameTags(renames)
+		return err
+	})
+	return merged, err
+}
+
+// RenameTags gives each tag that is a key of renames the name it maps to,
+// keeping the replaced versions as revisions, and removes the old tags. It
+// returns how many notes changed.
+func (s *MemoryStore) RenameTags(ctx context.Context, renames map[string]string) (int64, error) {
+	var renamed int64
+	err := s.locked(func() error {
+		var err error
+		renamed, err = s.state.renameTags(renames)
+		return err
+	})
+	return renamed, err
+}
+
+// PruneTags removes the tags no note carries, trashed notes included, and
+// returns how many were removed.
+func (s *MemoryStore) PruneTags(ctx context.Context) (int64, error) {
+	var pruned int64
+	err := s.locked(func() error {
+		used := map[string]bool{}
+		for _, n := range s.state.notes {
+			for _, tag := range n.tags {
+				used[tag] = true
+			}
+		}
+		for name := range s.state.tags {
+			if !used[name] {
+				delete(s.state.tags, name)
+				pruned++
+			}
+		}
+		return nil
+	})
+	return pruned, err
+}
+
+func (s *MemoryStore) SaveSearch(ctx context.Context, search model.SavedSearch) error {
+	return s.locked(func() error {
+		s.state.searches[search.Name] = search.Query
+		return nil
+	})
+}
+
+// SavedSearches returns the saved searches ordered by name.
+func (s *MemoryStore) SavedSearches(ctx context.Context) ([]model.SavedSearch, error) {
+	var result []model.SavedSearch
+	err := s.locked(func() error {
+		for name, query := range s.state.searches {
+			result = append(result, model.SavedSearch{Name: name, Query: query})
+		}
+		sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+		return nil
+	})
+	return result, err
+}
+
+func (s *MemoryStore) DeleteSavedSearch(ctx context.Context, name string) error {
+	return s.locked(func() error {
+		if _, ok := s.state.searches[name]; !ok {
+			return fmt.Errorf("saved search %q: %w", name, ErrNotFound)
+		}
+		delete(s.state.searches, name)
+		return nil
+	})
+}
+
+// Trashed returns the notes in the trash, most recently deleted first.
+func (s *MemoryStore) Trashed(ctx context.Context) ([]model.Note, error) {
+	var result []model.Note
+	err := s.locked(func() error {
+		result = s.state.find(func(id int64, n *memoryNote) bool {
+			return !n.deletedAt.IsZero()
+		})
+		sort.SliceStable(result, func(i, j int) bool { return result[i].DeletedAt.After(result[j].DeletedAt) })
+		return nil
+	})
+	return result, err
+}
+
+// Restore moves the note with the given Id out of the trash and sets its
+// update time.
+func (s *MemoryStore) Restore(ctx context.Context, id int64) error {
+	return s.locked(func() error {
+		n, ok := s.state.notes[id]
+		if !ok || n.deletedAt.IsZero() {
+			return fmt.Errorf("note %d is not in the trash: %w", id, ErrNotFound)
+		}
+		n.deletedAt = time.Time{}
+		n.updatedAt = storedTime(now())
+		return nil
+	})
+}
+
+// Purge permanently removes the notes trashed before cutoff, or every
+// trashed note when cutoff is zero, leaving a tombstone for each, and
+// returns how many were removed.
+func (s *MemoryStore) Purge(ctx context.Context, cutoff time.Time) (int64, error) {
+	var purged int64
+	err := s.locked(func() error {
+		for id, n := range s.state.notes {
+			if n.deletedAt.IsZero() || (!cutoff.IsZero() && !n.deletedAt.Before(storedTime(cutoff))) {
+				continue
+			}
+			s.state.remove(id)
+			s.state.tombstones[n.uuid] = n.deletedAt
+			purged++
+		}
+		return nil
+	})
+	return purged, err
+}
+
+// Import writes notes with their tags, timestamps and trash state and
+// returns how many were added. Nothing is written when any note fails.
+func (s *MemoryStore) Import(ctx context.Context, notes []model.Note, mode ImportMode) (int64, error) {
+	var imported int64
+	err := s.locked(func() error {
+		state := s.state.clone()
+		known, uuids := map[string]bool{}, map[string]bool{}
+		if mode == ImportReplace {
+			searches := state.searches
+			state = newMemoryState()
+			state.lastId, state.lastTagId = s.state.lastId, s.state.lastTagId
+			state.searches = searches
+			notes = append([]model.Note(nil), notes...)
+			sort.SliceStable(notes, func(i, j int) bool { return notes[i].Id > 0 && notes[j].Id <= 0 })
+		} else {
+			for _, note := range state.find(func(int64, *memoryNote) bool { return true }) {
+				known[ContentHash(note)] = true
+				uuids[note.UUID] = true
+			}
+		}
+
+		stamp := storedTime(now())
+		for _, note := range notes {
+			if mode == ImportMerge {
+				hash := ContentHash(note)
+				if known[hash] {
+					continue
+				}
+				known[hash] = true
+				note.Id = 0
+			}
+			if _, ok := state.notes[note.Id]; ok && note.Id > 0 {
+				return fmt.Errorf("note %d already exists", note.Id)
+			}
+			if note.UUID == "" || uuids[note.UUID] {
+				note.UUID = newUUID()
+			}
+			uuids[note.UUID] = true
+			state.insert(note.Id, newMemoryNote(note, stamp), note.Tags)
+			imported++
+		}
+		s.state = state
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return imported, nil
+}
+
+// Rewrite passes the text of every note and revision through rewrite,
+// leaving timestamps and revisions alone. Nothing is written when rewrite
+// fails.
+func (s *MemoryStore) Rewrite(ctx context.Context, rewrite func(text string) (string, error)) (int64, error) {
+	var rewritten int64
+	err := s.locked(func() error {
+		state := s.state.clone()
+		texts := []*string{}
+		for _, n := range state.notes {
+			texts = append(texts, &n.value)
+		}
+		for _, revisions := range state.revisions {
+			for i := range revisions {
+				texts = append(texts, &revisions[i].Value)
+			}
+		}
+		for _, text := range texts {
+			result, err := rewrite(*text)
+			if err != nil {
+				return err
+			}
+			if result != *text {
+				*text = result
+				rewritten++
+			}
+		}
+		s.state = state
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return rewritten, nil
+}
+
+// Put stores notes by UUID, keeping their timestamps and trash state. A
+// stored note with the same UUID is replaced, its previous version
+// becoming a revision when the text or tags change. Storing a note drops
+// the tombstone of its UUID.
+func (s *MemoryStore) Put(ctx context.Context, notes []model.Note) error {
+	return s.locked(func() error {
+		stamp := storedTime(now())
+		ids := s.state.uuidIds()
+		for _, note := range notes {
+			delete(s.state.tombstones, note.UUID)
+			id, ok := ids[note.UUID]
+			if !ok {
+				ids[note.UUID] = s.state.insert(0, newMemoryNote(note, stamp), note.Tags)
+				continue
+			}
+			if old := s.state.notes[id]; ContentHash(s.state.note(id, old)) != ContentHash(note) {
+				s.state.recordRevision(id, old)
+			}
+			n := newMemoryNote(note, stamp)
+			s.state.notes[id] = n
+			s.state.link(n, note.Tags)
+		}
+		return nil
+	})
+}
+
+// Forget permanently removes the notes with the UUIDs of tombstones and
+// keeps the tombstones. It returns how many notes were removed.
+func (s *MemoryStore) Forget(ctx context.Context, tombstones []model.Tombstone) (int64, error) {
+	var forgotten int64
+	err := s.locked(func() error {
+		ids := s.state.uuidIds()
+		for _, tombstone := range tombstones {
+			if id, ok := ids[tombstone.UUID]; ok {
+				delete(ids, tombstone.UUID)
+				s.state.remove(id)
+				forgotten++
+			}
+			deletedAt := storedTime(tombstone.DeletedAt)
+			if known, ok := s.state.tombstones[tombstone.UUID]; !ok || deletedAt.After(known) {
+				s.state.tombstones[tombstone.UUID] = deletedAt
+			}
+		}
+		return nil
+	})
+	return forgotten, err
+}
+
+// Tombstones returns the tombstones of the removed notes, ordered by UUID.
+func (s *MemoryStore) Tombstones(ctx context.Context) ([]model.Tombstone, error) {
+	var result []model.Tombstone
+	err := s.locked(func() error {
+		for uuid, deletedAt := range s.state.tombstones {
+			result = append(result, model.Tombstone{UUID: uuid, DeletedAt: deletedAt})
+		}
+		sort.Slice(result, func(i, j int) bool { return result[i].UUID < result[j].UUID })
+		return nil
+	})
+	return result, err
+}
+
+// locked runs fn while holding the lock of an open store.
+func (s *MemoryStore) locked(fn func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.open {
+		return errStoreClosed
+	}
+	return fn()
+}
+
+func newMemoryState() memoryState {
+	return memoryState{
+		notes:      map[int64]*memoryNote{},
+		tags:       map[string]int64{},
+		revisions:  map[int64][]model.Revision{},
+		tombstones: map[string]time.Time{},
+		searches:   map[string]string{},
+	}
+}
+
+// newMemoryNote returns note as stored, with zero creation and update
+// times set to stamp.
+func newMemoryNote(note model.Note, stamp time.Time) *memoryNote {
+	n := &memoryNote{uuid: note.UUID, value: note.Value, createdAt: stamp, updatedAt: stamp, deletedAt: storedTime(note.DeletedAt)}
+	if !note.CreatedAt.IsZero() {
+		n.createdAt = storedTime(note.CreatedAt)
+	}
+	if !note.UpdatedAt.IsZero() {
+		n.updatedAt = storedTime(note.UpdatedAt)
+	}
+	return n
+}
+
+// clone returns a deep copy of the state.
+func (st memoryState) clone() memoryState {
+	c := newMemoryState()
+	c.lastId, c.lastTagId = st.lastId, st.lastTagId
+	for id, n := range st.notes {
+		copied := *n
+		copied.tags = append([]string(nil), n.tags...)
+		c.notes[id] = &copied
+	}
+	for name, id := range st.tags {
+		c.tags[name] = id
+	}
+	for id, revisions := range st.revisions {
+		c.revisions[id] = append([]model.Revision(nil), revisions...)
+	}
+	for uuid, deletedAt := range st.tombstones {
+		c.tombstones[uuid] = deletedAt
+	}
+	for name, query := range st.searches {
+		c.searches[name] = query
+	}
+	return c
+}
+
+// insert adds n with its tags under id, or under the next free Id when id
+// is zero. Like SQLite AUTOINCREMENT, Ids are never reused.
+func (st *memoryState) insert(id int64, n *memoryNote, tags []string) int64 {
+	if id <= 0 {
+		id = st.lastId + 1
+	}
+	if id > st.lastId {
+		st.lastId = id
+	}
+	st.notes[id] = n
+	st.link(n, tags)
+	return id
+}
+
+// link attaches tags to n, creating the tags that do not exist yet. The
+// tags of a note are kept in the order the tags were created.
+func (st *memoryState) link(n *memoryNote, tags []string) {
+	for _, tag := range tags {
+		st.addTag(tag)
+		if !hasAnyTag(n, []string{tag}) {
+			n.tags = append(n.tags, tag)
+		}
+	}
+	sort.SliceStable(n.tags, func(i, j int) bool { return st.tags[n.tags[i]] < st.tags[n.tags[j]] })
+}
+
+// renameTags renames tags as RenameTags does, creating the new tags in the
+// order of the old names as SQLiteStore does.
+func (st *memoryState) renameTags(renames map[string]string) (int64, error) {
+	names := make([]string, 0, len(renames))
+	for name := range renames {
+		if _, ok := st.tags[name]; !ok {
+			return 0, fmt.Errorf("tag %q: %w", name, ErrNotFound)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		st.addTag(renames[name])
+	}
+
+	var renamed int64
+	stamp := storedTime(now())
+	for id, n := range st.notes {
+		if !hasAnyTag(n, names) {
+			continue
+		}
+		st.recordRevision(id, n)
+		tags := make([]string, 0, len(n.tags))
+		for _, tag := range n.tags {
+			if into, ok := renames[tag]; ok {
+				tag = into
+			}
+			tags = append(tags, tag)
+		}
+		n.tags = nil
+		st.link(n, tags)
+		n.updatedAt = stamp
+		renamed++
+	}
+	for _, name := range names {
+		delete(st.tags, name)
+	}
+	return renamed, nil
+}
+
+// addTag creates tag unless it exists.
+func (st *memoryState) addTag(tag string) {
+	if _, ok := st.tags[tag]; !ok {
+		st.lastTagId++
+		st.tags[tag] = st.lastTagId
+	}
+}
+
+// remove drops the note with the given Id and its revisions.
+func (st *memoryState) remove(id int64) {
+	delete(st.notes, id)
+	delete(st.revisions, id)
+}
+
+// recordRevision appends n, the note with the given Id, to its revisions.
+func (st *memoryState) recordRevision(id int64, n *memoryNote) {
+	revisions := st.revisions[id]
+	st.revisions[id] = append(revisions, model.Revision{
+		NoteId:    id,
+		Number:    len(revisions) + 1,
+		Value:     n.value,
+		Tags:      append([]string(nil), n.tags...),
+		CreatedAt: n.updatedAt,
+	})
+}
+
+// fillUUIDs gives a new UUID to the notes that have none.
+func (st *memoryState) fillUUIDs() {
+	for _, n := range st.notes {
+		if n.uuid == "" {
+			n.uuid = newUUID()
+		}
+	}
+}
+
+// uuidIds maps the UUID of every note to its Id.
+func (st *memoryState) uuidIds() map[string]int64 {
+	ids := make(map[string]int64, len(st.notes))
+	for id, n := range st.notes {
+		ids[n.uuid] = id
+	}
+	return ids
+}
+
+// find returns the notes matching keep, ordered by Id.
+func (st *memoryState) find(keep func(id int64, n *memoryNote) bool) []model.Note {
+	var result []model.Note
+	for id, n := range st.notes {
+		if keep(id, n) {
+			result = append(result, st.note(id, n))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Id < result[j].Id })
+	return result
+}
+
+// note returns n, stored under id, as a model.Note.
+func (st *memoryState) note(id int64, n *memoryNote) model.Note {
+	return model.Note{
+		Id:        id,
+		UUID:      n.uuid,
+		Value:     n.value,
+		Tags:      append([]string(nil), n.tags...),
+		CreatedAt: n.createdAt,
+		UpdatedAt: n.updatedAt,
+		DeletedAt: n.deletedAt,
+	}
+}
+
+// storedTime returns t as SQLiteStore stores it: in UTC, to the
+// millisecond.
+func storedTime(t time.Time) time.Time {
+	if t.IsZero() {
+		return time.Time{}
+	}
+	return t.UTC().Truncate(time.Millisecond)
+}
+
+func inPeriod(n *memoryNote, period model.DateRange) bool {
+	return (period.Since.IsZero() || !n.createdAt.Before(storedTime(period.Since))) &&
+		(period.Until.IsZero() || n.createdAt.Before(storedTime(period.Until)))
+}
+
+func hasAnyTag(n *memoryNote, tags []string) bool {
+	for _, have := range n.tags {
+		if containsTag(tags, have) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasTagUnder reports whether n carries one of tags or a tag below one of
+// them, where "work/oncall" is below "work".
+func hasTagUnder(n *memoryNote, tags []string) bool {
+	for _, have := range n.tags {
+		for _, want := range tags {
+			if have == want || strings.HasPrefix(have, want+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containsTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// foldASCII lower cases the ASCII letters of s, the only ones SQLite LIKE
+// treats case insensitively.
+func foldASCII(s string) string {
+	return strings.Map(func(r rune) rune {
+		if 'A' <= r && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, s)
+}
+
+// likeMatch reports whether value matches the SQLite LIKE pattern, where
+// "%" matches any run of characters and "_" any single one. It backtracks
+// to the last "%" only, so it runs in linear time for most patterns.
+func likeMatch(pattern, value string) bool {
+	p, v := []rune(foldASCII(pattern)), []rune(foldASCII(value))
+	pi, vi, star, mark := 0, 0, -1, 0
+	for vi < len(v) {
+		switch {
+		case pi < len(p) && p[pi] == '%':
+			star, mark = pi, vi
+			pi++
+		case pi < len(p) && (p[pi] == '_' || p[pi] == v[vi]):
+			pi++
+			vi++
+		case star >= 0:
+			pi, mark = star+1, mark+1
+			vi = mark
+		default:
+			return false
+		}
+	}
+	for pi < len(p) && p[pi] == '%' {
+		pi++
+	}
+	return pi == len(p)
+}
@@ -0,0 +1,27 @@
+package store
+
+import "testing"
+
+func Test_likeMatch(t *testing.T) {
+	tests := []struct {
+		pattern, value string
+		want           bool
+	}{
+		{pattern: "", value: "", want: true},
+		{pattern: "", value: "a", want: false},
+		{pattern: "abc", value: "ABC", want: true},
+		{pattern: "é", value: "É", want: false},
+		{pattern: "%", value: "", want: true},
+		{pattern: "a%c", value: "abbbc", want: true},
+		{pattern: "a%c", value: "abbbd", want: false},
+		{pattern: "%b%b%", value: "abab", want: true},
+		{pattern: "_b", value: "éb", want: true},
+		{pattern: "a_", value: "a", want: false},
+		{pattern: "%a%a%a%a%b", value: "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", want: false},
+	}
+	for _, tt := range tests {
+		if got := likeMatch(tt.pattern, tt.value); got != tt.want {
+			t.Errorf("likeMatch(%q, %q) = %v, want %v", tt.pattern, tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// Migration is a single, ordered step of the SQLite schema. The version of
+// the last applied migration is kept in PRAGMA user_version, so a database
+// only ever runs the steps it has not seen yet.
+type Migration struct {
+	Version int
+	Name    string
+	Applied bool
+	up      func(ctx context.Context, tx *sql.Tx) error
+}
+
+// Migrator is implemented by stores whose schema is versioned.
+type Migrator interface {
+	Migrate(ctx context.Context) ([]Migration, error)
+	Migrations(ctx context.Context) ([]Migration, error)
+}
+
+// migrations must stay sorted by Version. Never edit a released migration;
+// append a new one instead.
+var migrations = []Migration{
+	{Version: 1, Name: "create notes, tags and note_tags tables", up: createNotesSchema},
+	{Version: 2, Name: "add created_at and updated_at to notes", up: addNoteTimestamps},
+	{Version: 3, Name: "create notes_fts full-text index", up: createFullTextIndex},
+	{Version: 4, Name: "add deleted_at to notes for the trash", up: addNoteTrash},
+	{Version: 5, Name: "create note_revisions table", up: createNoteRevisions},
+	{Version: 6, Name: "add uuid to notes and create tombstones table", up: addNoteUUIDs},
+	{Version: 7, Name: "create saved_searches table", up: createSavedSearches},
+	{Version: 8, Name: "store revision tags as JSON arrays", up: encodeRevisionTags},
+}
+
+func schemaVersion(ctx context.Context, q queryRower) (int, error) {
+	var version int
+	err := q.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// migrate applies every pending migration, each in its own transaction,
+// and returns the ones that ran.
+func migrate(ctx context.Context, dbConn *sql.DB) ([]Migration, error) {
+	current, err := schemaVersion(ctx, dbConn)
+	if err != nil {
+		return nil, err
+	}
+	latest := migrations[len(migrations)-1].Version
+	if current > latest {
+		return nil, fmt.Errorf("database schema version %d is newer than the latest known version %d, upgrade hugnin", current, latest)
+	}
+
+	var applied []Migration
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+		log.Printf("Applying migration %d: %s ...", m.Version, m.Name)
+		err = applyMigration(ctx, dbConn, m)
+		if err != nil {
+			return applied, fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+		m.Applied = true
+		applied = append(applied, m)
+	}
+	return applied, nil
+}
+
+func applyMigration(ctx context.Context, dbConn *sql.DB, m Migration) error {
+	tx, err := dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = m.up(ctx, tx)
+	if err != nil {
+		return err
+	}
+	// PRAGMA does not accept bound parameters; Version is never user input.
+	_, err = tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", m.Version))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// migrationStatus lists every known migration and whether it has been
+// applied to the database.
+func migrationStatus(ctx context.Context, dbConn *sql.DB) ([]Migration, error) {
+	current, err := schemaVersion(ctx, dbConn)
+	if err != nil {
+		return nil, err
+	}
+	var result []Migration
+	for _, m := range migrations {
+		m.Applied = m.Version <= current
+		result = append(result, m)
+	}
+	return result, nil
+}
+
+// createNotesSchema creates the notes, tags and note_tags tables. Databases
+// created before tags were normalized keep one notes row per tag; those rows
+// are folded into the new layout so existing notes survive the upgrade.
+func createNotesSchema(ctx context.Context, tx *sql.Tx) error {
+	legacy, err := hasLegacyNotesTable(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	if legacy {
+		log.Println("Migrating notes table to normalized tags...")
+		_, err = tx.ExecContext(ctx, "ALTER TABLE notes RENAME TO notes_legacy")
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS notes
+		(id INTEGER PRIMARY KEY AUTOINCREMENT,
+		note TEXT);`)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS tags
+		(id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE);`)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS note_tags
+		(note_id INTEGER NOT NULL REFERENCES notes(id) ON DELETE CASCADE,
+		tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+		PRIMARY KEY (note_id, tag_id));`)
+	if err != nil {
+		return err
+	}
+
+	if legacy {
+		return migrateLegacyNotes(ctx, tx)
+	}
+	return nil
+}
+
+// hasLegacyNotesTable reports whether the notes table still carries the
+// old per-row tags column.
+func hasLegacyNotesTable(ctx context.Context, tx *sql.Tx) (bool, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT name FROM pragma_table_info('notes')")
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			return false, err
+		}
+		if name == "tags" {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// migrateLegacyNotes folds the rows of notes_legacy into notes and links
+// their tags. Older versions added a note as one row per tag, written in a
+// single transaction: consecutive Ids with the same text and different
+// tags. Such a run becomes one note under its lowest Id; any other row,
+// including a later one with the same text, stays a note of its own.
+func migrateLegacyNotes(ctx context.Context, tx *sql.Tx) error {
+	type legacyNote struct {
+		id   int64
+		note sql.NullString
+		tags []string
+	}
+	rows, err := tx.QueryContext(ctx, "SELECT id, note, IFNULL(TRIM(tags), '') FROM notes_legacy ORDER BY id")
+	if err != nil {
+		return err
+	}
+	var notes []*legacyNote
+	var last *legacyNote
+	var lastId int64
+	for rows.Next() {
+		var id int64
+		var note sql.NullString
+		var tag string
+		err = rows.Scan(&id, &note, &tag)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		sameRun := last != nil && id == lastId+1 && note == last.note && tag != "" && !containsTag(last.tags, tag)
+		if !sameRun {
+			last = &legacyNote{id: id, note: note}
+			notes = append(notes, last)
+		}
+		if tag != "" {
+			last.tags = append(last.tags, tag)
+		}
+		lastId = id
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, n := range notes {
+		_, err = tx.ExecContext(ctx, "INSERT INTO notes (id, note) VALUES (?, ?)", n.id, n.note)
+		if err != nil {
+			return err
+		}
+		for _, tag := range n.tags {
+			_, err = tx.ExecContext(ctx, "INSERT OR IGNORE INTO tags (name) VALUES (?)", tag)
+			if err != nil {
+				return err
+			}
+			_, err = tx.ExecContext(ctx, "INSERT OR IGNORE INTO note_tags (note_id, tag_id) SELECT ?, id FROM tags WHERE name = ?", n.id, tag)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	_, err = tx.ExecContext(ctx, "DROP TABLE notes_legacy")
+	return err
+}
+
+// addNoteTimestamps adds the created_at and updated_at columns. Notes that
+// predate them are stamped with the time of the migration.
+func addNoteTimestamps(ctx context.Context, tx *sql.Tx) error {
+	statements := []string{
+		`ALTER TABLE notes ADD COLUMN created_at TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE notes ADD COLUMN updated_at TEXT NOT NULL DEFAULT ''`,
+		`UPDATE notes SET created_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now'), updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now')`,
+		`CREATE INDEX IF NOT EXISTS notes_created_at ON notes (created_at)`,
+	}
+	for _, stmt := range statements {
+		_, err := tx.ExecContext(ctx, stmt)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addNoteTrash adds deleted_at, which is empty for live notes and holds the
+// deletion time of notes in the trash.
+func addNoteTrash(ctx context.Context, tx *sql.Tx) error {
+	statements := []string{
+		`ALTER TABLE notes ADD COLUMN deleted_at TEXT NOT NULL DEFAULT ''`,
+		`CREATE INDEX IF NOT EXISTS notes_deleted_at ON notes (deleted_at)`,
+	}
+	for _, stmt := range statements {
+		_, err := tx.ExecContext(ctx, stmt)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/iamunni/hugnin/model"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db")+"?_foreign_keys=on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestMigrate_FreshDatabase(t *testing.T) {
+	db := openTestDB(t)
+
+	applied, err := migrate(context.Background(), db)
+	if err != nil {
+		t.Fatalf("migrate() error = %v", err)
+	}
+	if len(applied) != len(migrations) {
+		t.Errorf("migrate() applied %d migrations, want %d", len(applied), len(migrations))
+	}
+	version, err := schemaVersion(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := migrations[len(migrations)-1].Version; version != want {
+		t.Errorf("schemaVersion() = %d, want %d", version, want)
+	}
+
+	applied, err = migrate(context.Background(), db)
+	if err != nil {
+		t.Fatalf("second migrate() error = %v", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("second migrate() applied %v, want nothing", applied)
+	}
+
+	status, err := migrationStatus(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range status {
+		if !m.Applied {
+			t.Errorf("migration %d not reported as applied", m.Version)
+		}
+	}
+}
+
+func TestMigrate_RejectsNewerSchema(t *testing.T) {
+	db := openTestDB(t)
+	_, err := db.Exec("PRAGMA user_version = 9999")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrate(context.Background(), db); err == nil {
+		t.Error("migrate() expected an error for a newer schema version")
+	}
+}
+
+func TestMigrate_LegacyNotes(t *testing.T) {
+	db := openTestDB(t)
+	_, err := db.Exec(`CREATE TABLE notes (id INTEGER PRIMARY KEY AUTOINCREMENT, note TEXT, tags TEXT);
+		INSERT INTO notes (note, tags) VALUES ('note1', 'tag1'), ('note1', 'tag2'), ('note2', ''), ('note3', 'tag2');`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := migrate(context.Background(), db); err != nil {
+		t.Fatalf("migrate() error = %v", err)
+	}
+
+	s := &SQLiteStore{dbConn: db}
+	got, err := s.Read(context.Background(), model.Note{}, model.DateRange{})
+	if err != nil {
+		t.Fatalf("SQLiteStore.Read() error = %v", err)
+	}
+	uuids := map[string]bool{}
+	for i := range got {
+		if got[i].CreatedAt.IsZero() || got[i].UpdatedAt.IsZero() {
+			t.Errorf("note %d was not stamped by the migration", got[i].Id)
+		}
+		if got[i].UUID == "" || uuids[got[i].UUID] {
+			t.Errorf("note %d got UUID %q, want a unique one", got[i].Id, got[i].UUID)
+		}
+		uuids[got[i].UUID] = true
+		got[i].CreatedAt, got[i].UpdatedAt, got[i].UUID = time.Time{}, time.Time{}, ""
+	}
+	want := []model.Note{
+		{Id: 1, Value: "note1", Tags: []string{"tag1", "tag2"}},
+		{Id: 3, Value: "note2"},
+		{Id: 4, Value: "note3", Tags: []string{"tag2"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SQLiteStore.Read() = %v, want %v", got, want)
+	}
+}
+
+func TestMigrate_LegacyNotesWithTheSameText(t *testing.T) {
+	db := openTestDB(t)
+	// Notes 1 and 4 were each added with two tags, notes 3, 6 and 7 with
+	// one; 1, 4, 6 and 7 happen to say the same thing.
+	_, err := db.Exec(`CREATE TABLE notes (id INTEGER PRIMARY KEY AUTOINCREMENT, note TEXT, tags TEXT);
+		INSERT INTO notes (id, note, tags) VALUES
+			(1, 'call mom', 'family'), (2, 'call mom', 'phone'),
+			(3, 'buy milk', 'errands'),
+			(4, 'call mom', 'family'), (5, 'call mom', 'phone'),
+			(6, 'call mom', 'family'),
+			(7, 'call mom', 'family');`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := migrate(context.Background(), db); err != nil {
+		t.Fatalf("migrate() error = %v", err)
+	}
+
+	s := &SQLiteStore{dbConn: db}
+	got, err := s.Read(context.Background(), model.Note{}, model.DateRange{})
+	if err != nil {
+		t.Fatalf("SQLiteStore.Read() error = %v", err)
+	}
+	for i := range got {
+		got[i].CreatedAt, got[i].UpdatedAt, got[i].UUID = time.Time{}, time.Time{}, ""
+	}
+	want := []model.Note{
+		{Id: 1, Value: "call mom", Tags: []string{"family", "phone"}},
+		{Id: 3, Value: "buy milk", Tags: []string{"errands"}},
+		{Id: 4, Value: "call mom", Tags: []string{"family", "phone"}},
+		{Id: 6, Value: "call mom", Tags: []string{"family"}},
+		{Id: 7, Value: "call mom", Tags: []string{"family"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SQLiteStore.Read() = %v, want %v", got, want)
+	}
+}
+
+func TestMigrate_RevisionTagsAsJSON(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	if _, err := migrate(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+	_, err := db.Exec(`INSERT INTO notes (id, note, created_at, updated_at, deleted_at, uuid) VALUES (1, 'new', '', '', '', 'uuid-1');
+		INSERT INTO note_revisions (note_id, revision, note, tags, created_at) VALUES
+			(1, 1, 'first', 'db,oncall', '2024-01-02T03:04:05.000Z'),
+			(1, 2, 'second', '', '2024-01-02T03:04:05.000Z');
+		PRAGMA user_version = 7;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := migrate(ctx, db); err != nil {
+		t.Fatalf("migrate() error = %v", err)
+	}
+	revisions, err := queryRevisions(ctx, db, 1)
+	if err != nil {
+		t.Fatalf("queryRevisions() error = %v", err)
+	}
+	if len(revisions) != 2 || !reflect.DeepEqual(revisions[0].Tags, []string{"db", "oncall"}) || revisions[1].Tags != nil {
+		t.Errorf("queryRevisions() after migrate = %+v", revisions)
+	}
+}
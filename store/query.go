@@ -0,0 +1,177 @@
+package store
+
+import (
+	"strings"
+	"time"
+
+	"github.com/iamunni/hugnin/model"
+)
+
+// query builds a SQL statement from fixed fragments while collecting the
+// user supplied values as bound arguments, so no input is ever spliced into
+// the SQL text.
+type query struct {
+	sb    strings.Builder
+	conds []string
+	args  []interface{}
+	tail  string
+}
+
+// newQuery starts a statement with base, whose placeholders are bound to
+// args ahead of the ones added by where.
+func newQuery(base string, args ...interface{}) *query {
+	q := &query{args: args}
+	q.sb.WriteString(base)
+	return q
+}
+
+// where adds a condition that is ANDed with the others. cond must use one
+// "?" placeholder per arg.
+func (q *query) where(cond string, args ...interface{}) *query {
+	q.conds = append(q.conds, cond)
+	q.args = append(q.args, args...)
+	return q
+}
+
+// live leaves out the notes in the trash.
+func (q *query) live() *query {
+	return q.where("n.deleted_at = ''")
+}
+
+// within limits the notes to those created inside period.
+func (q *query) within(period model.DateRange) *query {
+	if !period.Since.IsZero() {
+		q.where("n.created_at >= ?", formatTime(period.Since))
+	}
+	if !period.Until.IsZero() {
+		q.where("n.created_at < ?", formatTime(period.Until))
+	}
+	return q
+}
+
+// suffix sets the clause appended after the WHERE conditions.
+func (q *query) suffix(tail string) *query {
+	q.tail = tail
+	return q
+}
+
+func (q *query) build() (string, []interface{}) {
+	stmt := q.sb.String()
+	if len(q.conds) > 0 {
+		stmt += " WHERE " + strings.Join(q.conds, " AND ")
+	}
+	if q.tail != "" {
+		stmt += " " + q.tail
+	}
+	return stmt + ";", q.args
+}
+
+// placeholders returns n comma separated "?" placeholders.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+// notesUnderTagsSQL selects the Ids of notes carrying any of the given tags
+// or a tag below one of them, where "work/oncall" is below "work". Tags
+// below "work" sort between "work/" and "work0" since "0" follows "/".
+func notesUnderTagsSQL(tags []string) (string, []interface{}) {
+	conds := make([]string, 0, len(tags))
+	args := make([]interface{}, 0, 3*len(tags))
+	for _, tag := range tags {
+		conds = append(conds, "fg.name = ? OR (fg.name > ? AND fg.name < ?)")
+		args = append(args, tag, tag+"/", tag+"0")
+	}
+	return "SELECT ft.note_id FROM note_tags ft JOIN tags fg ON fg.id = ft.tag_id WHERE " + strings.Join(conds, " OR "), args
+}
+
+// buildReadQuery returns the statement used by Read. A positive note.Id
+// selects that note, note.Value is a LIKE pattern, so "%" and "_" keep
+// their wildcard meaning, and tags select the notes carrying them or a
+// tag below them.
+func buildReadQuery(note model.Note, period model.DateRange) (string, []interface{}) {
+	q := newQuery(selectNotesSQL)
+	q.live()
+	q.within(period)
+	if note.Id > 0 {
+		q.where("n.id = ?", note.Id)
+	}
+	if len(note.Value) > 0 {
+		q.where("n.note LIKE ?", note.Value)
+	}
+	if len(note.Tags) > 0 {
+		tagged, args := notesUnderTagsSQL(note.Tags)
+		q.where("n.id IN ("+tagged+")", args...)
+	}
+	return q.suffix("ORDER BY n.id").build()
+}
+
+// buildSearchQuery returns the statement Search uses when full-text search
+// is unavailable. The keyword is matched as a literal, case insensitive
+// substring of the note or of any of its tags.
+func buildSearchQuery(keyword string, period model.DateRange) (string, []interface{}) {
+	pattern := "%" + escapeLike(keyword) + "%"
+	q := newQuery(selectNotesSQL)
+	q.live()
+	q.within(period)
+	q.where(`(n.note LIKE ? ESCAPE '\' OR n.id IN (SELECT st.note_id FROM note_tags st JOIN tags sg ON sg.id = st.tag_id WHERE sg.name LIKE ? ESCAPE '\'))`, pattern, pattern)
+	return q.suffix("ORDER BY n.id").build()
+}
+
+// escapeLike escapes the LIKE wildcards in value so it matches literally
+// with ESCAPE '\'.
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
+// buildTrashQuery returns the statement used by Delete, which moves notes
+// to the trash by stamping their deleted_at. An Id of -1 selects every
+// note, any other non zero Id selects that note, otherwise notes carrying
+// one of the given tags or a tag below them are selected, as in Read. ok
+// is false when note selects nothing.
+func buildTrashQuery(note model.Note, stamp string) (stmt string, args []interface{}, ok bool) {
+	q := newQuery("UPDATE notes AS n SET deleted_at = ?", stamp)
+	q.live()
+	switch {
+	case note.Id == -1:
+	case note.Id != 0:
+		q.where("n.id = ?", note.Id)
+	case len(note.Tags) > 0:
+		tagged, args := notesUnderTagsSQL(note.Tags)
+		q.where("n.id IN ("+tagged+")", args...)
+	default:
+		return "", nil, false
+	}
+	stmt, args = q.build()
+	return stmt, args, true
+}
+
+// buildTrashedQuery returns the statement used by Trashed, most recently
+// deleted first.
+func buildTrashedQuery() (string, []interface{}) {
+	q := newQuery(selectNotesSQL)
+	q.where("n.deleted_at != ''")
+	return q.suffix("ORDER BY n.deleted_at DESC, n.id").build()
+}
+
+// buildPurgeQuery returns the statement used by Purge. It removes the notes
+// trashed before cutoff, or the whole trash when cutoff is zero.
+func buildPurgeQuery(cutoff time.Time) (string, []interface{}) {
+	return newQuery("DELETE FROM notes AS n").trashedBefore(cutoff).build()
+}
+
+// buildTombstoneQuery returns the statement Purge runs first, recording a
+// tombstone for every note buildPurgeQuery removes.
+func buildTombstoneQuery(cutoff time.Time) (string, []interface{}) {
+	q := newQuery("INSERT OR REPLACE INTO tombstones (uuid, deleted_at) SELECT n.uuid, n.deleted_at FROM notes AS n")
+	return q.trashedBefore(cutoff).build()
+}
+
+// trashedBefore limits the notes to those trashed before cutoff, or to
+// the whole trash when cutoff is zero.
+func (q *query) trashedBefore(cutoff time.Time) *query {
+	q.where("n.deleted_at != ''")
+	if !cutoff.IsZero() {
+		q.where("n.deleted_at < ?", formatTime(cutoff))
+	}
+	return q
+}
@@ -0,0 +1,211 @@
+package store
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/iamunni/hugnin/filter"
+	"github.com/iamunni/hugnin/model"
+)
+
+func Test_buildReadQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		note     model.Note
+		period   model.DateRange
+		wantStmt string
+		wantArgs []interface{}
+	}{
+		{
+			name:     "no filter",
+			note:     model.Note{},
+			wantStmt: selectNotesSQL + " WHERE n.deleted_at = '' ORDER BY n.id;",
+			wantArgs: nil,
+		},
+		{
+			name:     "value filter",
+			note:     model.Note{Value: "it's"},
+			wantStmt: selectNotesSQL + " WHERE n.deleted_at = '' AND n.note LIKE ? ORDER BY n.id;",
+			wantArgs: []interface{}{"it's"},
+		},
+		{
+			name:     "tag filter",
+			note:     model.Note{Tags: []string{"a'b", "c;d"}},
+			wantStmt: selectNotesSQL + " WHERE n.deleted_at = '' AND n.id IN (SELECT ft.note_id FROM note_tags ft JOIN tags fg ON fg.id = ft.tag_id WHERE fg.name = ? OR (fg.name > ? AND fg.name < ?) OR fg.name = ? OR (fg.name > ? AND fg.name < ?)) ORDER BY n.id;",
+			wantArgs: []interface{}{"a'b", "a'b/", "a'b0", "c;d", "c;d/", "c;d0"},
+		},
+		{
+			name:     "value and tag filter",
+			note:     model.Note{Value: "x", Tags: []string{"y"}},
+			wantStmt: selectNotesSQL + " WHERE n.deleted_at = '' AND n.note LIKE ? AND n.id IN (SELECT ft.note_id FROM note_tags ft JOIN tags fg ON fg.id = ft.tag_id WHERE fg.name = ? OR (fg.name > ? AND fg.name < ?)) ORDER BY n.id;",
+			wantArgs: []interface{}{"x", "y", "y/", "y0"},
+		},
+		{
+			name:     "date range",
+			note:     model.Note{Id: 2},
+			period:   model.DateRange{Since: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Until: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+			wantStmt: selectNotesSQL + " WHERE n.deleted_at = '' AND n.created_at >= ? AND n.created_at < ? AND n.id = ? ORDER BY n.id;",
+			wantArgs: []interface{}{"2024-01-01T00:00:00.000Z", "2024-02-01T00:00:00.000Z", int64(2)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stmt, args := buildReadQuery(tt.note, tt.period)
+			if stmt != tt.wantStmt {
+				t.Errorf("buildReadQuery() stmt = %q, want %q", stmt, tt.wantStmt)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("buildReadQuery() args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func Test_buildTrashQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		note     model.Note
+		wantStmt string
+		wantArgs []interface{}
+		wantOk   bool
+	}{
+		{
+			name:     "all",
+			note:     model.Note{Id: -1},
+			wantStmt: "UPDATE notes AS n SET deleted_at = ? WHERE n.deleted_at = '';",
+			wantArgs: []interface{}{testStamp},
+			wantOk:   true,
+		},
+		{
+			name:     "by id",
+			note:     model.Note{Id: 4},
+			wantStmt: "UPDATE notes AS n SET deleted_at = ? WHERE n.deleted_at = '' AND n.id = ?;",
+			wantArgs: []interface{}{testStamp, int64(4)},
+			wantOk:   true,
+		},
+		{
+			name:     "by tags",
+			note:     model.Note{Tags: []string{"%", "x'); DROP TABLE notes; --"}},
+			wantStmt: "UPDATE notes AS n SET deleted_at = ? WHERE n.deleted_at = '' AND n.id IN (SELECT ft.note_id FROM note_tags ft JOIN tags fg ON fg.id = ft.tag_id WHERE fg.name = ? OR (fg.name > ? AND fg.name < ?) OR fg.name = ? OR (fg.name > ? AND fg.name < ?));",
+			wantArgs: []interface{}{testStamp, "%", "%/", "%0", "x'); DROP TABLE notes; --", "x'); DROP TABLE notes; --/", "x'); DROP TABLE notes; --0"},
+			wantOk:   true,
+		},
+		{
+			name:   "nothing selected",
+			note:   model.Note{},
+			wantOk: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stmt, args, ok := buildTrashQuery(tt.note, testStamp)
+			if ok != tt.wantOk {
+				t.Fatalf("buildTrashQuery() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if stmt != tt.wantStmt {
+				t.Errorf("buildTrashQuery() stmt = %q, want %q", stmt, tt.wantStmt)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("buildTrashQuery() args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func Test_buildFindQuery(t *testing.T) {
+	expr, err := filter.Parse(`tag:work AND (tag:"o'neil" OR "100%") -created:<2024-01-02`, func(value string) (time.Time, time.Time, error) {
+		day, err := time.Parse("2006-01-02", value)
+		return day, day.AddDate(0, 0, 1), err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tagged := "n.id IN (SELECT ft.note_id FROM note_tags ft JOIN tags fg ON fg.id = ft.tag_id WHERE fg.name = ? OR (fg.name > ? AND fg.name < ?))"
+	wantStmt := selectNotesSQL + " WHERE n.deleted_at = '' AND n.created_at >= ? AND ((" + tagged + " AND (" + tagged + ` OR n.note LIKE ? ESCAPE '\')) AND NOT ((n.created_at < ?))) ORDER BY n.id;`
+	wantArgs := []interface{}{"2024-01-01T00:00:00.000Z", "work", "work/", "work0", "o'neil", "o'neil/", "o'neil0", `%100\%%`, "2024-01-02T00:00:00.000Z"}
+	stmt, args, err := buildFindQuery(expr, model.DateRange{Since: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stmt != wantStmt {
+		t.Errorf("buildFindQuery() stmt = %q, want %q", stmt, wantStmt)
+	}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("buildFindQuery() args = %v, want %v", args, wantArgs)
+	}
+}
+
+func Test_buildPurgeQuery(t *testing.T) {
+	stmt, args := buildPurgeQuery(time.Time{})
+	if want := "DELETE FROM notes AS n WHERE n.deleted_at != '';"; stmt != want || args != nil {
+		t.Errorf("buildPurgeQuery(zero) = %q, %v, want %q", stmt, args, want)
+	}
+	stmt, args = buildPurgeQuery(testTime)
+	if want := "DELETE FROM notes AS n WHERE n.deleted_at != '' AND n.deleted_at < ?;"; stmt != want || !reflect.DeepEqual(args, []interface{}{testStamp}) {
+		t.Errorf("buildPurgeQuery(%v) = %q, %v, want %q", testTime, stmt, args, want)
+	}
+}
+
+// TestSQLiteStore_HostileInput runs the generated statements against a
+// real database to prove quotes, percent signs and semicolons are treated
+// as data.
+func TestSQLiteStore_HostileInput(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := migrate(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+	seed := []struct {
+		value string
+		tags  []string
+	}{
+		{"it's 100% done; DROP TABLE notes; --", []string{"o'neil"}},
+		{"plain note", []string{"100%"}},
+		{"another note", []string{"work"}},
+	}
+	for _, n := range seed {
+		if _, err := insertNote(context.Background(), db, n.value, n.tags); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run := func(stmt string, args []interface{}) ([]model.Note, error) {
+		return queryNotes(context.Background(), db, stmt, args...)
+	}
+
+	read, err := run(buildReadQuery(model.Note{Value: "it's 100% done; DROP TABLE notes; --", Tags: []string{"o'neil"}}, model.DateRange{}))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if len(read) != 1 || read[0].Id != 1 {
+		t.Errorf("Read() = %v, want note 1", read)
+	}
+
+	found, err := run(buildSearchQuery("100%", model.DateRange{}))
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if len(found) != 2 || found[0].Id != 1 || found[1].Id != 2 {
+		t.Errorf("Search(\"100%%\") = %v, want notes 1 and 2", found)
+	}
+
+	found, err = run(buildSearchQuery("%", model.DateRange{}))
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if len(found) != 2 {
+		t.Errorf("Search(\"%%\") = %v, want only notes containing a literal %%", found)
+	}
+
+	stmt, args, _ := buildTrashQuery(model.Note{Tags: []string{"%", "'; DELETE FROM notes; --"}}, testStamp)
+	if _, err := db.Exec(stmt, args...); err != nil {
+		t.Fatalf("delete error = %v", err)
+	}
+	all, err := run(buildReadQuery(model.Note{}, model.DateRange{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 {
+		t.Errorf("delete by hostile tags removed notes, %d left, want 3", len(all))
+	}
+}
@@ -0,0 +1,135 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/iamunni/hugnin/model"
+)
+
+// Every update copies the note as it was into note_revisions before
+// overwriting it, so notes.note always holds the newest version and
+// note_revisions the earlier ones, numbered from 1 per note. Revisions go
+// away with their note when it is purged from the trash.
+
+// createNoteRevisions is the schema migration that adds note_revisions.
+func createNoteRevisions(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS note_revisions
+		(note_id INTEGER NOT NULL REFERENCES notes(id) ON DELETE CASCADE,
+		revision INTEGER NOT NULL,
+		note TEXT NOT NULL,
+		tags TEXT NOT NULL,
+		created_at TEXT NOT NULL,
+		PRIMARY KEY (note_id, revision));`)
+	return err
+}
+
+// encodeRevisionTags is the schema migration that rewrites the comma
+// separated tags of existing revisions as the JSON arrays noteTagsSQL now
+// builds, so that tags may contain commas.
+func encodeRevisionTags(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, "SELECT rowid, tags FROM note_revisions")
+	if err != nil {
+		return err
+	}
+	encoded := map[int64]string{}
+	for rows.Next() {
+		var rowid int64
+		var tags string
+		err := rows.Scan(&rowid, &tags)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		names := []string{}
+		if tags != "" {
+			names = strings.Split(tags, ",")
+		}
+		data, err := json.Marshal(names)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		encoded[rowid] = string(data)
+	}
+	rows.Close()
+	err = rows.Err()
+	if err != nil {
+		return err
+	}
+	for rowid, tags := range encoded {
+		_, err = tx.ExecContext(ctx, "UPDATE note_revisions SET tags = ? WHERE rowid = ?", tags, rowid)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordRevision copies the live note with the given Id into
+// note_revisions as its next revision. It returns ErrNotFound when there
+// is no such note.
+func recordRevision(ctx context.Context, tx *sql.Tx, noteId int64) error {
+	return copyRevision(ctx, tx, noteId, true)
+}
+
+// copyRevision copies the note with the given Id into note_revisions as
+// its next revision, skipping trashed notes when liveOnly is set. It
+// returns ErrNotFound when no note was copied.
+func copyRevision(ctx context.Context, tx *sql.Tx, noteId int64, liveOnly bool) error {
+	q := newQuery(`INSERT INTO note_revisions (note_id, revision, note, tags, created_at)
+		SELECT n.id, (SELECT IFNULL(MAX(r.revision), 0) + 1 FROM note_revisions r WHERE r.note_id = n.id), n.note, ` + noteTagsSQL + `, n.updated_at
+		FROM notes n`)
+	q.where("n.id = ?", noteId)
+	if liveOnly {
+		q.live()
+	}
+	stmt, args := q.build()
+	res, err := tx.ExecContext(ctx, stmt, args...)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("note %d: %w", noteId, ErrNotFound)
+	}
+	return nil
+}
+
+func queryRevisions(ctx context.Context, dbConn *sql.DB, noteId int64) ([]model.Revision, error) {
+	rows, err := dbConn.QueryContext(ctx, "SELECT revision, note, tags, created_at FROM note_revisions WHERE note_id = ? ORDER BY revision", noteId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []model.Revision
+	for rows.Next() {
+		revision := model.Revision{NoteId: noteId}
+		var tags, createdAt string
+		err := rows.Scan(&revision.Number, &revision.Value, &tags, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+		revision.Tags, err = decodeTags(tags)
+		if err != nil {
+			return nil, err
+		}
+		revision.CreatedAt, err = parseTime(createdAt)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, revision)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
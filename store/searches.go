@@ -0,0 +1,18 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Saved searches are named queries kept with the notes, so the filters
+// run every day need not be typed again. The store keeps their text only;
+// parsing them is left to the caller.
+
+// createSavedSearches is the schema migration that adds saved_searches.
+func createSavedSearches(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS saved_searches
+		(name TEXT PRIMARY KEY,
+		query TEXT NOT NULL);`)
+	return err
+}
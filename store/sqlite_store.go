@@ -1,211 +1,857 @@
 package store
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
-	"reflect"
+	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/iamunni/hugnin/filter"
 	"github.com/iamunni/hugnin/model"
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// selectNotesSQL reads every note together with its tags as a JSON array.
+const selectNotesSQL = `SELECT n.id, n.note, ` + noteTagsSQL + `, n.created_at, n.updated_at, n.deleted_at, n.uuid
+	FROM notes n`
+
+// timeLayout is the fixed width UTC layout of created_at and updated_at.
+// It matches strftime('%Y-%m-%dT%H:%M:%fZ') and sorts chronologically as
+// text.
+const timeLayout = "2006-01-02T15:04:05.000Z"
+
+// now is replaced in tests to get stable timestamps.
+var now = time.Now
+
+// SQLiteStore keeps notes in a SQLite database file. It is created closed;
+// Open connects and migrates the database, after which the store serves
+// any number of calls until Close.
 type SQLiteStore struct {
+	path   string
 	dbConn *sql.DB
+	// manualMigrations leaves the schema alone in Open, for
+	// NewUnmigratedSQLiteStore.
+	manualMigrations bool
 }
 
-func NewSQLiteStore() Store {
-	db, err := sql.Open("sqlite3", "./sqlite-database.db")
+// memoryPath is the path of a database that lives in memory and is gone
+// once the store is closed.
+const memoryPath = ":memory:"
+
+// errStoreClosed is returned by calls made before Open or after Close.
+var errStoreClosed = errors.New("store is not open")
+
+// NewSQLiteStore returns a store for the SQLite database at path. Call
+// Open before using it.
+func NewSQLiteStore(path string) Store {
+	return &SQLiteStore{
+		path: path,
+	}
+}
+
+// NewUnmigratedSQLiteStore returns a store like NewSQLiteStore whose Open
+// leaves the schema as it is, for tools that list and apply the
+// migrations themselves through Migrator. Only the Migrator methods are
+// safe to call on a database that is not up to date.
+func NewUnmigratedSQLiteStore(path string) Store {
+	return &SQLiteStore{
+		path:             path,
+		manualMigrations: true,
+	}
+}
+
+// Open opens the database, creating the file and its parent directories
+// when needed, brings its schema up to date and checks the full-text
+// index, see checkFullTextIndex.
+func (s *SQLiteStore) Open(ctx context.Context) error {
+	if s.dbConn != nil {
+		return nil
+	}
+	if s.path != memoryPath {
+		err := os.MkdirAll(filepath.Dir(s.path), 0o700)
+		if err != nil {
+			return err
+		}
+	}
+	db, err := sql.Open("sqlite3", s.path+"?_foreign_keys=on&_busy_timeout=5000")
+	if err != nil {
+		return err
+	}
+	if s.path == memoryPath {
+		// Every connection to :memory: opens a new, empty database.
+		db.SetMaxOpenConns(1)
+	}
+	err = db.PingContext(ctx)
 	if err != nil {
-		log.Fatal(err)
+		db.Close()
+		return err
 	}
-	err = db.Ping()
+	if !s.manualMigrations {
+		_, err = migrate(ctx, db)
+		if err != nil {
+			db.Close()
+			return err
+		}
+	}
+	err = checkFullTextIndex(ctx, db)
 	if err != nil {
-		panic(err)
+		db.Close()
+		return err
 	}
-	return &SQLiteStore{
-		dbConn: db,
+	s.dbConn = db
+	return nil
+}
+
+// Close releases the database connection. Closing a closed store is a
+// no-op.
+func (s *SQLiteStore) Close() error {
+	if s.dbConn == nil {
+		return nil
+	}
+	err := s.dbConn.Close()
+	s.dbConn = nil
+	return err
+}
+
+func (s *SQLiteStore) Write(ctx context.Context, value string, tags []string) (int64, error) {
+	if s.dbConn == nil {
+		return 0, errStoreClosed
+	}
+	id, err := insertNote(ctx, s.dbConn, value, tags)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// Update replaces the text and tags of the note with note.Id, keeping the
+// previous version as a revision.
+func (s *SQLiteStore) Update(ctx context.Context, note model.Note) error {
+	if s.dbConn == nil {
+		return errStoreClosed
 	}
+	return updateNote(ctx, s.dbConn, note)
 }
 
-func (s *SQLiteStore) Write(value string, tags []string) error {
-	defer s.dbConn.Close()
-	err := insertNote(s.dbConn, value, tags)
+func (s *SQLiteStore) Read(ctx context.Context, note model.Note, period model.DateRange) ([]model.Note, error) {
+	if s.dbConn == nil {
+		return nil, errStoreClosed
+	}
+	stmt, args := buildReadQuery(note, period)
+	return queryNotes(ctx, s.dbConn, stmt, args...)
+}
+
+func (s *SQLiteStore) Init(ctx context.Context, dbFile string) error {
+	if s.dbConn == nil {
+		return errStoreClosed
+	}
+	err := createDatabase(dbFile)
+	if err != nil {
+		return err
+	}
+	_, err = migrate(ctx, s.dbConn)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (s *SQLiteStore) Read(note model.Note) ([]model.Note, error) {
-	defer s.dbConn.Close()
+// Migrate applies any pending schema migrations and returns the ones that
+// ran.
+func (s *SQLiteStore) Migrate(ctx context.Context) ([]Migration, error) {
+	if s.dbConn == nil {
+		return nil, errStoreClosed
+	}
+	return migrate(ctx, s.dbConn)
+}
 
-	var sb strings.Builder
-	sb.WriteString("SELECT * FROM notes")
-	if !reflect.DeepEqual(note, model.Note{}) {
-		sb.WriteString(" WHERE")
-		if len(note.Value) > 0 {
-			sb.WriteString(fmt.Sprintf(" note LIKE '%s'", note.Value))
+// Migrations lists every known schema migration with its applied state.
+func (s *SQLiteStore) Migrations(ctx context.Context) ([]Migration, error) {
+	if s.dbConn == nil {
+		return nil, errStoreClosed
+	}
+	return migrationStatus(ctx, s.dbConn)
+}
+
+// Search ranks notes against keyword with the full-text index when it is
+// available, and falls back to a case insensitive substring match
+// otherwise.
+func (s *SQLiteStore) Search(ctx context.Context, keyword string, period model.DateRange) ([]model.Note, error) {
+	if s.dbConn == nil {
+		return nil, errStoreClosed
+	}
+	ready, err := fullTextReady(ctx, s.dbConn)
+	if err != nil {
+		return nil, err
+	}
+	if !ready || strings.TrimSpace(keyword) == "" {
+		stmt, args := buildSearchQuery(keyword, period)
+		result, err := queryNotes(ctx, s.dbConn, stmt, args...)
+		if err != nil {
+			return nil, err
 		}
-		if len(note.Tag) > 0 {
-			if len(note.Value) > 0 {
-				sb.WriteString(" AND")
-			}
-			tags := strings.Split(note.Tag, ",")
-			quotedTags := "'" + strings.Join(tags, "','") + "'"
-			sb.WriteString(fmt.Sprintf(" tags IN (%s)", quotedTags))
+		for i := range result {
+			result[i].Snippet = substringSnippet(result[i].Value, keyword)
 		}
+		return result, nil
+	}
+
+	stmt, args := buildFullTextQuery(keyword, period)
+	result, err := queryNotes(ctx, s.dbConn, stmt, args...)
+	if isFullTextSyntaxError(err) {
+		stmt, args = buildFullTextQuery(quoteFullTextQuery(keyword), period)
+		result, err = queryNotes(ctx, s.dbConn, stmt, args...)
+	}
+	if err != nil {
+		return nil, err
 	}
+	return result, nil
+}
 
-	sb.WriteString(";")
-	stmt := sb.String()
+func (s *SQLiteStore) Find(ctx context.Context, expr filter.Expr, period model.DateRange) ([]model.Note, error) {
+	if s.dbConn == nil {
+		return nil, errStoreClosed
+	}
+	stmt, args, err := buildFindQuery(expr, period)
+	if err != nil {
+		return nil, err
+	}
+	return queryNotes(ctx, s.dbConn, stmt, args...)
+}
 
-	var result []model.Note
-	rows, err := s.dbConn.Query(stmt)
+// RebuildSearchIndex recreates the full-text index from the notes, creating
+// it and its triggers first for databases that were migrated or opened
+// without FTS5.
+func (s *SQLiteStore) RebuildSearchIndex(ctx context.Context) error {
+	if s.dbConn == nil {
+		return errStoreClosed
+	}
+	compiled, err := fullTextCompiled(ctx, s.dbConn)
+	if err != nil {
+		return err
+	}
+	if !compiled {
+		return errFullTextUnavailable
+	}
+	tx, err := s.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	err = rebuildFullTextIndex(ctx, tx)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Delete moves the notes selected by note to the trash and returns how many
+// were moved. See buildTrashQuery for the selection rules.
+func (s *SQLiteStore) Delete(ctx context.Context, note model.Note) (int64, error) {
+	if s.dbConn == nil {
+		return 0, errStoreClosed
+	}
+	stmt, args, ok := buildTrashQuery(note, formatTime(now()))
+	if !ok {
+		return 0, nil
+	}
+	res, err := s.dbConn.ExecContext(ctx, stmt, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Tags returns every tag with the number of live notes carrying it and
+// their latest update time, ordered by name.
+func (s *SQLiteStore) Tags(ctx context.Context) ([]model.Tag, error) {
+	if s.dbConn == nil {
+		return nil, errStoreClosed
+	}
+	rows, err := s.dbConn.QueryContext(ctx, `SELECT t.name, COUNT(n.id), IFNULL(MAX(n.updated_at), '') FROM tags t
+		LEFT JOIN note_tags nt ON nt.tag_id = t.id
+		LEFT JOIN notes n ON n.id = nt.note_id AND n.deleted_at = ''
+		GROUP BY t.id ORDER BY t.name`)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
+
+	var result []model.Tag
 	for rows.Next() {
-		var note model.Note
-		err = rows.Scan(&note.Id, &note.Value, &note.Tag)
+		var tag model.Tag
+		var lastUsed string
+		err := rows.Scan(&tag.Name, &tag.Notes, &lastUsed)
 		if err != nil {
 			return nil, err
 		}
-		result = append(result, note)
+		if lastUsed != "" {
+			tag.LastUsed, err = parseTime(lastUsed)
+			if err != nil {
+				return nil, err
+			}
+		}
+		result = append(result, tag)
 	}
 	err = rows.Err()
 	if err != nil {
 		return nil, err
 	}
-
 	return result, nil
 }
 
-func (s *SQLiteStore) Init(dbFile string) error {
-	defer s.dbConn.Close()
-	err := createDatabase(dbFile)
+// MergeTags replaces the tags from with the tag into on every note
+// carrying one of them, keeping the replaced versions as revisions, and
+// removes the tags from. It returns how many notes changed.
+func (s *SQLiteStore) MergeTags(ctx context.Context, from []string, into string) (int64, error) {
+	if s.dbConn == nil {
+		return 0, errStoreClosed
+	}
+	tx, err := s.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	merged, err := mergeTags(ctx, tx, from, into)
+	if err != nil {
+		return 0, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+	return merged, nil
+}
+
+// RenameTags gives each tag that is a key of renames the name it maps to,
+// keeping the replaced versions as revisions. It returns how many notes
+// changed.
+func (s *SQLiteStore) RenameTags(ctx context.Context, renames map[string]string) (int64, error) {
+	if s.dbConn == nil {
+		return 0, errStoreClosed
+	}
+	tx, err := s.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	renamed, err := renameTags(ctx, tx, renames)
+	if err != nil {
+		return 0, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+	return renamed, nil
+}
+
+// PruneTags removes the tags no note carries, trashed notes included, and
+// returns how many were removed.
+func (s *SQLiteStore) PruneTags(ctx context.Context) (int64, error) {
+	if s.dbConn == nil {
+		return 0, errStoreClosed
+	}
+	res, err := s.dbConn.ExecContext(ctx, "DELETE FROM tags WHERE id NOT IN (SELECT tag_id FROM note_tags)")
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *SQLiteStore) SaveSearch(ctx context.Context, search model.SavedSearch) error {
+	if s.dbConn == nil {
+		return errStoreClosed
+	}
+	_, err := s.dbConn.ExecContext(ctx, "INSERT OR REPLACE INTO saved_searches (name, query) VALUES (?, ?)", search.Name, search.Query)
+	return err
+}
+
+func (s *SQLiteStore) SavedSearches(ctx context.Context) ([]model.SavedSearch, error) {
+	if s.dbConn == nil {
+		return nil, errStoreClosed
+	}
+	rows, err := s.dbConn.QueryContext(ctx, "SELECT name, query FROM saved_searches ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []model.SavedSearch
+	for rows.Next() {
+		var search model.SavedSearch
+		err := rows.Scan(&search.Name, &search.Query)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, search)
+	}
+	return result, rows.Err()
+}
+
+func (s *SQLiteStore) DeleteSavedSearch(ctx context.Context, name string) error {
+	if s.dbConn == nil {
+		return errStoreClosed
+	}
+	res, err := s.dbConn.ExecContext(ctx, "DELETE FROM saved_searches WHERE name = ?", name)
 	if err != nil {
 		return err
 	}
-	err = createTable(s.dbConn)
+	deleted, err := res.RowsAffected()
 	if err != nil {
 		return err
 	}
+	if deleted == 0 {
+		return fmt.Errorf("saved search %q: %w", name, ErrNotFound)
+	}
 	return nil
 }
 
-func (s *SQLiteStore) Search(keyword string) ([]model.Note, error) {
-	defer s.dbConn.Close()
-	rows, err := s.dbConn.Query("SELECT * FROM notes")
-	if err != nil {
-		return nil, err
+// Revisions returns the earlier versions of the note with the given Id,
+// oldest first.
+func (s *SQLiteStore) Revisions(ctx context.Context, id int64) ([]model.Revision, error) {
+	if s.dbConn == nil {
+		return nil, errStoreClosed
 	}
+	return queryRevisions(ctx, s.dbConn, id)
+}
 
-	defer rows.Close()
+// Trashed returns the notes in the trash, most recently deleted first.
+func (s *SQLiteStore) Trashed(ctx context.Context) ([]model.Note, error) {
+	if s.dbConn == nil {
+		return nil, errStoreClosed
+	}
+	stmt, args := buildTrashedQuery()
+	return queryNotes(ctx, s.dbConn, stmt, args...)
+}
 
-	var result []model.Note
-	note := model.Note{}
+// Restore moves the note with the given Id out of the trash. Restoring
+// counts as a change, so it sets updated_at.
+func (s *SQLiteStore) Restore(ctx context.Context, id int64) error {
+	if s.dbConn == nil {
+		return errStoreClosed
+	}
+	res, err := s.dbConn.ExecContext(ctx, "UPDATE notes SET deleted_at = '', updated_at = ? WHERE id = ? AND deleted_at != ''", formatTime(now()), id)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("note %d is not in the trash: %w", id, ErrNotFound)
+	}
+	return nil
+}
 
-	for rows.Next() {
-		note = model.Note{}
+// Purge permanently removes the notes trashed before cutoff, or every
+// trashed note when cutoff is zero, leaving a tombstone for each, and
+// returns how many were removed.
+func (s *SQLiteStore) Purge(ctx context.Context, cutoff time.Time) (int64, error) {
+	if s.dbConn == nil {
+		return 0, errStoreClosed
+	}
+	tx, err := s.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
 
-		err = rows.Scan(&note.Id, &note.Value, &note.Tag)
+	stmt, args := buildTombstoneQuery(cutoff)
+	_, err = tx.ExecContext(ctx, stmt, args...)
+	if err != nil {
+		return 0, err
+	}
+	stmt, args = buildPurgeQuery(cutoff)
+	res, err := tx.ExecContext(ctx, stmt, args...)
+	if err != nil {
+		return 0, err
+	}
+	purged, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+	return purged, nil
+}
 
-		if strings.Contains(note.Value, keyword) {
-			result = append(result, note)
-		} else if strings.Contains(note.Tag, keyword) {
-			result = append(result, note)
+// Import writes notes with their tags, timestamps and trash state in a
+// single transaction and returns how many were added. Nothing is written
+// when any note fails.
+func (s *SQLiteStore) Import(ctx context.Context, notes []model.Note, mode ImportMode) (int64, error) {
+	if s.dbConn == nil {
+		return 0, errStoreClosed
+	}
+	tx, err := s.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	known, uuids := map[string]bool{}, map[string]bool{}
+	if mode == ImportReplace {
+		for _, stmt := range []string{"DELETE FROM notes", "DELETE FROM tags", "DELETE FROM tombstones"} {
+			_, err = tx.ExecContext(ctx, stmt)
+			if err != nil {
+				return 0, err
+			}
+		}
+		// Notes keeping their Id go first so the Ids SQLite picks for the
+		// others cannot collide with them.
+		notes = append([]model.Note(nil), notes...)
+		sort.SliceStable(notes, func(i, j int) bool { return notes[i].Id > 0 && notes[j].Id <= 0 })
+	} else {
+		known, err = contentHashes(ctx, tx)
+		if err != nil {
+			return 0, err
+		}
+		uuids, err = noteUUIDs(ctx, tx)
+		if err != nil {
+			return 0, err
 		}
+	}
 
+	var imported int64
+	for _, note := range notes {
+		if mode == ImportMerge {
+			hash := ContentHash(note)
+			if known[hash] {
+				continue
+			}
+			known[hash] = true
+			note.Id = 0
+		}
+		if note.UUID == "" || uuids[note.UUID] {
+			note.UUID = newUUID()
+		}
+		uuids[note.UUID] = true
+		err = importNote(ctx, tx, note)
 		if err != nil {
-			return nil, err
+			return 0, err
 		}
+		imported++
+	}
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
 	}
+	return imported, nil
+}
 
-	return result, nil
+// Rewrite passes the text of every note and revision through rewrite in a
+// single transaction, leaving timestamps and revisions alone. Nothing is
+// written when rewrite fails.
+func (s *SQLiteStore) Rewrite(ctx context.Context, rewrite func(text string) (string, error)) (int64, error) {
+	if s.dbConn == nil {
+		return 0, errStoreClosed
+	}
+	tx, err := s.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var rewritten int64
+	for _, table := range []string{"notes", "note_revisions"} {
+		changed, err := rewriteTexts(ctx, tx, table, rewrite)
+		if err != nil {
+			return 0, err
+		}
+		rewritten += changed
+	}
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+	return rewritten, nil
 }
 
-func (s *SQLiteStore) Delete(note model.Note) error {
-	defer s.dbConn.Close()
-	if note.Id == -1 {
-		statement, err := s.dbConn.Prepare("DELETE FROM notes") // Prepare SQL Statement
+// Put stores notes by UUID in a single transaction, keeping their
+// timestamps and trash state. See putNote.
+func (s *SQLiteStore) Put(ctx context.Context, notes []model.Note) error {
+	if s.dbConn == nil {
+		return errStoreClosed
+	}
+	tx, err := s.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, note := range notes {
+		err = putNote(ctx, tx, note)
 		if err != nil {
 			return err
 		}
-		statement.Exec() // Execute SQL Statements
-		return nil
-	} else if note.Id != 0 {
-		stmt := fmt.Sprintf("DELETE FROM notes WHERE Id=%d", note.Id)
-		statement, err := s.dbConn.Prepare(stmt) // Prepare SQL Statement
+	}
+	return tx.Commit()
+}
+
+// Forget permanently removes the notes with the UUIDs of tombstones and
+// records the tombstones in a single transaction. It returns how many
+// notes were removed.
+func (s *SQLiteStore) Forget(ctx context.Context, tombstones []model.Tombstone) (int64, error) {
+	if s.dbConn == nil {
+		return 0, errStoreClosed
+	}
+	tx, err := s.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var forgotten int64
+	for _, tombstone := range tombstones {
+		removed, err := forgetNote(ctx, tx, tombstone)
 		if err != nil {
-			return err
+			return 0, err
 		}
-		statement.Exec() // Execute SQL Statements
-		return nil
+		if removed {
+			forgotten++
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+	return forgotten, nil
+}
+
+// Tombstones returns the tombstones of the purged notes, ordered by UUID.
+func (s *SQLiteStore) Tombstones(ctx context.Context) ([]model.Tombstone, error) {
+	if s.dbConn == nil {
+		return nil, errStoreClosed
+	}
+	return queryTombstones(ctx, s.dbConn)
+}
+
+// rewriteTexts runs rewrite over the note column of table and stores the
+// texts it changed.
+func rewriteTexts(ctx context.Context, tx *sql.Tx, table string, rewrite func(string) (string, error)) (int64, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT rowid, note FROM "+table)
+	if err != nil {
+		return 0, err
 	}
-	if note.Tag != "" {
-		fmt.Println("removing notes with tags")
-		stmt := fmt.Sprintf("DELETE FROM notes WHERE tags LIKE '%s'", note.Tag)
-		statement, err := s.dbConn.Prepare(stmt) // Prepare SQL Statement
+	defer rows.Close()
+
+	changed := map[int64]string{}
+	for rows.Next() {
+		var rowid int64
+		var text string
+		err := rows.Scan(&rowid, &text)
 		if err != nil {
-			return err
+			return 0, err
+		}
+		result, err := rewrite(text)
+		if err != nil {
+			return 0, err
+		}
+		if result != text {
+			changed[rowid] = result
 		}
-		statement.Exec() // Execute SQL Statements
-		return nil
 	}
-	return nil
+	err = rows.Err()
+	if err != nil {
+		return 0, err
+	}
+	rows.Close()
+
+	for rowid, text := range changed {
+		_, err = tx.ExecContext(ctx, "UPDATE "+table+" SET note = ? WHERE rowid = ?", text, rowid)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(changed)), nil
 }
 
 func createDatabase(dbFile string) error {
-	file, err := os.Create(dbFile) // Create SQLite file
+	file, err := os.OpenFile(dbFile, os.O_RDWR|os.O_CREATE, 0o600) // Create SQLite file, keeping existing notes
 	if err != nil {
 		return err
 	}
 	file.Close()
-	log.Println("sqlite-database.db created")
+	log.Printf("%s created", dbFile)
 	return nil
 }
 
-func createTable(dbConn *sql.DB) error {
-	createNotesTableSQL := `CREATE TABLE IF NOT EXISTS notes
-		(id INTEGER PRIMARY KEY AUTOINCREMENT,
-		note TEXT,
-		tags TEXT);` // SQL Statement for Create Table
+func insertNote(ctx context.Context, dbConn *sql.DB, value string, tags []string) (int64, error) {
+	log.Println("Inserting notes record ...")
 
-	log.Println("Create Notes table...")
-	statement, err := dbConn.Prepare(createNotesTableSQL) // Prepare SQL Statement
+	tx, err := dbConn.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	statement.Exec() // Execute SQL Statements
-	log.Println("notes table created")
-	return nil
+	defer tx.Rollback()
+
+	stamp := formatTime(now())
+	res, err := tx.ExecContext(ctx, "INSERT INTO notes (note, uuid, created_at, updated_at) VALUES (?, ?, ?, ?)", value, newUUID(), stamp, stamp)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	err = linkTags(ctx, tx, id, tags)
+	if err != nil {
+		return 0, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
 }
 
-func insertNote(dbConn *sql.DB, value string, tags []string) error {
-	log.Println("Inserting notes record ...")
+func updateNote(ctx context.Context, dbConn *sql.DB, note model.Note) error {
+	log.Println("Updating notes record ...")
 
-	tx, err := dbConn.Begin()
+	tx, err := dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = recordRevision(ctx, tx, note.Id)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE notes SET note = ?, updated_at = ? WHERE id = ?", note.Value, formatTime(now()), note.Id)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM note_tags WHERE note_id = ?", note.Id)
 	if err != nil {
 		return err
 	}
-	stmt, err := tx.Prepare("INSERT INTO notes (note, tags) VALUES (?, ?)")
+	err = linkTags(ctx, tx, note.Id, note.Tags)
 	if err != nil {
 		return err
 	}
-	defer stmt.Close()
+	return tx.Commit()
+}
+
+// linkTags attaches tags to the note, creating the tags that do not exist
+// yet.
+func linkTags(ctx context.Context, tx *sql.Tx, noteId int64, tags []string) error {
 	for _, tag := range tags {
-		_, err = stmt.Exec(value, tag)
+		_, err := tx.ExecContext(ctx, "INSERT OR IGNORE INTO tags (name) VALUES (?)", tag)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, "INSERT OR IGNORE INTO note_tags (note_id, tag_id) SELECT ?, id FROM tags WHERE name = ?", noteId, tag)
 		if err != nil {
 			return err
 		}
 	}
-	err = tx.Commit()
+	return nil
+}
+
+// querier runs queries on a database or inside a transaction.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func queryNotes(ctx context.Context, q querier, stmt string, args ...interface{}) ([]model.Note, error) {
+	rows, err := q.QueryContext(ctx, stmt, args...)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	defer rows.Close()
+	return scanNotes(rows)
+}
+
+func scanNotes(rows *sql.Rows) ([]model.Note, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var result []model.Note
+	for rows.Next() {
+		var note model.Note
+		var tags, createdAt, updatedAt, deletedAt string
+		dest := []interface{}{&note.Id, &note.Value, &tags, &createdAt, &updatedAt, &deletedAt, &note.UUID}
+		if len(columns) > len(dest) {
+			dest = append(dest, &note.Snippet)
+		}
+		err := rows.Scan(dest...)
+		if err != nil {
+			return nil, err
+		}
+		note.Tags, err = decodeTags(tags)
+		if err != nil {
+			return nil, err
+		}
+		note.CreatedAt, err = parseTime(createdAt)
+		if err != nil {
+			return nil, err
+		}
+		note.UpdatedAt, err = parseTime(updatedAt)
+		if err != nil {
+			return nil, err
+		}
+		note.DeletedAt, err = parseTime(deletedAt)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, note)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// decodeTags decodes the JSON array of tag names built by noteTagsSQL and
+// kept in note_revisions.tags.
+func decodeTags(tags string) ([]string, error) {
+	var result []string
+	err := json.Unmarshal([]byte(tags), &result)
+	if err != nil {
+		return nil, fmt.Errorf("decoding tags %q: %w", tags, err)
+	}
+	if len(result) == 0 {
+		return nil, nil
+	}
+	return result, nil
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
+
+// formatStoredTime formats t like formatTime, leaving zero times empty.
+func formatStoredTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return formatTime(t)
+}
+
+// parseTime reads a timestamp written by formatTime. Empty values are
+// returned as the zero time.
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(timeLayout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q: %w", value, err)
+	}
+	return t, nil
 }
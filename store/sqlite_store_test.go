@@ -1,9 +1,15 @@
 package store
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	sqlmock "github.com/DATA-DOG/go-sqlmock"
 	"github.com/iamunni/hugnin/model"
@@ -21,11 +27,24 @@ func newMockStore(t *testing.T) *mockStore {
 	}
 }
 
+const testStamp = "2024-01-02T03:04:05.000Z"
+
+var testTime = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
 type mockStore struct {
 	dbConn *sql.DB
 	mock   sqlmock.Sqlmock
 }
 
+// jsonTags encodes tags the way noteTagsSQL returns them.
+func jsonTags(t *testing.T, tags []string) string {
+	data, err := json.Marshal(append([]string{}, tags...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
 func TestSQLiteStore_Write(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -63,20 +82,28 @@ func TestSQLiteStore_Write(t *testing.T) {
 				dbConn: mockStoreInstance.dbConn,
 			}
 			mockStoreInstance.mock.ExpectBegin()
-			mockStoreInstance.mock.ExpectPrepare("INSERT INTO notes")
+			mockStoreInstance.mock.ExpectExec("INSERT INTO notes").WithArgs(tt.value, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(7, 1))
 			for _, tag := range tt.tags {
-				mockStoreInstance.mock.ExpectExec("INSERT INTO notes").WithArgs(tt.value, tag).WillReturnResult(sqlmock.NewResult(1, 1))
+				mockStoreInstance.mock.ExpectExec("INSERT OR IGNORE INTO tags").WithArgs(tag).WillReturnResult(sqlmock.NewResult(1, 1))
+				mockStoreInstance.mock.ExpectExec("INSERT OR IGNORE INTO note_tags").WithArgs(7, tag).WillReturnResult(sqlmock.NewResult(1, 1))
 			}
 			mockStoreInstance.mock.ExpectCommit()
-			if err := s.Write(tt.value, tt.tags); (err != nil) != tt.wantErr {
+			id, err := s.Write(context.Background(), tt.value, tt.tags)
+			if (err != nil) != tt.wantErr {
 				t.Errorf("SQLiteStore.Write() error = %v, wantErr %v", err, tt.wantErr)
 			}
+			if id != 7 {
+				t.Errorf("SQLiteStore.Write() id = %v, want %v", id, 7)
+			}
+			if err := mockStoreInstance.mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unfulfilled expectations: %s", err)
+			}
 		})
 	}
 }
 
 func TestSQLiteWriter_Init(t *testing.T) {
-	dbFile := "sqlite-database-test.db"
+	dbFile := filepath.Join(t.TempDir(), "sqlite-database-test.db")
 	tests := []struct {
 		name    string
 		wantErr bool
@@ -92,9 +119,50 @@ func TestSQLiteWriter_Init(t *testing.T) {
 			s := &SQLiteStore{
 				dbConn: mockStoreInstance.dbConn,
 			}
-			mockStoreInstance.mock.ExpectPrepare("CREATE TABLE IF NOT EXISTS notes")
-			mockStoreInstance.mock.ExpectExec("CREATE TABLE IF NOT EXISTS notes").WillReturnResult(sqlmock.NewResult(1, 1))
-			if err := s.Init(dbFile); (err != nil) != tt.wantErr {
+			mockStoreInstance.mock.ExpectQuery("PRAGMA user_version").WillReturnRows(sqlmock.NewRows([]string{"user_version"}).AddRow(0))
+			mockStoreInstance.mock.ExpectBegin()
+			mockStoreInstance.mock.ExpectQuery("SELECT name FROM pragma_table_info").WillReturnRows(sqlmock.NewRows([]string{"name"}))
+			mockStoreInstance.mock.ExpectExec("CREATE TABLE IF NOT EXISTS notes").WillReturnResult(sqlmock.NewResult(0, 0))
+			mockStoreInstance.mock.ExpectExec("CREATE TABLE IF NOT EXISTS tags").WillReturnResult(sqlmock.NewResult(0, 0))
+			mockStoreInstance.mock.ExpectExec("CREATE TABLE IF NOT EXISTS note_tags").WillReturnResult(sqlmock.NewResult(0, 0))
+			mockStoreInstance.mock.ExpectExec("PRAGMA user_version = 1").WillReturnResult(sqlmock.NewResult(0, 0))
+			mockStoreInstance.mock.ExpectCommit()
+			mockStoreInstance.mock.ExpectBegin()
+			mockStoreInstance.mock.ExpectExec("ALTER TABLE notes ADD COLUMN created_at").WillReturnResult(sqlmock.NewResult(0, 0))
+			mockStoreInstance.mock.ExpectExec("ALTER TABLE notes ADD COLUMN updated_at").WillReturnResult(sqlmock.NewResult(0, 0))
+			mockStoreInstance.mock.ExpectExec("UPDATE notes SET created_at").WillReturnResult(sqlmock.NewResult(0, 0))
+			mockStoreInstance.mock.ExpectExec("CREATE INDEX IF NOT EXISTS notes_created_at").WillReturnResult(sqlmock.NewResult(0, 0))
+			mockStoreInstance.mock.ExpectExec("PRAGMA user_version = 2").WillReturnResult(sqlmock.NewResult(0, 0))
+			mockStoreInstance.mock.ExpectCommit()
+			mockStoreInstance.mock.ExpectBegin()
+			mockStoreInstance.mock.ExpectQuery("SELECT sqlite_compileoption_used").WillReturnRows(sqlmock.NewRows([]string{"used"}).AddRow(false))
+			mockStoreInstance.mock.ExpectExec("PRAGMA user_version = 3").WillReturnResult(sqlmock.NewResult(0, 0))
+			mockStoreInstance.mock.ExpectCommit()
+			mockStoreInstance.mock.ExpectBegin()
+			mockStoreInstance.mock.ExpectExec("ALTER TABLE notes ADD COLUMN deleted_at").WillReturnResult(sqlmock.NewResult(0, 0))
+			mockStoreInstance.mock.ExpectExec("CREATE INDEX IF NOT EXISTS notes_deleted_at").WillReturnResult(sqlmock.NewResult(0, 0))
+			mockStoreInstance.mock.ExpectExec("PRAGMA user_version = 4").WillReturnResult(sqlmock.NewResult(0, 0))
+			mockStoreInstance.mock.ExpectCommit()
+			mockStoreInstance.mock.ExpectBegin()
+			mockStoreInstance.mock.ExpectExec("CREATE TABLE IF NOT EXISTS note_revisions").WillReturnResult(sqlmock.NewResult(0, 0))
+			mockStoreInstance.mock.ExpectExec("PRAGMA user_version = 5").WillReturnResult(sqlmock.NewResult(0, 0))
+			mockStoreInstance.mock.ExpectCommit()
+			mockStoreInstance.mock.ExpectBegin()
+			mockStoreInstance.mock.ExpectExec("ALTER TABLE notes ADD COLUMN uuid").WillReturnResult(sqlmock.NewResult(0, 0))
+			mockStoreInstance.mock.ExpectQuery("SELECT id FROM notes").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			mockStoreInstance.mock.ExpectExec("CREATE UNIQUE INDEX IF NOT EXISTS notes_uuid").WillReturnResult(sqlmock.NewResult(0, 0))
+			mockStoreInstance.mock.ExpectExec("CREATE TABLE IF NOT EXISTS tombstones").WillReturnResult(sqlmock.NewResult(0, 0))
+			mockStoreInstance.mock.ExpectExec("PRAGMA user_version = 6").WillReturnResult(sqlmock.NewResult(0, 0))
+			mockStoreInstance.mock.ExpectCommit()
+			mockStoreInstance.mock.ExpectBegin()
+			mockStoreInstance.mock.ExpectExec("CREATE TABLE IF NOT EXISTS saved_searches").WillReturnResult(sqlmock.NewResult(0, 0))
+			mockStoreInstance.mock.ExpectExec("PRAGMA user_version = 7").WillReturnResult(sqlmock.NewResult(0, 0))
+			mockStoreInstance.mock.ExpectCommit()
+			mockStoreInstance.mock.ExpectBegin()
+			mockStoreInstance.mock.ExpectQuery("SELECT rowid, tags FROM note_revisions").WillReturnRows(sqlmock.NewRows([]string{"rowid", "tags"}))
+			mockStoreInstance.mock.ExpectExec("PRAGMA user_version = 8").WillReturnResult(sqlmock.NewResult(0, 0))
+			mockStoreInstance.mock.ExpectCommit()
+			if err := s.Init(context.Background(), dbFile); (err != nil) != tt.wantErr {
 				t.Errorf("SQLiteWriter.Init() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
@@ -113,19 +181,25 @@ func TestSQLiteStore_ReadWithoutAnyFilter(t *testing.T) {
 			note: model.Note{},
 			want: []model.Note{
 				{
-					Id:    1,
-					Value: "note1",
-					Tag:   "tag1",
+					Id:        1,
+					Value:     "note1",
+					Tags:      []string{"tag1"},
+					CreatedAt: testTime,
+					UpdatedAt: testTime,
 				},
 				{
-					Id:    2,
-					Value: "note2",
-					Tag:   "tag1,tag2",
+					Id:        2,
+					Value:     "note2",
+					Tags:      []string{"tag1", "tag2"},
+					CreatedAt: testTime,
+					UpdatedAt: testTime,
 				},
 				{
-					Id:    3,
-					Value: "note2",
-					Tag:   "tag1,tag3",
+					Id:        3,
+					Value:     "note2",
+					Tags:      []string{"tag1", "tag3"},
+					CreatedAt: testTime,
+					UpdatedAt: testTime,
 				},
 			},
 			wantErr: false,
@@ -134,15 +208,15 @@ func TestSQLiteStore_ReadWithoutAnyFilter(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockStoreInstance := newMockStore(t)
-			rows := sqlmock.NewRows([]string{"id", "note", "tags"}).
-				AddRow(1, "note1", "tag1").
-				AddRow(2, "note2", "tag1,tag2").
-				AddRow(3, "note2", "tag1,tag3")
-			mockStoreInstance.mock.ExpectQuery("SELECT (.+) FROM notes;$").WillReturnRows(rows)
+			rows := sqlmock.NewRows([]string{"id", "note", "tags", "created_at", "updated_at", "deleted_at", "uuid"}).
+				AddRow(1, "note1", `["tag1"]`, testStamp, testStamp, "", "").
+				AddRow(2, "note2", `["tag1","tag2"]`, testStamp, testStamp, "", "").
+				AddRow(3, "note2", `["tag1","tag3"]`, testStamp, testStamp, "", "")
+			mockStoreInstance.mock.ExpectQuery("SELECT (.+) FROM notes n WHERE (.+) ORDER BY n.id;$").WillReturnRows(rows)
 			s := &SQLiteStore{
 				dbConn: mockStoreInstance.dbConn,
 			}
-			got, err := s.Read(tt.note)
+			got, err := s.Read(context.Background(), tt.note, model.DateRange{})
 			if (err != nil) != tt.wantErr {
 				t.Errorf("SQLiteStore.Read() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -168,8 +242,10 @@ func TestSQLiteStore_ReadWithNoteFilter(t *testing.T) {
 			},
 			want: []model.Note{
 				{
-					Id:    1,
-					Value: "note1",
+					Id:        1,
+					Value:     "note1",
+					CreatedAt: testTime,
+					UpdatedAt: testTime,
 				},
 			},
 			wantErr: false,
@@ -178,13 +254,13 @@ func TestSQLiteStore_ReadWithNoteFilter(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockStoreInstance := newMockStore(t)
-			rows := sqlmock.NewRows([]string{"id", "note", "tags"}).
-				AddRow(1, "note1", "")
-			mockStoreInstance.mock.ExpectQuery("^SELECT (.+) FROM notes WHERE note LIKE (.+');$").WillReturnRows(rows)
+			rows := sqlmock.NewRows([]string{"id", "note", "tags", "created_at", "updated_at", "deleted_at", "uuid"}).
+				AddRow(1, "note1", "[]", testStamp, testStamp, "", "")
+			mockStoreInstance.mock.ExpectQuery("^SELECT (.+) FROM notes n WHERE n.deleted_at = '' AND n.note LIKE \\? ORDER BY n.id;$").WithArgs("note1").WillReturnRows(rows)
 			s := &SQLiteStore{
 				dbConn: mockStoreInstance.dbConn,
 			}
-			got, err := s.Read(tt.note)
+			got, err := s.Read(context.Background(), tt.note, model.DateRange{})
 			if (err != nil) != tt.wantErr {
 				t.Errorf("SQLiteStore.Read() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -206,12 +282,15 @@ func TestSQLiteStore_ReadWithTagFilter(t *testing.T) {
 		{
 			name: "read with tag filter",
 			note: model.Note{
-				Tag: "tag1",
+				Tags: []string{"tag1"},
 			},
 			want: []model.Note{
 				{
-					Id:  1,
-					Tag: "tag1",
+					Id:        1,
+					Value:     "note1",
+					Tags:      []string{"tag1"},
+					CreatedAt: testTime,
+					UpdatedAt: testTime,
 				},
 			},
 			wantErr: false,
@@ -220,13 +299,13 @@ func TestSQLiteStore_ReadWithTagFilter(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockStoreInstance := newMockStore(t)
-			rows := sqlmock.NewRows([]string{"id", "note", "tags"}).
-				AddRow(1, "", "tag1")
-			mockStoreInstance.mock.ExpectQuery("^SELECT (.+) FROM notes WHERE tags IN ((.+));").WillReturnRows(rows)
+			rows := sqlmock.NewRows([]string{"id", "note", "tags", "created_at", "updated_at", "deleted_at", "uuid"}).
+				AddRow(1, "note1", `["tag1"]`, testStamp, testStamp, "", "")
+			mockStoreInstance.mock.ExpectQuery("^SELECT (.+) FROM notes n WHERE n.deleted_at = '' AND n.id IN \\(SELECT (.+) WHERE fg.name = \\? OR \\(fg.name > \\? AND fg.name < \\?\\)\\)").WithArgs("tag1", "tag1/", "tag10").WillReturnRows(rows)
 			s := &SQLiteStore{
 				dbConn: mockStoreInstance.dbConn,
 			}
-			got, err := s.Read(tt.note)
+			got, err := s.Read(context.Background(), tt.note, model.DateRange{})
 			if (err != nil) != tt.wantErr {
 				t.Errorf("SQLiteStore.Read() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -249,13 +328,15 @@ func TestSQLiteStore_ReadWithNoteAndTagFilter(t *testing.T) {
 			name: "read with tag filter",
 			note: model.Note{
 				Value: "note1",
-				Tag:   "tag1",
+				Tags:  []string{"tag1", "tag2"},
 			},
 			want: []model.Note{
 				{
-					Id:    1,
-					Value: "note1",
-					Tag:   "tag1",
+					Id:        1,
+					Value:     "note1",
+					Tags:      []string{"tag1"},
+					CreatedAt: testTime,
+					UpdatedAt: testTime,
 				},
 			},
 			wantErr: false,
@@ -264,13 +345,13 @@ func TestSQLiteStore_ReadWithNoteAndTagFilter(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockStoreInstance := newMockStore(t)
-			rows := sqlmock.NewRows([]string{"id", "note", "tags"}).
-				AddRow(1, "note1", "tag1")
-			mockStoreInstance.mock.ExpectQuery("^SELECT (.+) FROM notes WHERE note LIKE (.+) AND tags IN ((.+));").WillReturnRows(rows)
+			rows := sqlmock.NewRows([]string{"id", "note", "tags", "created_at", "updated_at", "deleted_at", "uuid"}).
+				AddRow(1, "note1", `["tag1"]`, testStamp, testStamp, "", "")
+			mockStoreInstance.mock.ExpectQuery("^SELECT (.+) FROM notes n WHERE n.deleted_at = '' AND n.note LIKE \\? AND n.id IN \\(SELECT (.+) WHERE fg.name = \\? OR (.+) OR \\(fg.name > \\? AND fg.name < \\?\\)\\)").WithArgs("note1", "tag1", "tag1/", "tag10", "tag2", "tag2/", "tag20").WillReturnRows(rows)
 			s := &SQLiteStore{
 				dbConn: mockStoreInstance.dbConn,
 			}
-			got, err := s.Read(tt.note)
+			got, err := s.Read(context.Background(), tt.note, model.DateRange{})
 			if (err != nil) != tt.wantErr {
 				t.Errorf("SQLiteStore.Read() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -299,12 +380,11 @@ func TestSQLiteStore_DeleteAll(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockStoreInstance := newMockStore(t)
-			mockStoreInstance.mock.ExpectPrepare("DELETE FROM notes")
-			mockStoreInstance.mock.ExpectExec("DELETE FROM notes")
+			mockStoreInstance.mock.ExpectExec("^UPDATE notes AS n SET deleted_at = \\? WHERE n.deleted_at = '';$").WithArgs(sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 3))
 			s := &SQLiteStore{
 				dbConn: mockStoreInstance.dbConn,
 			}
-			if err := s.Delete(tt.note); (err != nil) != tt.wantErr {
+			if _, err := s.Delete(context.Background(), tt.note); (err != nil) != tt.wantErr {
 				t.Errorf("SQLiteStore.Delete() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
@@ -320,7 +400,7 @@ func TestSQLiteStore_DeleteBasedOnTag(t *testing.T) {
 		{
 			name: "delete notes based on Tag",
 			note: model.Note{
-				Tag: "tag1",
+				Tags: []string{"tag1"},
 			},
 			wantErr: false,
 		},
@@ -328,12 +408,11 @@ func TestSQLiteStore_DeleteBasedOnTag(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockStoreInstance := newMockStore(t)
-			mockStoreInstance.mock.ExpectPrepare("DELETE FROM notes WHERE tags LIKE (.+')$")
-			mockStoreInstance.mock.ExpectExec("DELETE FROM notes WHERE tags LIKE (.+')$")
+			mockStoreInstance.mock.ExpectExec("^UPDATE notes AS n SET deleted_at = \\? WHERE n.deleted_at = '' AND n.id IN \\(SELECT (.+) WHERE fg.name = \\? OR \\(fg.name > \\? AND fg.name < \\?\\)\\);$").WithArgs(sqlmock.AnyArg(), "tag1", "tag1/", "tag10").WillReturnResult(sqlmock.NewResult(0, 1))
 			s := &SQLiteStore{
 				dbConn: mockStoreInstance.dbConn,
 			}
-			if err := s.Delete(tt.note); (err != nil) != tt.wantErr {
+			if _, err := s.Delete(context.Background(), tt.note); (err != nil) != tt.wantErr {
 				t.Errorf("SQLiteStore.Delete() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
@@ -357,12 +436,11 @@ func TestSQLiteStore_DeleteBasedOnId(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockStoreInstance := newMockStore(t)
-			mockStoreInstance.mock.ExpectPrepare("DELETE FROM notes WHERE Id=(.+)$")
-			mockStoreInstance.mock.ExpectExec("DELETE FROM notes WHERE Id=(.+)$")
+			mockStoreInstance.mock.ExpectExec("^UPDATE notes AS n SET deleted_at = \\? WHERE n.deleted_at = '' AND n.id = \\?;$").WithArgs(sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(0, 1))
 			s := &SQLiteStore{
 				dbConn: mockStoreInstance.dbConn,
 			}
-			if err := s.Delete(tt.note); (err != nil) != tt.wantErr {
+			if _, err := s.Delete(context.Background(), tt.note); (err != nil) != tt.wantErr {
 				t.Errorf("SQLiteStore.Delete() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
@@ -381,9 +459,12 @@ func TestSQLiteStore_SearchAvailableNoteValue(t *testing.T) {
 			keyword: "test",
 			want: []model.Note{
 				{
-					Id:    1,
-					Value: "testnote",
-					Tag:   "tag1",
+					Id:        1,
+					Value:     "testnote",
+					Tags:      []string{"tag1"},
+					CreatedAt: testTime,
+					UpdatedAt: testTime,
+					Snippet:   "[test]note",
 				},
 			},
 		},
@@ -392,9 +473,12 @@ func TestSQLiteStore_SearchAvailableNoteValue(t *testing.T) {
 			keyword: "2",
 			want: []model.Note{
 				{
-					Id:    2,
-					Value: "note2",
-					Tag:   "tag2",
+					Id:        2,
+					Value:     "note2",
+					Tags:      []string{"tag2"},
+					CreatedAt: testTime,
+					UpdatedAt: testTime,
+					Snippet:   "note[2]",
 				},
 			},
 		},
@@ -403,14 +487,18 @@ func TestSQLiteStore_SearchAvailableNoteValue(t *testing.T) {
 			keyword: "tag",
 			want: []model.Note{
 				{
-					Id:    1,
-					Value: "testnote",
-					Tag:   "tag1",
+					Id:        1,
+					Value:     "testnote",
+					Tags:      []string{"tag1"},
+					CreatedAt: testTime,
+					UpdatedAt: testTime,
 				},
 				{
-					Id:    2,
-					Value: "note2",
-					Tag:   "tag2",
+					Id:        2,
+					Value:     "note2",
+					Tags:      []string{"tag2"},
+					CreatedAt: testTime,
+					UpdatedAt: testTime,
 				},
 			},
 		},
@@ -418,14 +506,18 @@ func TestSQLiteStore_SearchAvailableNoteValue(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockStoreInstance := newMockStore(t)
-			rows := mockStoreInstance.mock.NewRows([]string{"id", "note", "tags"}).
-				AddRow(1, "testnote", "tag1").
-				AddRow(2, "note2", "tag2")
-			mockStoreInstance.mock.ExpectQuery("SELECT (\\*+) FROM notes").WillReturnRows(rows)
+			rows := mockStoreInstance.mock.NewRows([]string{"id", "note", "tags", "created_at", "updated_at", "deleted_at", "uuid"})
+			for _, note := range tt.want {
+				rows.AddRow(note.Id, note.Value, jsonTags(t, note.Tags), testStamp, testStamp, "", "")
+			}
+			mockStoreInstance.mock.ExpectQuery("SELECT sqlite_compileoption_used\\('ENABLE_FTS5'\\)").WillReturnRows(sqlmock.NewRows([]string{"ready"}).AddRow(false))
+			mockStoreInstance.mock.ExpectQuery("^SELECT (.+) FROM notes n WHERE n.deleted_at = '' AND \\(n.note LIKE \\? ESCAPE (.+) OR (.+)\\)").
+				WithArgs("%"+tt.keyword+"%", "%"+tt.keyword+"%").
+				WillReturnRows(rows)
 			s := &SQLiteStore{
 				dbConn: mockStoreInstance.dbConn,
 			}
-			got, err := s.Search(tt.keyword)
+			got, err := s.Search(context.Background(), tt.keyword, model.DateRange{})
 			if (err != nil) != tt.wantErr {
 				t.Errorf("SQLiteStore.Search() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -436,3 +528,411 @@ func TestSQLiteStore_SearchAvailableNoteValue(t *testing.T) {
 		})
 	}
 }
+
+func TestSQLiteStore_Update(t *testing.T) {
+	tests := []struct {
+		name     string
+		note     model.Note
+		affected int64
+		wantErr  bool
+	}{
+		{
+			name:     "update text and tags",
+			note:     model.Note{Id: 3, Value: "new value", Tags: []string{"tag1", "tag2"}},
+			affected: 1,
+			wantErr:  false,
+		},
+		{
+			name:     "unknown id",
+			note:     model.Note{Id: 42, Value: "new value"},
+			affected: 0,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStoreInstance := newMockStore(t)
+			s := &SQLiteStore{
+				dbConn: mockStoreInstance.dbConn,
+			}
+			mockStoreInstance.mock.ExpectBegin()
+			mockStoreInstance.mock.ExpectExec("INSERT INTO note_revisions (.+) FROM notes n WHERE n.id = \\? AND n.deleted_at = ''").WithArgs(tt.note.Id).WillReturnResult(sqlmock.NewResult(0, tt.affected))
+			if tt.affected == 0 {
+				mockStoreInstance.mock.ExpectRollback()
+			} else {
+				mockStoreInstance.mock.ExpectExec("UPDATE notes SET note = \\?, updated_at = \\? WHERE id = \\?").WithArgs(tt.note.Value, sqlmock.AnyArg(), tt.note.Id).WillReturnResult(sqlmock.NewResult(0, 1))
+				mockStoreInstance.mock.ExpectExec("DELETE FROM note_tags WHERE note_id = \\?").WithArgs(tt.note.Id).WillReturnResult(sqlmock.NewResult(0, 1))
+				for _, tag := range tt.note.Tags {
+					mockStoreInstance.mock.ExpectExec("INSERT OR IGNORE INTO tags").WithArgs(tag).WillReturnResult(sqlmock.NewResult(1, 1))
+					mockStoreInstance.mock.ExpectExec("INSERT OR IGNORE INTO note_tags").WithArgs(tt.note.Id, tag).WillReturnResult(sqlmock.NewResult(1, 1))
+				}
+				mockStoreInstance.mock.ExpectCommit()
+			}
+			err := s.Update(context.Background(), tt.note)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SQLiteStore.Update() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !errors.Is(err, ErrNotFound) {
+				t.Errorf("SQLiteStore.Update() error = %v, want ErrNotFound", err)
+			}
+			if err := mockStoreInstance.mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestSQLiteStore_Tags(t *testing.T) {
+	mockStoreInstance := newMockStore(t)
+	s := &SQLiteStore{
+		dbConn: mockStoreInstance.dbConn,
+	}
+	rows := sqlmock.NewRows([]string{"name", "count", "last_used"}).
+		AddRow("db", 2, testStamp).
+		AddRow("unused", 0, "")
+	mockStoreInstance.mock.ExpectQuery("^SELECT t.name, COUNT\\(n.id\\), IFNULL\\(MAX\\(n.updated_at\\), ''\\) FROM tags t (.+) LEFT JOIN notes n ON n.id = nt.note_id AND n.deleted_at = '' GROUP BY t.id ORDER BY t.name$").WillReturnRows(rows)
+	got, err := s.Tags(context.Background())
+	if err != nil {
+		t.Fatalf("SQLiteStore.Tags() error = %v", err)
+	}
+	want := []model.Tag{{Name: "db", Notes: 2, LastUsed: testTime}, {Name: "unused", Notes: 0}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SQLiteStore.Tags() = %v, want %v", got, want)
+	}
+	if err := mockStoreInstance.mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled expectations: %s", err)
+	}
+}
+
+func TestSQLiteStore_Revisions(t *testing.T) {
+	tests := []struct {
+		name string
+		id   int64
+		want []model.Revision
+	}{
+		{
+			name: "note with revisions",
+			id:   3,
+			want: []model.Revision{
+				{NoteId: 3, Number: 1, Value: "first", CreatedAt: testTime},
+				{NoteId: 3, Number: 2, Value: "second", Tags: []string{"tag1", "tag2"}, CreatedAt: testTime},
+			},
+		},
+		{
+			name: "note without revisions",
+			id:   4,
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStoreInstance := newMockStore(t)
+			s := &SQLiteStore{
+				dbConn: mockStoreInstance.dbConn,
+			}
+			rows := sqlmock.NewRows([]string{"revision", "note", "tags", "created_at"})
+			for _, r := range tt.want {
+				rows.AddRow(r.Number, r.Value, jsonTags(t, r.Tags), testStamp)
+			}
+			mockStoreInstance.mock.ExpectQuery("^SELECT revision, note, tags, created_at FROM note_revisions WHERE note_id = \\? ORDER BY revision$").WithArgs(tt.id).WillReturnRows(rows)
+			got, err := s.Revisions(context.Background(), tt.id)
+			if err != nil {
+				t.Fatalf("SQLiteStore.Revisions() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SQLiteStore.Revisions() = %v, want %v", got, tt.want)
+			}
+			if err := mockStoreInstance.mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+// TestSQLiteStore_UpdateKeepsRevisions checks against a real database that
+// each update stores the version it replaces, tags included.
+func TestSQLiteStore_UpdateKeepsRevisions(t *testing.T) {
+	ctx := context.Background()
+	s := NewSQLiteStore(filepath.Join(t.TempDir(), "notes.db"))
+	if err := s.Open(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	id, err := s.Write(ctx, "first", []string{"a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, note := range []model.Note{
+		{Id: id, Value: "second", Tags: []string{"a", "b"}},
+		{Id: id, Value: "third"},
+	} {
+		if err := s.Update(ctx, note); err != nil {
+			t.Fatalf("SQLiteStore.Update() error = %v", err)
+		}
+	}
+
+	got, err := s.Revisions(ctx, id)
+	if err != nil {
+		t.Fatalf("SQLiteStore.Revisions() error = %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("SQLiteStore.Revisions() = %v, want 2 revisions", got)
+	}
+	if got[0].Number != 1 || got[0].Value != "first" || !reflect.DeepEqual(got[0].Tags, []string{"a"}) {
+		t.Errorf("revision 1 = %+v", got[0])
+	}
+	if got[1].Number != 2 || got[1].Value != "second" || !reflect.DeepEqual(got[1].Tags, []string{"a", "b"}) {
+		t.Errorf("revision 2 = %+v", got[1])
+	}
+
+	if _, err := s.Delete(ctx, model.Note{Id: id}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Update(ctx, model.Note{Id: id, Value: "trashed"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SQLiteStore.Update() of a trashed note error = %v, want ErrNotFound", err)
+	}
+	if _, err := s.Purge(ctx, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.Revisions(ctx, id); err != nil || len(got) != 0 {
+		t.Errorf("SQLiteStore.Revisions() after purge = %v, %v, want none", got, err)
+	}
+}
+
+func TestSQLiteStore_Lifecycle(t *testing.T) {
+	ctx := context.Background()
+	s := NewSQLiteStore(filepath.Join(t.TempDir(), "notes", "notes.db"))
+	if _, err := s.Read(ctx, model.Note{}, model.DateRange{}); err == nil {
+		t.Error("SQLiteStore.Read() before Open expected an error")
+	}
+	if err := s.Open(ctx); err != nil {
+		t.Fatalf("SQLiteStore.Open() error = %v", err)
+	}
+
+	for _, value := range []string{"first", "second"} {
+		if _, err := s.Write(ctx, value, []string{"work"}); err != nil {
+			t.Fatalf("SQLiteStore.Write() error = %v", err)
+		}
+	}
+	if deleted, err := s.Delete(ctx, model.Note{Id: 1}); err != nil || deleted != 1 {
+		t.Fatalf("SQLiteStore.Delete() = %d, %v, want 1", deleted, err)
+	}
+	got, err := s.Read(ctx, model.Note{}, model.DateRange{})
+	if err != nil {
+		t.Fatalf("SQLiteStore.Read() error = %v", err)
+	}
+	if len(got) != 1 || got[0].Value != "second" {
+		t.Errorf("SQLiteStore.Read() = %v, want only the second note", got)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("SQLiteStore.Close() error = %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("second SQLiteStore.Close() error = %v", err)
+	}
+	if _, err := s.Write(ctx, "third", nil); err == nil {
+		t.Error("SQLiteStore.Write() after Close expected an error")
+	}
+}
+
+func TestSQLiteStore_Trash(t *testing.T) {
+	ctx := context.Background()
+	s := NewSQLiteStore(filepath.Join(t.TempDir(), "notes.db")).(*SQLiteStore)
+	if err := s.Open(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	defer func() { now = time.Now }()
+
+	for _, value := range []string{"old", "recent", "kept"} {
+		if _, err := s.Write(ctx, value, []string{"work"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now = func() time.Time { return testTime }
+	if _, err := s.Delete(ctx, model.Note{Id: 1}); err != nil {
+		t.Fatal(err)
+	}
+	now = func() time.Time { return testTime.Add(48 * time.Hour) }
+	if _, err := s.Delete(ctx, model.Note{Id: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if deleted, err := s.Delete(ctx, model.Note{Id: 2}); err != nil || deleted != 0 {
+		t.Errorf("SQLiteStore.Delete() of a trashed note = %d, %v, want 0", deleted, err)
+	}
+
+	live, err := s.Read(ctx, model.Note{}, model.DateRange{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(live) != 1 || live[0].Id != 3 {
+		t.Errorf("SQLiteStore.Read() = %v, want only note 3", live)
+	}
+	trashed, err := s.Trashed(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trashed) != 2 || trashed[0].Id != 2 || trashed[1].Id != 1 || !trashed[1].DeletedAt.Equal(testTime) {
+		t.Errorf("SQLiteStore.Trashed() = %v, want notes 2 and 1", trashed)
+	}
+
+	purged, err := s.Purge(ctx, testTime.Add(24*time.Hour))
+	if err != nil || purged != 1 {
+		t.Errorf("SQLiteStore.Purge() = %d, %v, want 1", purged, err)
+	}
+	if err := s.Restore(ctx, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SQLiteStore.Restore() of a purged note error = %v, want ErrNotFound", err)
+	}
+	if err := s.Restore(ctx, 3); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SQLiteStore.Restore() of a live note error = %v, want ErrNotFound", err)
+	}
+	if err := s.Restore(ctx, 2); err != nil {
+		t.Fatalf("SQLiteStore.Restore() error = %v", err)
+	}
+	restored, err := s.Read(ctx, model.Note{Id: 2}, model.DateRange{})
+	if err != nil || len(restored) != 1 || restored[0].Value != "recent" || !reflect.DeepEqual(restored[0].Tags, []string{"work"}) {
+		t.Errorf("SQLiteStore.Read() after Restore = %v, %v", restored, err)
+	}
+
+	if _, err := s.Delete(ctx, model.Note{Id: -1}); err != nil {
+		t.Fatal(err)
+	}
+	if purged, err := s.Purge(ctx, time.Time{}); err != nil || purged != 2 {
+		t.Errorf("SQLiteStore.Purge() of the whole trash = %d, %v, want 2", purged, err)
+	}
+}
+
+func TestSQLiteStore_Rewrite(t *testing.T) {
+	ctx := context.Background()
+	s := NewSQLiteStore(filepath.Join(t.TempDir(), "notes.db"))
+	if err := s.Open(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for _, value := range []string{"secret one", "plain", "secret two"} {
+		if _, err := s.Write(ctx, value, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Update(ctx, model.Note{Id: 1, Value: "secret three"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Delete(ctx, model.Note{Id: 3}); err != nil {
+		t.Fatal(err)
+	}
+	before, err := s.Read(ctx, model.Note{Id: 1}, model.DateRange{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failure := errors.New("cannot rewrite")
+	rewritten, err := s.Rewrite(ctx, func(text string) (string, error) {
+		if text == "plain" {
+			return "", failure
+		}
+		return strings.ToUpper(text), nil
+	})
+	if !errors.Is(err, failure) || rewritten != 0 {
+		t.Errorf("SQLiteStore.Rewrite() = %d, %v, want %v", rewritten, err, failure)
+	}
+	if notes, _ := s.Read(ctx, model.Note{Id: 1}, model.DateRange{}); notes[0].Value != "secret three" {
+		t.Errorf("SQLiteStore.Rewrite() kept %q after failing", notes[0].Value)
+	}
+
+	rewritten, err = s.Rewrite(ctx, func(text string) (string, error) {
+		if strings.HasPrefix(text, "secret") {
+			return strings.ToUpper(text), nil
+		}
+		return text, nil
+	})
+	if err != nil || rewritten != 3 {
+		t.Errorf("SQLiteStore.Rewrite() = %d, %v, want 3", rewritten, err)
+	}
+	after, err := s.Read(ctx, model.Note{Id: 1}, model.DateRange{})
+	if err != nil || after[0].Value != "SECRET THREE" || !after[0].UpdatedAt.Equal(before[0].UpdatedAt) {
+		t.Errorf("SQLiteStore.Read() after Rewrite = %v, %v", after, err)
+	}
+	revisions, err := s.Revisions(ctx, 1)
+	if err != nil || len(revisions) != 1 || revisions[0].Value != "SECRET ONE" {
+		t.Errorf("SQLiteStore.Revisions() after Rewrite = %v, %v", revisions, err)
+	}
+	trashed, err := s.Trashed(ctx)
+	if err != nil || len(trashed) != 1 || trashed[0].Value != "SECRET TWO" {
+		t.Errorf("SQLiteStore.Trashed() after Rewrite = %v, %v", trashed, err)
+	}
+}
+
+func TestSQLiteStore_Import(t *testing.T) {
+	ctx := context.Background()
+	s := NewSQLiteStore(filepath.Join(t.TempDir(), "notes.db"))
+	if err := s.Open(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, err := s.Write(ctx, "existing", []string{"b", "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	notes := []model.Note{
+		{Id: 7, UUID: "uuid-7", Value: "existing", Tags: []string{"a", "b"}, CreatedAt: testTime, UpdatedAt: testTime},
+		{Id: 9, UUID: "uuid-9", Value: "new", Tags: []string{"c"}, CreatedAt: testTime, UpdatedAt: testTime, DeletedAt: testTime},
+	}
+	imported, err := s.Import(ctx, notes, ImportMerge)
+	if err != nil || imported != 1 {
+		t.Fatalf("SQLiteStore.Import(merge) = %d, %v, want 1", imported, err)
+	}
+	trashed, err := s.Trashed(ctx)
+	if err != nil || len(trashed) != 1 || trashed[0].Id != 2 || trashed[0].Value != "new" {
+		t.Errorf("SQLiteStore.Trashed() after merge = %v, %v, want the new note as note 2", trashed, err)
+	}
+	if imported, err := s.Import(ctx, notes, ImportMerge); err != nil || imported != 0 {
+		t.Errorf("second SQLiteStore.Import(merge) = %d, %v, want 0", imported, err)
+	}
+
+	imported, err = s.Import(ctx, notes, ImportReplace)
+	if err != nil || imported != 2 {
+		t.Fatalf("SQLiteStore.Import(replace) = %d, %v, want 2", imported, err)
+	}
+	live, err := s.Read(ctx, model.Note{}, model.DateRange{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []model.Note{{Id: 7, UUID: "uuid-7", Value: "existing", Tags: []string{"a", "b"}, CreatedAt: testTime, UpdatedAt: testTime}}
+	if !reflect.DeepEqual(live, want) {
+		t.Errorf("SQLiteStore.Read() after replace = %v, want %v", live, want)
+	}
+
+	mixedIds := []model.Note{{Value: "no id"}, {Id: 1, Value: "one"}}
+	if imported, err := s.Import(ctx, mixedIds, ImportReplace); err != nil || imported != 2 {
+		t.Errorf("SQLiteStore.Import() with and without Ids = %d, %v, want 2", imported, err)
+	}
+	if err := s.Restore(ctx, 9); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("SQLiteStore.Import(replace) kept the trash: %v", err)
+	}
+	if _, err := s.Import(ctx, notes, ImportReplace); err != nil {
+		t.Fatal(err)
+	}
+
+	duplicateIds := []model.Note{{Id: 1, Value: "one"}, {Id: 1, Value: "two"}}
+	if _, err := s.Import(ctx, duplicateIds, ImportReplace); err == nil {
+		t.Error("SQLiteStore.Import() with duplicate Ids expected an error")
+	}
+	if live, err := s.Read(ctx, model.Note{}, model.DateRange{}); err != nil || !reflect.DeepEqual(live, want) {
+		t.Errorf("failed SQLiteStore.Import() changed the notes to %v, %v", live, err)
+	}
+}
+
+func TestContentHash(t *testing.T) {
+	a := ContentHash(model.Note{Id: 1, Value: "x", Tags: []string{"b", "a"}})
+	if b := ContentHash(model.Note{Id: 2, Value: "x", Tags: []string{"a", "b"}, CreatedAt: testTime}); a != b {
+		t.Errorf("ContentHash() differs for the same text and tags: %s, %s", a, b)
+	}
+	if b := ContentHash(model.Note{Value: "x", Tags: []string{"a"}}); a == b {
+		t.Error("ContentHash() ignores tags")
+	}
+	if b := ContentHash(model.Note{Value: "x\x00a", Tags: []string{"b"}}); a == b {
+		t.Error("ContentHash() mixes text and tags")
+	}
+}
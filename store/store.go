@@ -1,13 +1,91 @@
 package store
 
 import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/iamunni/hugnin/filter"
 	"github.com/iamunni/hugnin/model"
 )
 
+// ErrNotFound is returned when an operation targets a note Id that does
+// not exist.
+var ErrNotFound = errors.New("note not found")
+
+// Store persists notes. Implementations are opened once with Open, serve
+// any number of calls and are released with Close.
 type Store interface {
-	Init(string) error
-	Write(value string, tags []string) error
-	Read(note model.Note) ([]model.Note, error)
-	Delete(note model.Note) error
-	Search(keyword string) ([]model.Note, error)
+	Open(ctx context.Context) error
+	Close() error
+	Init(ctx context.Context, dbFile string) error
+	Write(ctx context.Context, value string, tags []string) (int64, error)
+	// Read returns the live notes selected by note and period. Tags select
+	// the notes carrying one of them or a tag below it in the hierarchy,
+	// as "work/oncall" is below "work".
+	Read(ctx context.Context, note model.Note, period model.DateRange) ([]model.Note, error)
+	// Update replaces a note in place and keeps its previous version as a
+	// revision.
+	Update(ctx context.Context, note model.Note) error
+	// Revisions returns the earlier versions of a note, oldest first.
+	Revisions(ctx context.Context, id int64) ([]model.Revision, error)
+	// Delete moves the selected notes to the trash and returns how many
+	// were moved.
+	Delete(ctx context.Context, note model.Note) (int64, error)
+	Search(ctx context.Context, keyword string, period model.DateRange) ([]model.Note, error)
+	// Find returns the live notes matching expr, a parsed query, and
+	// created inside period, ordered by Id. A nil expr matches every note.
+	Find(ctx context.Context, expr filter.Expr, period model.DateRange) ([]model.Note, error)
+	// Tags returns every tag with the number of live notes carrying it.
+	Tags(ctx context.Context) ([]model.Tag, error)
+	// MergeTags replaces the tags from with the tag into on every note
+	// carrying one of them, trashed notes included, and removes the tags
+	// from, in a single transaction. The replaced versions are kept as
+	// revisions. It returns how many notes changed, and ErrNotFound when
+	// one of the tags from does not exist.
+	MergeTags(ctx context.Context, from []string, into string) (int64, error)
+	// RenameTags gives each tag that is a key of renames the name it maps
+	// to, on every note carrying it, trashed notes included, merging it into
+	// an existing tag of that name, in a single transaction. Each changed
+	// note keeps one revision. It returns how many notes changed, and
+	// ErrNotFound when one of the tags does not exist.
+	RenameTags(ctx context.Context, renames map[string]string) (int64, error)
+	// PruneTags removes the tags no note carries and returns how many were
+	// removed.
+	PruneTags(ctx context.Context) (int64, error)
+	// SaveSearch stores search under its name, replacing the search saved
+	// under that name if any.
+	SaveSearch(ctx context.Context, search model.SavedSearch) error
+	// SavedSearches returns the saved searches ordered by name.
+	SavedSearches(ctx context.Context) ([]model.SavedSearch, error)
+	// DeleteSavedSearch removes the search saved under name, or returns
+	// ErrNotFound.
+	DeleteSavedSearch(ctx context.Context, name string) error
+	Trashed(ctx context.Context) ([]model.Note, error)
+	// Restore moves a trashed note back and sets its update time, or
+	// returns ErrNotFound.
+	Restore(ctx context.Context, id int64) error
+	// Purge permanently removes the notes trashed before cutoff, leaving
+	// a tombstone for each; a zero cutoff empties the trash.
+	Purge(ctx context.Context, cutoff time.Time) (int64, error)
+	// Import writes notes in a single transaction and returns how many
+	// were added. See ImportMode.
+	Import(ctx context.Context, notes []model.Note, mode ImportMode) (int64, error)
+	// Rewrite passes the text of every note and revision, trashed ones
+	// included, through rewrite and stores the results in a single
+	// transaction, without recording revisions. It returns how many texts
+	// changed.
+	Rewrite(ctx context.Context, rewrite func(text string) (string, error)) (int64, error)
+	// Put stores notes by UUID in a single transaction: a note whose UUID
+	// is stored replaces that note, keeping its previous version as a
+	// revision when the text or tags differ, and the others are added
+	// under new Ids. Timestamps and trash state are kept as given.
+	Put(ctx context.Context, notes []model.Note) error
+	// Forget permanently removes the notes with the UUIDs of tombstones,
+	// trashed or not, and keeps the tombstones. It returns how many notes
+	// were removed.
+	Forget(ctx context.Context, tombstones []model.Tombstone) (int64, error)
+	// Tombstones returns the tombstones left by Purge and Forget, ordered
+	// by UUID.
+	Tombstones(ctx context.Context) ([]model.Tombstone, error)
 }
@@ -0,0 +1,187 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"fmt"
+
+	"github.com/iamunni/hugnin/model"
+)
+
+// Every note carries a random UUID that stays the same in every store the
+// note is synced or imported into, while its Id is only unique within one
+// store. Purging a note leaves a tombstone with its UUID, so a sync can
+// tell a note removed here from a note never seen here.
+
+// newUUID returns a random version 4 UUID. It is replaced in tests to get
+// stable UUIDs.
+var newUUID = randomUUID
+
+// NewUUID returns a new note UUID, for notes that are given to Put.
+func NewUUID() string {
+	return newUUID()
+}
+
+func randomUUID() string {
+	var b [16]byte
+	_, err := rand.Read(b[:])
+	if err != nil {
+		panic(fmt.Sprintf("reading random bytes: %v", err))
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// addNoteUUIDs is the schema migration that gives every note a UUID and
+// adds the tombstones table.
+func addNoteUUIDs(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `ALTER TABLE notes ADD COLUMN uuid TEXT NOT NULL DEFAULT ''`)
+	if err != nil {
+		return err
+	}
+	rows, err := tx.QueryContext(ctx, "SELECT id FROM notes")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		err = rows.Scan(&id)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+	err = rows.Err()
+	if err != nil {
+		return err
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		_, err = tx.ExecContext(ctx, "UPDATE notes SET uuid = ? WHERE id = ?", newUUID(), id)
+		if err != nil {
+			return err
+		}
+	}
+	statements := []string{
+		`CREATE UNIQUE INDEX IF NOT EXISTS notes_uuid ON notes (uuid)`,
+		`CREATE TABLE IF NOT EXISTS tombstones
+			(uuid TEXT PRIMARY KEY,
+			deleted_at TEXT NOT NULL);`,
+	}
+	for _, stmt := range statements {
+		_, err := tx.ExecContext(ctx, stmt)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// noteUUIDs returns the UUIDs of every stored note, trashed ones included.
+func noteUUIDs(ctx context.Context, tx *sql.Tx) (map[string]bool, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT uuid FROM notes")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	uuids := map[string]bool{}
+	for rows.Next() {
+		var uuid string
+		err = rows.Scan(&uuid)
+		if err != nil {
+			return nil, err
+		}
+		uuids[uuid] = true
+	}
+	return uuids, rows.Err()
+}
+
+// putNote stores note under its UUID. A stored note with that UUID is
+// replaced, its previous version becoming a revision when the text or tags
+// change; otherwise note is added under a new Id. Storing a note drops the
+// tombstone of its UUID.
+func putNote(ctx context.Context, tx *sql.Tx, note model.Note) error {
+	stored, err := queryNotes(ctx, tx, selectNotesSQL+" WHERE n.uuid = ?", note.UUID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM tombstones WHERE uuid = ?", note.UUID)
+	if err != nil {
+		return err
+	}
+	if len(stored) == 0 {
+		note.Id = 0
+		return importNote(ctx, tx, note)
+	}
+
+	id := stored[0].Id
+	if ContentHash(stored[0]) != ContentHash(note) {
+		err = copyRevision(ctx, tx, id, false)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE notes SET note = ?, created_at = ?, updated_at = ?, deleted_at = ? WHERE id = ?",
+		note.Value, formatTime(note.CreatedAt), formatTime(note.UpdatedAt), formatStoredTime(note.DeletedAt), id)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM note_tags WHERE note_id = ?", id)
+	if err != nil {
+		return err
+	}
+	return linkTags(ctx, tx, id, note.Tags)
+}
+
+// forgetNote removes the note with the UUID of tombstone, if any, and
+// records the tombstone. It reports whether a note was removed.
+func forgetNote(ctx context.Context, tx *sql.Tx, tombstone model.Tombstone) (bool, error) {
+	res, err := tx.ExecContext(ctx, "DELETE FROM notes WHERE uuid = ?", tombstone.UUID)
+	if err != nil {
+		return false, err
+	}
+	removed, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO tombstones (uuid, deleted_at) VALUES (?, ?)
+		ON CONFLICT (uuid) DO UPDATE SET deleted_at = MAX(deleted_at, excluded.deleted_at)`,
+		tombstone.UUID, formatTime(tombstone.DeletedAt))
+	if err != nil {
+		return false, err
+	}
+	return removed > 0, nil
+}
+
+func queryTombstones(ctx context.Context, dbConn *sql.DB) ([]model.Tombstone, error) {
+	rows, err := dbConn.QueryContext(ctx, "SELECT uuid, deleted_at FROM tombstones ORDER BY uuid")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []model.Tombstone
+	for rows.Next() {
+		var tombstone model.Tombstone
+		var deletedAt string
+		err := rows.Scan(&tombstone.UUID, &deletedAt)
+		if err != nil {
+			return nil, err
+		}
+		tombstone.DeletedAt, err = parseTime(deletedAt)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, tombstone)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
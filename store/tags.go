@@ -0,0 +1,108 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// mergeTags moves the notes carrying one of the tags from to the tag into
+// and removes the tags from, inside tx. It returns how many notes changed.
+func mergeTags(ctx context.Context, tx *sql.Tx, from []string, into string) (int64, error) {
+	_, err := tx.ExecContext(ctx, "INSERT OR IGNORE INTO tags (name) VALUES (?)", into)
+	if err != nil {
+		return 0, err
+	}
+	renames := map[string]string{}
+	for _, name := range from {
+		if name != into {
+			renames[name] = into
+		}
+	}
+	return renameTags(ctx, tx, renames)
+}
+
+// renameTags gives each tag that is a key of renames the name it maps to,
+// merging it into the tag of that name when there is one, and removes the
+// old tags, inside tx. No tag may be renamed to another of the renamed
+// tags. Each changed note gets one revision and a new update time, so
+// syncs see the change as an edit. It returns how many notes changed.
+func renameTags(ctx context.Context, tx *sql.Tx, renames map[string]string) (int64, error) {
+	names := make([]string, 0, len(renames))
+	for name := range renames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tagIds := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		var id int64
+		err := tx.QueryRowContext(ctx, "SELECT id FROM tags WHERE name = ?", name).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("tag %q: %w", name, ErrNotFound)
+		}
+		if err != nil {
+			return 0, err
+		}
+		tagIds = append(tagIds, id)
+	}
+	if len(tagIds) == 0 {
+		return 0, nil
+	}
+
+	inTags := "tag_id IN (" + placeholders(len(tagIds)) + ")"
+	rows, err := tx.QueryContext(ctx, "SELECT DISTINCT note_id FROM note_tags WHERE "+inTags+" ORDER BY note_id", tagIds...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	var noteIds []int64
+	for rows.Next() {
+		var id int64
+		err = rows.Scan(&id)
+		if err != nil {
+			return 0, err
+		}
+		noteIds = append(noteIds, id)
+	}
+	err = rows.Err()
+	if err != nil {
+		return 0, err
+	}
+	rows.Close()
+
+	stamp := formatTime(now())
+	for _, id := range noteIds {
+		err = copyRevision(ctx, tx, id, false)
+		if err != nil {
+			return 0, err
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE notes SET updated_at = ? WHERE id = ?", stamp, id)
+		if err != nil {
+			return 0, err
+		}
+	}
+	for i, name := range names {
+		_, err = tx.ExecContext(ctx, "INSERT OR IGNORE INTO tags (name) VALUES (?)", renames[name])
+		if err != nil {
+			return 0, err
+		}
+		_, err = tx.ExecContext(ctx, "INSERT OR IGNORE INTO note_tags (note_id, tag_id) SELECT note_id, (SELECT id FROM tags WHERE name = ?) FROM note_tags WHERE tag_id = ?",
+			renames[name], tagIds[i])
+		if err != nil {
+			return 0, err
+		}
+	}
+	// Unlinking before removing the tags runs the note_tags triggers that
+	// keep the full-text index in step.
+	_, err = tx.ExecContext(ctx, "DELETE FROM note_tags WHERE "+inTags, tagIds...)
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM tags WHERE id IN ("+placeholders(len(tagIds))+")", tagIds...)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(noteIds)), nil
+}